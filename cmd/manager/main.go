@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -28,16 +29,21 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	jivav1alpha1 "github.com/openebs/jiva-operator/pkg/apis/openebs/v1alpha1"
 	"github.com/openebs/jiva-operator/pkg/controllers"
+	jivawebhook "github.com/openebs/jiva-operator/pkg/webhook"
 	"github.com/openebs/jiva-operator/version"
 	"github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,7 +54,10 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
 	utilruntime.Must(jivaAPI.AddToScheme(scheme))
+	utilruntime.Must(jivav1alpha1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -58,15 +67,59 @@ func printVersion() {
 	logrus.Info(fmt.Sprintf("Version of jiva-operator: %v", version.Version))
 }
 
+// volumeReconcilerFlags registers the flags which configure the JivaVolume
+// reconciler and returns the reconciler the flags are parsed into. The
+// client, the scheme and the recorder are set once the manager is created.
+func volumeReconcilerFlags(fs *flag.FlagSet) *controllers.JivaVolumeReconciler {
+	r := &controllers.JivaVolumeReconciler{}
+	fs.DurationVar(&r.ReplicaFailureGracePeriod, "replica-failure-grace-period", controllers.DefaultReplicaFailureGracePeriod,
+		"The duration for which a replica can stay unhealthy before it is replaced.")
+	fs.DurationVar(&r.TargetFailoverGracePeriod, "target-failover-grace-period", controllers.DefaultTargetFailoverGracePeriod,
+		"The duration for which the node of a target pod can stay NotReady before the pod is force deleted.")
+	fs.IntVar(&r.MaxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The maximum number of volumes reconciled in parallel.")
+	return r
+}
+
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var autoUpgradeInterval time.Duration
+	var autoUpgradeMaxConcurrent, autoUpgradeBatchSize int
+	var autoUpgradeWindow string
+	var targetPlacementWindow string
+	var enableWebhooks bool
+	var migrateStorageVersion bool
+	var webhookPort int
+	var webhookCertDir, webhookServiceName string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8383", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8282", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	volumeReconciler := volumeReconcilerFlags(flag.CommandLine)
+	flag.DurationVar(&autoUpgradeInterval, "auto-upgrade-interval", controllers.DefaultAutoUpgradeInterval,
+		"The interval at which the volumes with auto upgrade enabled are checked for upgrade.")
+	flag.IntVar(&autoUpgradeMaxConcurrent, "auto-upgrade-max-concurrent", 1,
+		"The maximum number of volumes upgraded at a time.")
+	flag.IntVar(&autoUpgradeBatchSize, "auto-upgrade-batch-size", 1,
+		"The maximum number of volume upgrades started in an interval.")
+	flag.StringVar(&autoUpgradeWindow, "auto-upgrade-maintenance-window", "",
+		"The daily window in UTC, in HH:MM-HH:MM format, during which the volume upgrades are started. "+
+			"If not set the upgrades are started at any time.")
+	flag.StringVar(&targetPlacementWindow, "target-placement-maintenance-window", "",
+		"The daily window in UTC, in HH:MM-HH:MM format, during which the target of a staged volume "+
+			"is moved to the consumer node. If not set the target is moved only when the volume is not staged.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", true,
+		"Enable the admission webhooks which default and validate the volumes and the volume policies.")
+	flag.BoolVar(&migrateStorageVersion, "migrate-storage-version", true,
+		"Rewrite the JivaVolumes and JivaVolumePolicies stored in an older API version in the storage version.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server listens on.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory to which the certificate of the webhook server is written.")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", jivawebhook.ConfigurationName,
+		"The name of the service in front of the webhook server, in the operator namespace.")
 	flag.Parse()
 
 	duration := 30 * time.Second
@@ -74,10 +127,14 @@ func main() {
 	// Controller Runtime Logger Init
 	logf.SetLogger(zap.New(zap.WriteTo(os.Stdout), zap.UseDevMode(true)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   8686,
+	cfg := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: metricsAddr,
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "jiva-operator.openebs.io",
@@ -87,12 +144,78 @@ func main() {
 		logrus.Fatal("failed to create manager:", err)
 	}
 
-	if err = (&controllers.JivaVolumeReconciler{
+	volumeReconciler.Client = mgr.GetClient()
+	volumeReconciler.Scheme = mgr.GetScheme()
+	volumeReconciler.Recorder = mgr.GetEventRecorderFor("jivavolume-controller")
+	if targetPlacementWindow != "" {
+		volumeReconciler.TargetPlacementWindow, err = controllers.ParseMaintenanceWindow(targetPlacementWindow)
+		if err != nil {
+			logrus.Fatal("failed to parse target placement maintenance window:", err)
+		}
+	}
+	if err = volumeReconciler.SetupWithManager(mgr); err != nil {
+		logrus.Fatal("failed to create controller JivaVolume:", err)
+	}
+
+	if err = (&controllers.JivaVolumePolicyReconciler{
 		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("jivavolume-controller"),
+		Recorder: mgr.GetEventRecorderFor("jivavolumepolicy-controller"),
 	}).SetupWithManager(mgr); err != nil {
-		logrus.Fatal("failed to create controller JivaVolume:", err)
+		logrus.Fatal("failed to create controller JivaVolumePolicy:", err)
+	}
+
+	if err = (&controllers.NodeEvacuationReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("jiva-node-evacuation"),
+	}).SetupWithManager(mgr); err != nil {
+		logrus.Fatal("failed to create controller NodeEvacuation:", err)
+	}
+
+	autoUpgradeScheduler := &controllers.AutoUpgradeScheduler{
+		Client:        mgr.GetClient(),
+		Recorder:      mgr.GetEventRecorderFor("jiva-auto-upgrade"),
+		Interval:      autoUpgradeInterval,
+		MaxConcurrent: autoUpgradeMaxConcurrent,
+		BatchSize:     autoUpgradeBatchSize,
+	}
+	if autoUpgradeWindow != "" {
+		autoUpgradeScheduler.MaintenanceWindow, err = controllers.ParseMaintenanceWindow(autoUpgradeWindow)
+		if err != nil {
+			logrus.Fatal("failed to parse auto upgrade maintenance window:", err)
+		}
+	}
+	if err := mgr.Add(autoUpgradeScheduler); err != nil {
+		logrus.Fatal("failed to add auto upgrade scheduler:", err)
+	}
+	if migrateStorageVersion {
+		if err := mgr.Add(&controllers.StorageVersionMigrator{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+		}); err != nil {
+			logrus.Fatal("failed to add storage version migrator:", err)
+		}
+	}
+	if enableWebhooks {
+		// the certificate is set up before the manager starts
+		// as the webhook server loads it on start
+		cl, err := client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			logrus.Fatal("failed to create client:", err)
+		}
+		err = jivawebhook.SetupCertificates(context.Background(), cl, jivawebhook.CertConfig{
+			Namespace:   os.Getenv("OPENEBS_NAMESPACE"),
+			ServiceName: webhookServiceName,
+			CertDir:     webhookCertDir,
+		})
+		if err != nil {
+			logrus.Fatal("failed to set up webhook certificates:", err)
+		}
+		if err = (&jivawebhook.JivaVolumePolicyWebhook{}).SetupWithManager(mgr); err != nil {
+			logrus.Fatal("failed to create webhook JivaVolumePolicy:", err)
+		}
+		if err = (&jivawebhook.JivaVolumeWebhook{}).SetupWithManager(mgr); err != nil {
+			logrus.Fatal("failed to create webhook JivaVolume:", err)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 	printVersion()
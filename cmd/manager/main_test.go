@@ -0,0 +1,66 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openebs/jiva-operator/pkg/controllers"
+)
+
+func TestVolumeReconcilerFlags(t *testing.T) {
+	tests := map[string]struct {
+		args                     []string
+		expectMaxConcurrent      int
+		expectReplicaGracePeriod time.Duration
+	}{
+		"Test default flags": {
+			expectMaxConcurrent:      1,
+			expectReplicaGracePeriod: controllers.DefaultReplicaFailureGracePeriod,
+		},
+		"Test max concurrent reconciles": {
+			args:                     []string{"--max-concurrent-reconciles=4"},
+			expectMaxConcurrent:      4,
+			expectReplicaGracePeriod: controllers.DefaultReplicaFailureGracePeriod,
+		},
+		"Test replica failure grace period": {
+			args:                     []string{"--replica-failure-grace-period=10m"},
+			expectMaxConcurrent:      1,
+			expectReplicaGracePeriod: 10 * time.Minute,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("jiva-operator", flag.ContinueOnError)
+			r := volumeReconcilerFlags(fs)
+			if err := fs.Parse(mock.args); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if r.MaxConcurrentReconciles != mock.expectMaxConcurrent {
+				t.Fatalf("Test %q failed: expected %d concurrent reconciles, got %d",
+					name, mock.expectMaxConcurrent, r.MaxConcurrentReconciles)
+			}
+			if r.ReplicaFailureGracePeriod != mock.expectReplicaGracePeriod {
+				t.Fatalf("Test %q failed: expected replica failure grace period %s, got %s",
+					name, mock.expectReplicaGracePeriod, r.ReplicaFailureGracePeriod)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Copyright © 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// Hub marks JivaVolume as a conversion hub, v1 is the version the
+// other versions are converted to and from by the conversion webhook.
+func (*JivaVolume) Hub() {}
+
+// Hub marks JivaVolumePolicy as a conversion hub.
+func (*JivaVolumePolicy) Hub() {}
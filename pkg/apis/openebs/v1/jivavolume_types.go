@@ -27,6 +27,10 @@ type ISCSISpec struct {
 	TargetIP   string `json:"targetIP,omitempty"`
 	TargetPort int32  `json:"targetPort,omitempty"`
 	Iqn        string `json:"iqn,omitempty"`
+	// ExternalPortals are the addresses of the target
+	// for the initiators outside the cluster
+	// +nullable
+	ExternalPortals []string `json:"externalPortals,omitempty"`
 }
 
 type MountInfo struct {
@@ -38,6 +42,9 @@ type MountInfo struct {
 	TargetPath string `json:"targetPath,omitempty"`
 	FSType     string `json:"fsType,omitempty"`
 	DevicePath string `json:"devicePath,omitempty"`
+	// PodUID is the UID of the pod the volume is published to, the
+	// I/O limits of the volume are applied to the cgroup of the pod.
+	PodUID string `json:"podUID,omitempty"`
 }
 
 // JivaVolumeSpec defines the desired state of JivaVolume
@@ -67,6 +74,117 @@ type JivaVolumeStatus struct {
 	ReplicaStatuses []ReplicaStatus `json:"replicaStatus,omitempty"`
 	// Phase represents the current phase of JivaVolume.
 	Phase JivaVolumePhase `json:"phase,omitempty"`
+	// Scaleup reports the progress of an ongoing replica scaleup.
+	// +nullable
+	Scaleup *ReplicaScaleupStatus `json:"scaleup,omitempty"`
+	// FailedReplicas are the replicas found unhealthy, these are replaced
+	// if they don't recover within the failure grace period.
+	// +nullable
+	FailedReplicas []FailedReplica `json:"failedReplicas,omitempty"`
+	// ReplacedReplicaCount is the number of failed replicas replaced so far
+	ReplacedReplicaCount int `json:"replacedReplicaCount,omitempty"`
+	// PolicyGeneration is the generation of the JivaVolumePolicy
+	// which has been applied to the volume
+	PolicyGeneration int64 `json:"policyGeneration,omitempty"`
+	// BasePolicyGenerations are the generations of the base policies
+	// inherited by the JivaVolumePolicy, which have been applied
+	// to the volume, keyed by the name of the policy
+	// +nullable
+	BasePolicyGenerations map[string]int64 `json:"basePolicyGenerations,omitempty"`
+	// TopologySpread reports the placement of the replicas across
+	// the topology domains configured in the replica policy.
+	// +nullable
+	TopologySpread *TopologySpreadStatus `json:"topologySpread,omitempty"`
+	// Evacuation reports the progress of the evacuation of
+	// a replica from a node under maintenance.
+	// +nullable
+	Evacuation *ReplicaEvacuationStatus `json:"evacuation,omitempty"`
+	// PendingActions are the actions of the operator waiting for
+	// the volume components to reach the expected state, the volume
+	// is reconciled again till they are completed.
+	// +nullable
+	PendingActions []PendingAction `json:"pendingActions,omitempty"`
+	// ConsumerNode is the node on which the volume
+	// has been staged most recently.
+	ConsumerNode string `json:"consumerNode,omitempty"`
+	// ColocatedNode is the consumer node the target
+	// deployment prefers, if the target is colocated.
+	ColocatedNode string `json:"colocatedNode,omitempty"`
+	// ExternalPortals are the addresses of the target for the
+	// initiators outside the cluster, if the target is exposed.
+	// +nullable
+	ExternalPortals []string `json:"externalPortals,omitempty"`
+}
+
+// PendingAction is an action of the operator waiting for
+// the volume components to reach the expected state
+type PendingAction struct {
+	// Type is the type of the action, e.g. ReplicaMovement
+	Type string `json:"type"`
+	// Target is the name of the object the action is waiting on
+	Target string `json:"target,omitempty"`
+	// Message is a human readable message about the action
+	Message string `json:"message,omitempty"`
+	// Since is the time the action was started
+	// +nullable
+	Since metav1.Time `json:"since,omitempty"`
+}
+
+// EvacuationStep is a step of the replica evacuation
+type EvacuationStep string
+
+const (
+	// EvacuationStepPending waits for all the replicas
+	// to be in RW mode before the replica is evacuated
+	EvacuationStepPending EvacuationStep = "Pending"
+	// EvacuationStepAddingReplica waits for an additional
+	// replica to be rebuilt on another node
+	EvacuationStepAddingReplica EvacuationStep = "AddingReplica"
+	// EvacuationStepRebuilding waits for the
+	// replacement replica to be rebuilt
+	EvacuationStepRebuilding EvacuationStep = "Rebuilding"
+	// EvacuationStepRemovingReplica waits for the additional
+	// replica to be removed once the replacement is rebuilt
+	EvacuationStepRemovingReplica EvacuationStep = "RemovingReplica"
+	// EvacuationStepSkipped is set by the earlier versions of the
+	// operator for the replicas of the volumes with less than 3
+	// replicas, these have to be moved manually
+	EvacuationStepSkipped EvacuationStep = "Skipped"
+)
+
+// ReplicaEvacuationStatus stores the progress of the evacuation
+// of a replica from a node under maintenance
+type ReplicaEvacuationStatus struct {
+	// Node is the node the replica is evacuated from
+	Node string `json:"node"`
+	// ClaimName is the name of the PVC of the evacuated replica
+	ClaimName string `json:"claimName,omitempty"`
+	// Step is the current step of the evacuation
+	Step EvacuationStep `json:"step,omitempty"`
+	// ReplicationFactor is the replication factor of the
+	// volume before the additional replica was added
+	ReplicationFactor int `json:"replicationFactor,omitempty"`
+	// Message is a human readable message about the progress
+	Message string `json:"message,omitempty"`
+	// StartTime is the time the evacuation was started
+	// +nullable
+	StartTime metav1.Time `json:"startTime,omitempty"`
+}
+
+// TopologySpreadStatus reports the spread of the replicas
+// across the topology domains
+type TopologySpreadStatus struct {
+	// TopologyKey is the key of the node labels used for the spread
+	TopologyKey string `json:"topologyKey"`
+	// Domains is the number of replicas in each topology domain
+	// +nullable
+	Domains map[string]int `json:"domains,omitempty"`
+	// Skew is the difference between the number of replicas
+	// in the most and the least populated topology domains
+	Skew int32 `json:"skew"`
+	// Violated is set when the current placement of the
+	// replicas breaks the requested spread
+	Violated bool `json:"violated"`
 }
 
 // +genclient
@@ -101,6 +219,52 @@ type JivaVolumeList struct {
 type ReplicaStatus struct {
 	Address string `json:"address,omitempty"`
 	Mode    string `json:"mode,omitempty"`
+	// Rebuild reports the progress of the replica rebuild,
+	// it is set only while the replica is in WO mode.
+	// +nullable
+	Rebuild *RebuildStatus `json:"rebuild,omitempty"`
+}
+
+// RebuildStatus stores the rebuild progress of a replica
+type RebuildStatus struct {
+	// SyncedBytes is the amount of data synced from the healthy replicas
+	SyncedBytes int64 `json:"syncedBytes,omitempty"`
+	// TotalBytes is the amount of data to be synced from the healthy replicas
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// Percentage is the percentage of data synced
+	Percentage int `json:"percentage,omitempty"`
+	// StartTime is the time the rebuild was first observed
+	// +nullable
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// EstimatedFinishTime is the estimated time of rebuild completion
+	// based on the rate at which the data has been synced so far
+	// +nullable
+	EstimatedFinishTime *metav1.Time `json:"estimatedFinishTime,omitempty"`
+}
+
+// FailedReplica stores the details of an unhealthy replica
+type FailedReplica struct {
+	// PodName is the name of the replica pod
+	PodName string `json:"podName,omitempty"`
+	// Reason is the reason the replica is considered unhealthy
+	Reason string `json:"reason,omitempty"`
+	// Since is the time the replica was first found unhealthy
+	// +nullable
+	Since metav1.Time `json:"since,omitempty"`
+}
+
+// ReplicaScaleupStatus stores the progress of a replica scaleup, replicas
+// are added one at a time till the desired replication factor is reached.
+type ReplicaScaleupStatus struct {
+	// Desired is the replication factor the volume is being scaled up to
+	Desired int `json:"desired,omitempty"`
+	// Current is the replication factor applied to the volume so far
+	Current int `json:"current,omitempty"`
+	// Message is a human readable description of the current step
+	Message string `json:"message,omitempty"`
+	// LastUpdateTime is the time the step was last updated
+	// +nullable
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
 }
 
 // JivaVolumePhase represents the current phase of JivaVolume.
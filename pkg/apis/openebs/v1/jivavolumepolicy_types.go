@@ -26,6 +26,10 @@ import (
 
 // JivaVolumePolicySpec defines the desired state of JivaVolumePolicy
 type JivaVolumePolicySpec struct {
+	// BasePolicy is the name of the JivaVolumePolicy, in the same
+	// namespace, which this policy inherits from. The fields set in
+	// this policy override the ones of the base policy.
+	BasePolicy string `json:"basePolicy,omitempty"`
 	// ReplicaSC represents the storage class used for
 	// creating the pvc for the replicas (provisioned by localpv provisioner)
 	ReplicaSC string `json:"replicaSC,omitempty"`
@@ -40,29 +44,130 @@ type JivaVolumePolicySpec struct {
 	// ReplicaSpec represents configuration related to replicas resources
 	// +nullable
 	Replica ReplicaSpec `json:"replica,omitempty"`
+	// DataRetention is the policy for the replica data when the volume
+	// is deleted, it can be Delete, Retain or Wipe. Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Retain;Wipe
+	DataRetention DataRetentionPolicy `json:"dataRetention,omitempty"`
 }
 
+// DataRetentionPolicy is the policy for the replica data on volume deletion
+type DataRetentionPolicy string
+
+const (
+	// DataRetentionDelete deletes the replica PVCs, the data is
+	// removed as per the reclaim policy of the replica storage class
+	DataRetentionDelete DataRetentionPolicy = "Delete"
+	// DataRetentionRetain detaches the replica PVCs from the volume
+	// and labels them, so that they can be adopted later
+	DataRetentionRetain DataRetentionPolicy = "Retain"
+	// DataRetentionWipe overwrites the replica data
+	// before the replica PVCs are deleted
+	DataRetentionWipe DataRetentionPolicy = "Wipe"
+)
+
 // TargetSpec represents configuration related to jiva target deployment
 type TargetSpec struct {
 	// DisableMonitor will not attach prometheus exporter sidecar to jiva volume target.
-	DisableMonitor bool `json:"disableMonitor,omitempty"`
+	// Defaults to true, the volume metrics are exported by the operator.
+	// +nullable
+	DisableMonitor *bool `json:"disableMonitor,omitempty"`
 
 	// ReplicationFactor represents maximum number of replicas
 	// that are allowed to connect to the target
 	ReplicationFactor int `json:"replicationFactor,omitempty"`
 
+	// ColocateWithConsumer if set, the target prefers the node on which the
+	// volume is staged by the application. The target is moved only when the
+	// volume is not staged or within the target placement maintenance window.
+	ColocateWithConsumer bool `json:"colocateWithConsumer,omitempty"`
+
 	// PodTemplateResources represents the configuration for target deployment.
 	PodTemplateResources `json:",inline"`
 
 	// AuxResources are the compute resources required by the jiva-target pod
 	// side car containers.
 	AuxResources *corev1.ResourceRequirements `json:"auxResources,omitempty"`
+
+	// QoS are the I/O limits of the volume, these are enforced on the
+	// node of the consuming pod and can be changed on a running volume.
+	// +nullable
+	QoS *QoSSpec `json:"qos,omitempty"`
+
+	// MonitorImage if specified, overrides the image of the
+	// prometheus exporter side car set for the operator.
+	MonitorImage string `json:"monitorImage,omitempty"`
+
+	// ExternalService if specified, exposes the iSCSI port of the
+	// target to the initiators outside the cluster.
+	// +nullable
+	ExternalService *ExternalServiceSpec `json:"externalService,omitempty"`
+}
+
+// ExternalServiceSpec represents the service which exposes
+// the target to the initiators outside the cluster
+type ExternalServiceSpec struct {
+	// Type of the service, NodePort or LoadBalancer
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	Type corev1.ServiceType `json:"type"`
+
+	// Annotations of the service, e.g. to configure the load balancer
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// AllowedInitiators are the CIDRs of the initiators outside the
+	// cluster which are allowed to connect to the target. The jiva
+	// target doesn't support CHAP, so at least one is required.
+	// +kubebuilder:validation:MinItems=1
+	AllowedInitiators []string `json:"allowedInitiators"`
+}
+
+// QoSSpec represents the I/O limits of a volume, a limit
+// which is not set or is zero doesn't limit the I/O
+type QoSSpec struct {
+	// ReadIOPS is the maximum number of read operations per second
+	// +kubebuilder:validation:Minimum=0
+	ReadIOPS int64 `json:"readIOPS,omitempty"`
+
+	// WriteIOPS is the maximum number of write operations per second
+	// +kubebuilder:validation:Minimum=0
+	WriteIOPS int64 `json:"writeIOPS,omitempty"`
+
+	// ReadBPS is the maximum number of bytes read per second
+	// +kubebuilder:validation:Minimum=0
+	ReadBPS int64 `json:"readBPS,omitempty"`
+
+	// WriteBPS is the maximum number of bytes written per second
+	// +kubebuilder:validation:Minimum=0
+	WriteBPS int64 `json:"writeBPS,omitempty"`
 }
 
 // ReplicaSpec represents configuration related to jiva replica sts
 type ReplicaSpec struct {
 	// PodTemplateResources represents the configuration for replica sts.
 	PodTemplateResources `json:",inline"`
+
+	// TopologySpread if specified, spreads the replicas
+	// across the domains of the given topology key
+	// +nullable
+	TopologySpread *ReplicaTopologySpread `json:"topologySpread,omitempty"`
+}
+
+// ReplicaTopologySpread represents how the replicas
+// are spread across the topology domains
+type ReplicaTopologySpread struct {
+	// TopologyKey is the key of the node labels which identifies the
+	// topology domain of the node, e.g. topology.kubernetes.io/zone
+	TopologyKey string `json:"topologyKey"`
+
+	// MaxSkew is the maximum difference between the number of replicas
+	// in any two topology domains. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+
+	// WhenUnsatisfiable is the action taken when a replica can't be
+	// placed without breaking the spread, it can be DoNotSchedule or
+	// ScheduleAnyway. Defaults to DoNotSchedule.
+	// +kubebuilder:validation:Enum=DoNotSchedule;ScheduleAnyway
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
 }
 
 // PodTemplateResources represents the common configuration field for
@@ -81,13 +186,85 @@ type PodTemplateResources struct {
 	// NodeSelector is the labels that will be used to select
 	// a node for pod scheduleing
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Image if specified, overrides the image of the jiva
+	// container set for the operator.
+	Image string `json:"image,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the images of the
+	// pod. Defaults to IfNotPresent.
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are the secrets in the namespace of
+	// the pod used to pull the images of the pod.
+	// +nullable
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Labels are added to the labels of the pod, the labels
+	// with the openebs.io/ prefix are reserved for the operator.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the annotations of the pod, the
+	// annotations set by the operator take precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Env are added to the environment variables of the jiva container,
+	// the environment variables set by the operator take precedence.
+	// +nullable
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// PodSecurityContext is the security context of the pod.
+	// +nullable
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is the security context of the jiva container.
+	// The replica container runs privileged if it isn't specified.
+	// +nullable
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
 }
 
 // JivaVolumePolicyStatus is for handling status of JivaVolumePolicy
 type JivaVolumePolicyStatus struct {
+	// Phase is Valid if all the resources referenced
+	// by the policy exist, otherwise Invalid
 	Phase string `json:"phase"`
+	// ObservedGeneration is the generation of the policy
+	// which has been validated
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions report the validation of each
+	// of the resources referenced by the policy
+	// +nullable
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// VolumeCount is the number of volumes using the policy
+	VolumeCount int `json:"volumeCount,omitempty"`
+	// Volumes are the names of the volumes using the policy
+	// +nullable
+	Volumes []string `json:"volumes,omitempty"`
 }
 
+const (
+	// JivaVolumePolicyPhaseValid means that all the resources
+	// referenced by the policy exist
+	JivaVolumePolicyPhaseValid = "Valid"
+	// JivaVolumePolicyPhaseInvalid means that some of the
+	// resources referenced by the policy don't exist
+	JivaVolumePolicyPhaseInvalid = "Invalid"
+
+	// PolicyConditionReplicaSC reports if the replica storage class exists
+	PolicyConditionReplicaSC = "ReplicaSCAvailable"
+	// PolicyConditionPriorityClass reports if the priority class exists
+	PolicyConditionPriorityClass = "PriorityClassAvailable"
+	// PolicyConditionServiceAccount reports if the service account exists
+	PolicyConditionServiceAccount = "ServiceAccountAvailable"
+	// PolicyConditionImagePullSecrets reports if
+	// the image pull secrets of the pods exist
+	PolicyConditionImagePullSecrets = "ImagePullSecretsAvailable"
+	// PolicyConditionBasePolicy reports if the base policies
+	// exist and the chain of base policies has no cycle
+	PolicyConditionBasePolicy = "BasePolicyAvailable"
+)
+
 // +genclient
 // JivaVolumePolicy is the Schema for the jivavolumes API
 // Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
@@ -95,6 +272,10 @@ type JivaVolumePolicyStatus struct {
 // +k8s:openapi-gen=true
 // +kubebuilder:storageversion
 // +kubebuilder:resource:scope=Namespaced,shortName=jvp
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Volumes",type="integer",JSONPath=`.status.volumeCount`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
 type JivaVolumePolicy struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -47,6 +47,14 @@ type VersionStatus struct {
 	// LastUpdateTime is the time the status was last  updated
 	// +nullable
 	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// UpgradeStep is the step of the data plane upgrade in progress,
+	// it is used to resume a failed upgrade
+	UpgradeStep UpgradeStep `json:"upgradeStep,omitempty"`
+	// PreviousImages are the images of the containers, keyed by the
+	// container name, before the upgrade was started. These are used
+	// to roll back the upgrade.
+	// +nullable
+	PreviousImages map[string]string `json:"previousImages,omitempty"`
 }
 
 // VersionState is the state of reconciliation
@@ -62,6 +70,18 @@ const (
 	ReconcilePending VersionState = "ReconcilePending"
 )
 
+// UpgradeStep is the step of the data plane upgrade
+type UpgradeStep string
+
+const (
+	// UpgradeStepReplicas is the step when the replicas
+	// are restarted one at a time with the new images
+	UpgradeStepReplicas UpgradeStep = "UpgradeReplicas"
+	// UpgradeStepTarget is the step when the target is
+	// restarted with the new images
+	UpgradeStepTarget UpgradeStep = "UpgradeTarget"
+)
+
 // SetErrorStatus sets the message and reason for the error
 func (vs *VersionStatus) SetErrorStatus(msg string, err error) {
 	vs.Message = msg
@@ -82,5 +102,7 @@ func (vd *VersionDetails) SetSuccessStatus() {
 	vd.Status.Message = ""
 	vd.Status.Reason = ""
 	vd.Status.State = ReconcileComplete
+	vd.Status.UpgradeStep = ""
+	vd.Status.PreviousImages = nil
 	vd.Status.LastUpdateTime = metav1.Now()
 }
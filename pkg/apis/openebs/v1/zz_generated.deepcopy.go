@@ -23,12 +23,63 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalServiceSpec) DeepCopyInto(out *ExternalServiceSpec) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AllowedInitiators != nil {
+		in, out := &in.AllowedInitiators, &out.AllowedInitiators
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalServiceSpec.
+func (in *ExternalServiceSpec) DeepCopy() *ExternalServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedReplica) DeepCopyInto(out *FailedReplica) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedReplica.
+func (in *FailedReplica) DeepCopy() *FailedReplica {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedReplica)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ISCSISpec) DeepCopyInto(out *ISCSISpec) {
 	*out = *in
+	if in.ExternalPortals != nil {
+		in, out := &in.ExternalPortals, &out.ExternalPortals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -110,7 +161,7 @@ func (in *JivaVolumePolicy) DeepCopyInto(out *JivaVolumePolicy) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -186,6 +237,18 @@ func (in *JivaVolumePolicySpec) DeepCopy() *JivaVolumePolicySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JivaVolumePolicyStatus) DeepCopyInto(out *JivaVolumePolicyStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -202,7 +265,7 @@ func (in *JivaVolumePolicyStatus) DeepCopy() *JivaVolumePolicyStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JivaVolumeSpec) DeepCopyInto(out *JivaVolumeSpec) {
 	*out = *in
-	out.ISCSISpec = in.ISCSISpec
+	in.ISCSISpec.DeepCopyInto(&out.ISCSISpec)
 	out.MountInfo = in.MountInfo
 	in.Policy.DeepCopyInto(&out.Policy)
 	return
@@ -224,6 +287,49 @@ func (in *JivaVolumeStatus) DeepCopyInto(out *JivaVolumeStatus) {
 	if in.ReplicaStatuses != nil {
 		in, out := &in.ReplicaStatuses, &out.ReplicaStatuses
 		*out = make([]ReplicaStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Scaleup != nil {
+		in, out := &in.Scaleup, &out.Scaleup
+		*out = new(ReplicaScaleupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailedReplicas != nil {
+		in, out := &in.FailedReplicas, &out.FailedReplicas
+		*out = make([]FailedReplica, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BasePolicyGenerations != nil {
+		in, out := &in.BasePolicyGenerations, &out.BasePolicyGenerations
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TopologySpread != nil {
+		in, out := &in.TopologySpread, &out.TopologySpread
+		*out = new(TopologySpreadStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Evacuation != nil {
+		in, out := &in.Evacuation, &out.Evacuation
+		*out = new(ReplicaEvacuationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingActions != nil {
+		in, out := &in.PendingActions, &out.PendingActions
+		*out = make([]PendingAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExternalPortals != nil {
+		in, out := &in.ExternalPortals, &out.ExternalPortals
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 	return
@@ -255,6 +361,23 @@ func (in *MountInfo) DeepCopy() *MountInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingAction) DeepCopyInto(out *PendingAction) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingAction.
+func (in *PendingAction) DeepCopy() *PendingAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodTemplateResources) DeepCopyInto(out *PodTemplateResources) {
 	*out = *in
@@ -282,6 +405,42 @@ func (in *PodTemplateResources) DeepCopyInto(out *PodTemplateResources) {
 			(*out)[key] = val
 		}
 	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -295,10 +454,86 @@ func (in *PodTemplateResources) DeepCopy() *PodTemplateResources {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QoSSpec) DeepCopyInto(out *QoSSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QoSSpec.
+func (in *QoSSpec) DeepCopy() *QoSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(QoSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RebuildStatus) DeepCopyInto(out *RebuildStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.EstimatedFinishTime != nil {
+		in, out := &in.EstimatedFinishTime, &out.EstimatedFinishTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RebuildStatus.
+func (in *RebuildStatus) DeepCopy() *RebuildStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RebuildStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaEvacuationStatus) DeepCopyInto(out *ReplicaEvacuationStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaEvacuationStatus.
+func (in *ReplicaEvacuationStatus) DeepCopy() *ReplicaEvacuationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaEvacuationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaScaleupStatus) DeepCopyInto(out *ReplicaScaleupStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaScaleupStatus.
+func (in *ReplicaScaleupStatus) DeepCopy() *ReplicaScaleupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaScaleupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReplicaSpec) DeepCopyInto(out *ReplicaSpec) {
 	*out = *in
 	in.PodTemplateResources.DeepCopyInto(&out.PodTemplateResources)
+	if in.TopologySpread != nil {
+		in, out := &in.TopologySpread, &out.TopologySpread
+		*out = new(ReplicaTopologySpread)
+		**out = **in
+	}
 	return
 }
 
@@ -315,6 +550,11 @@ func (in *ReplicaSpec) DeepCopy() *ReplicaSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReplicaStatus) DeepCopyInto(out *ReplicaStatus) {
 	*out = *in
+	if in.Rebuild != nil {
+		in, out := &in.Rebuild, &out.Rebuild
+		*out = new(RebuildStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -328,15 +568,46 @@ func (in *ReplicaStatus) DeepCopy() *ReplicaStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaTopologySpread) DeepCopyInto(out *ReplicaTopologySpread) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaTopologySpread.
+func (in *ReplicaTopologySpread) DeepCopy() *ReplicaTopologySpread {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaTopologySpread)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetSpec) DeepCopyInto(out *TargetSpec) {
 	*out = *in
+	if in.DisableMonitor != nil {
+		in, out := &in.DisableMonitor, &out.DisableMonitor
+		*out = new(bool)
+		**out = **in
+	}
 	in.PodTemplateResources.DeepCopyInto(&out.PodTemplateResources)
 	if in.AuxResources != nil {
 		in, out := &in.AuxResources, &out.AuxResources
 		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(QoSSpec)
+		**out = **in
+	}
+	if in.ExternalService != nil {
+		in, out := &in.ExternalService, &out.ExternalService
+		*out = new(ExternalServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -350,6 +621,29 @@ func (in *TargetSpec) DeepCopy() *TargetSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologySpreadStatus) DeepCopyInto(out *TopologySpreadStatus) {
+	*out = *in
+	if in.Domains != nil {
+		in, out := &in.Domains, &out.Domains
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologySpreadStatus.
+func (in *TopologySpreadStatus) DeepCopy() *TopologySpreadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologySpreadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VersionDetails) DeepCopyInto(out *VersionDetails) {
 	*out = *in
@@ -371,6 +665,13 @@ func (in *VersionDetails) DeepCopy() *VersionDetails {
 func (in *VersionStatus) DeepCopyInto(out *VersionStatus) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.PreviousImages != nil {
+		in, out := &in.PreviousImages, &out.PreviousImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
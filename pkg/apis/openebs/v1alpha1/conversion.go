@@ -0,0 +1,235 @@
+/*
+Copyright © 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v1 "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// conversionDataAnnotation stores the fields of the hub version which
+// don't exist in v1alpha1, so that a round trip through v1alpha1
+// doesn't lose them.
+const conversionDataAnnotation = "openebs.io/conversion-data"
+
+// jivaVolumeData is the content of the conversion
+// data annotation of a JivaVolume
+type jivaVolumeData struct {
+	Spec           v1.JivaVolumeSpec   `json:"spec,omitempty"`
+	Status         v1.JivaVolumeStatus `json:"status,omitempty"`
+	VersionDetails v1.VersionDetails   `json:"versionDetails,omitempty"`
+}
+
+// jivaVolumePolicyData is the content of the conversion
+// data annotation of a JivaVolumePolicy
+type jivaVolumePolicyData struct {
+	Spec   v1.JivaVolumePolicySpec   `json:"spec,omitempty"`
+	Status v1.JivaVolumePolicyStatus `json:"status,omitempty"`
+}
+
+// ConvertTo converts the JivaVolume to the hub version
+func (src *JivaVolume) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1.JivaVolume)
+	if !ok {
+		return fmt.Errorf("expected a v1 JivaVolume but got a %T", dstRaw)
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if err := convertFields(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertFields(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+	if err := convertFields(&src.VersionDetails, &dst.VersionDetails); err != nil {
+		return err
+	}
+
+	data := &jivaVolumeData{}
+	ok, err := popConversionData(dst.Annotations, data)
+	if err != nil || !ok {
+		return err
+	}
+	restorePolicySpec(&dst.Spec.Policy, &data.Spec.Policy)
+	dst.Spec.MountInfo.PodUID = data.Spec.MountInfo.PodUID
+	dst.Spec.ISCSISpec.ExternalPortals = data.Spec.ISCSISpec.ExternalPortals
+
+	dst.Status.Scaleup = data.Status.Scaleup
+	dst.Status.FailedReplicas = data.Status.FailedReplicas
+	dst.Status.ReplacedReplicaCount = data.Status.ReplacedReplicaCount
+	dst.Status.PolicyGeneration = data.Status.PolicyGeneration
+	dst.Status.BasePolicyGenerations = data.Status.BasePolicyGenerations
+	dst.Status.TopologySpread = data.Status.TopologySpread
+	dst.Status.Evacuation = data.Status.Evacuation
+	dst.Status.PendingActions = data.Status.PendingActions
+	dst.Status.ConsumerNode = data.Status.ConsumerNode
+	dst.Status.ColocatedNode = data.Status.ColocatedNode
+	dst.Status.ExternalPortals = data.Status.ExternalPortals
+	for i := range dst.Status.ReplicaStatuses {
+		for _, rs := range data.Status.ReplicaStatuses {
+			if rs.Address == dst.Status.ReplicaStatuses[i].Address {
+				dst.Status.ReplicaStatuses[i].Rebuild = rs.Rebuild
+			}
+		}
+	}
+
+	dst.VersionDetails.Status.UpgradeStep = data.VersionDetails.Status.UpgradeStep
+	dst.VersionDetails.Status.PreviousImages = data.VersionDetails.Status.PreviousImages
+	return nil
+}
+
+// ConvertFrom converts the hub version to the JivaVolume
+func (dst *JivaVolume) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1.JivaVolume)
+	if !ok {
+		return fmt.Errorf("expected a v1 JivaVolume but got a %T", srcRaw)
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if err := convertFields(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertFields(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+	if err := convertFields(&src.VersionDetails, &dst.VersionDetails); err != nil {
+		return err
+	}
+	return pushConversionData(&dst.ObjectMeta.Annotations, &jivaVolumeData{
+		Spec:           src.Spec,
+		Status:         src.Status,
+		VersionDetails: src.VersionDetails,
+	})
+}
+
+// ConvertTo converts the JivaVolumePolicy to the hub version
+func (src *JivaVolumePolicy) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1.JivaVolumePolicy)
+	if !ok {
+		return fmt.Errorf("expected a v1 JivaVolumePolicy but got a %T", dstRaw)
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if err := convertFields(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertFields(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+
+	data := &jivaVolumePolicyData{}
+	ok, err := popConversionData(dst.Annotations, data)
+	if err != nil || !ok {
+		return err
+	}
+	restorePolicySpec(&dst.Spec, &data.Spec)
+
+	dst.Status.ObservedGeneration = data.Status.ObservedGeneration
+	dst.Status.Conditions = data.Status.Conditions
+	dst.Status.VolumeCount = data.Status.VolumeCount
+	dst.Status.Volumes = data.Status.Volumes
+	return nil
+}
+
+// ConvertFrom converts the hub version to the JivaVolumePolicy
+func (dst *JivaVolumePolicy) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1.JivaVolumePolicy)
+	if !ok {
+		return fmt.Errorf("expected a v1 JivaVolumePolicy but got a %T", srcRaw)
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if err := convertFields(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertFields(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+	return pushConversionData(&dst.ObjectMeta.Annotations, &jivaVolumePolicyData{
+		Spec:   src.Spec,
+		Status: src.Status,
+	})
+}
+
+// restorePolicySpec restores the fields of the policy spec which
+// don't exist in v1alpha1 from the conversion data
+func restorePolicySpec(dst, data *v1.JivaVolumePolicySpec) {
+	dst.BasePolicy = data.BasePolicy
+	dst.DataRetention = data.DataRetention
+	dst.Target.ColocateWithConsumer = data.Target.ColocateWithConsumer
+	dst.Target.QoS = data.Target.QoS
+	dst.Target.MonitorImage = data.Target.MonitorImage
+	dst.Target.ExternalService = data.Target.ExternalService
+	restorePodTemplateResources(&dst.Target.PodTemplateResources, &data.Target.PodTemplateResources)
+	restorePodTemplateResources(&dst.Replica.PodTemplateResources, &data.Replica.PodTemplateResources)
+	if data.Target.DisableMonitor != nil {
+		// v1alpha1 can't tell an explicit false from an unset field
+		dst.Target.DisableMonitor = data.Target.DisableMonitor
+	}
+	dst.Replica.TopologySpread = data.Replica.TopologySpread
+}
+
+// restorePodTemplateResources restores the fields of the pod
+// template resources which don't exist in v1alpha1
+func restorePodTemplateResources(dst, data *v1.PodTemplateResources) {
+	dst.Image = data.Image
+	dst.ImagePullPolicy = data.ImagePullPolicy
+	dst.ImagePullSecrets = data.ImagePullSecrets
+	dst.Labels = data.Labels
+	dst.Annotations = data.Annotations
+	dst.Env = data.Env
+	dst.PodSecurityContext = data.PodSecurityContext
+	dst.SecurityContext = data.SecurityContext
+}
+
+// convertFields converts the fields which are common to both the versions,
+// the types of v1alpha1 are a subset of the types of v1 with the same
+// json names, so the fields missing in the destination are dropped.
+func convertFields(src, dst interface{}) error {
+	raw, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// pushConversionData stores the data of the hub version in the annotations
+func pushConversionData(annotations *map[string]string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if *annotations == nil {
+		*annotations = map[string]string{}
+	}
+	(*annotations)[conversionDataAnnotation] = string(raw)
+	return nil
+}
+
+// popConversionData removes the conversion data from the annotations and
+// decodes it, it returns false if there was no conversion data.
+func popConversionData(annotations map[string]string, data interface{}) (bool, error) {
+	raw, ok := annotations[conversionDataAnnotation]
+	if !ok {
+		return false, nil
+	}
+	delete(annotations, conversionDataAnnotation)
+	if err := json.Unmarshal([]byte(raw), data); err != nil {
+		return false, fmt.Errorf("failed to decode %s annotation: %v", conversionDataAnnotation, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// newTestHubVolume returns a v1 JivaVolume with
+// fields which don't exist in v1alpha1
+func newTestHubVolume() *v1.JivaVolume {
+	since := metav1.Unix(1600000000, 0)
+	return &v1.JivaVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pv",
+			Namespace:   "openebs",
+			Annotations: map[string]string{"openebs.io/volume-policy": "policy"},
+		},
+		Spec: v1.JivaVolumeSpec{
+			PV:       "pv",
+			Capacity: "4Gi",
+			ISCSISpec: v1.ISCSISpec{
+				TargetIP:        "10.0.0.1",
+				TargetPort:      3260,
+				Iqn:             "iqn.2016-09.com.openebs.jiva:pv",
+				ExternalPortals: []string{"203.0.113.10:3260"},
+			},
+			MountInfo: v1.MountInfo{
+				StagingPath: "/staging",
+				FSType:      "ext4",
+				PodUID:      "pod-uid",
+			},
+			Policy: v1.JivaVolumePolicySpec{
+				BasePolicy: "base",
+				ReplicaSC:  "openebs-hostpath",
+				Target: v1.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService: &v1.ExternalServiceSpec{
+						Type:              "LoadBalancer",
+						AllowedInitiators: []string{"192.168.1.0/24"},
+					},
+				},
+				Replica: v1.ReplicaSpec{
+					TopologySpread: &v1.ReplicaTopologySpread{
+						TopologyKey: "topology.kubernetes.io/zone",
+						MaxSkew:     1,
+					},
+				},
+				DataRetention: v1.DataRetentionRetain,
+			},
+			DesiredReplicationFactor: 3,
+		},
+		Status: v1.JivaVolumeStatus{
+			Status:       "RW",
+			ReplicaCount: 2,
+			ReplicaStatuses: []v1.ReplicaStatus{
+				{
+					Address: "tcp://10.0.0.2:9502",
+					Mode:    "WO",
+					Rebuild: &v1.RebuildStatus{SyncedBytes: 1024, TotalBytes: 4096, Percentage: 25, StartTime: since},
+				},
+				{Address: "tcp://10.0.0.3:9502", Mode: "RW"},
+			},
+			Phase: v1.JivaVolumePhaseSyncing,
+			Scaleup: &v1.ReplicaScaleupStatus{
+				Desired: 3, Current: 2, LastUpdateTime: since,
+			},
+			FailedReplicas: []v1.FailedReplica{
+				{PodName: "pv-jiva-rep-2", Reason: "CrashLoopBackOff", Since: since},
+			},
+			ReplacedReplicaCount:  1,
+			PolicyGeneration:      2,
+			BasePolicyGenerations: map[string]int64{"base": 4},
+			TopologySpread: &v1.TopologySpreadStatus{
+				TopologyKey: "topology.kubernetes.io/zone",
+				Domains:     map[string]int{"zone-a": 1, "zone-b": 1},
+				Skew:        0,
+			},
+			Evacuation: &v1.ReplicaEvacuationStatus{
+				Node: "node-1", Step: v1.EvacuationStepRebuilding, StartTime: since,
+			},
+			PendingActions: []v1.PendingAction{
+				{Type: "ReplicaRebuild", Target: "pv-jiva-rep-0", Since: since},
+			},
+			ExternalPortals: []string{"203.0.113.10:3260"},
+		},
+		VersionDetails: v1.VersionDetails{
+			Desired: "3.6.0",
+			Status: v1.VersionStatus{
+				Current:        "3.5.0",
+				UpgradeStep:    v1.UpgradeStepReplicas,
+				PreviousImages: map[string]string{"jiva-replica": "openebs/jiva:3.5.0"},
+				LastUpdateTime: since,
+			},
+		},
+	}
+}
+
+func TestJivaVolumeRoundTrip(t *testing.T) {
+	hub := newTestHubVolume()
+
+	spoke := &JivaVolume{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("failed to convert from v1: %v", err)
+	}
+	if _, ok := spoke.Annotations[conversionDataAnnotation]; !ok {
+		t.Fatalf("expected %s annotation on v1alpha1 volume, got %v",
+			conversionDataAnnotation, spoke.Annotations)
+	}
+	if spoke.Spec.Policy.ReplicaSC != hub.Spec.Policy.ReplicaSC || spoke.Status.Status != hub.Status.Status {
+		t.Fatalf("expected common fields to be converted, got %+v", spoke)
+	}
+
+	got := &v1.JivaVolume{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("failed to convert to v1: %v", err)
+	}
+	if !reflect.DeepEqual(got, hub) {
+		t.Fatalf("expected round trip to keep the volume\n%+v\ngot\n%+v", hub, got)
+	}
+}
+
+func TestJivaVolumePolicyRoundTrip(t *testing.T) {
+	hub := &v1.JivaVolumePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "openebs"},
+		Spec:       newTestHubVolume().Spec.Policy,
+		Status: v1.JivaVolumePolicyStatus{
+			Phase:              "Ready",
+			ObservedGeneration: 2,
+			Conditions: []metav1.Condition{{
+				Type: "Valid", Status: metav1.ConditionTrue, Reason: "Valid",
+				LastTransitionTime: metav1.Unix(1600000000, 0),
+			}},
+			VolumeCount: 1,
+			Volumes:     []string{"pv"},
+		},
+	}
+
+	spoke := &JivaVolumePolicy{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("failed to convert from v1: %v", err)
+	}
+	got := &v1.JivaVolumePolicy{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("failed to convert to v1: %v", err)
+	}
+	// the conversion data annotation is removed
+	// leaving behind the empty annotations
+	got.Annotations = nil
+	if !reflect.DeepEqual(got, hub) {
+		t.Fatalf("expected round trip to keep the policy\n%+v\ngot\n%+v", hub, got)
+	}
+}
+
+func TestConvertToWithoutConversionData(t *testing.T) {
+	spoke := &JivaVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv", Namespace: "openebs"},
+		Spec:       JivaVolumeSpec{PV: "pv", Capacity: "4Gi"},
+	}
+	got := &v1.JivaVolume{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("failed to convert to v1: %v", err)
+	}
+	if got.Spec.PV != "pv" || got.Spec.Capacity != "4Gi" || got.Status.Scaleup != nil {
+		t.Fatalf("expected only the v1alpha1 fields to be set, got %+v", got.Spec)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openebs/jiva-operator/pkg/jiva"
+	"github.com/openebs/jiva-operator/pkg/volume"
+	"github.com/openebs/jiva-operator/version"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// DefaultAutoUpgradeInterval is the default interval at
+	// which the volumes are checked for auto upgrade
+	DefaultAutoUpgradeInterval = 5 * time.Minute
+
+	autoUpgradeReason = "AutoUpgrade"
+
+	// targetRequestTimeout bounds the stats request
+	// sent to the target of each volume
+	targetRequestTimeout = 2 * time.Second
+)
+
+// MaintenanceWindow is a daily window of time in UTC
+type MaintenanceWindow struct {
+	// Start is the start of the window from midnight
+	Start time.Duration
+	// End is the end of the window from midnight, the window
+	// spans midnight if the end is before the start
+	End time.Duration
+}
+
+// ParseMaintenanceWindow parses a window in the HH:MM-HH:MM format,
+// e.g. 22:00-04:00
+func ParseMaintenanceWindow(window string) (*MaintenanceWindow, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid maintenance window %q, expected HH:MM-HH:MM", window)
+	}
+	bounds := make([]time.Duration, 2)
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %v", window, err)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return &MaintenanceWindow{Start: bounds[0], End: bounds[1]}, nil
+}
+
+// Contains checks if the given time falls within the window
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.Start <= w.End {
+		return now >= w.Start && now < w.End
+	}
+	return now >= w.Start || now < w.End
+}
+
+// AutoUpgradeScheduler upgrades the volumes which have auto upgrade
+// enabled to the operator version. The upgrades are started in batches
+// within the maintenance window, the number of volumes upgraded at a
+// time is limited and degraded volumes are skipped.
+type AutoUpgradeScheduler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Interval is the interval at which the volumes are checked
+	Interval time.Duration
+	// MaxConcurrent is the maximum number of volumes upgraded at a time
+	MaxConcurrent int
+	// BatchSize is the maximum number of upgrades started in an interval
+	BatchSize int
+	// MaintenanceWindow if set, upgrades are started only within the window
+	MaintenanceWindow *MaintenanceWindow
+}
+
+// Start runs the scheduler till the context is cancelled, it
+// is run by the manager only on the elected leader.
+func (s *AutoUpgradeScheduler) Start(ctx context.Context) error {
+	logrus.Infof("starting auto upgrade scheduler, max concurrent upgrades: %d, batch size: %d",
+		s.MaxConcurrent, s.BatchSize)
+	wait.UntilWithContext(ctx, s.schedule, s.Interval)
+	return nil
+}
+
+func (s *AutoUpgradeScheduler) schedule(ctx context.Context) {
+	volumes := &jivaAPI.JivaVolumeList{}
+	if err := s.List(ctx, volumes); err != nil {
+		logrus.Errorf("failed to list volumes for auto upgrade: %v", err)
+		return
+	}
+
+	var (
+		pending                                  []jivaAPI.JivaVolume
+		total, upgraded, inProgress, unsupported int
+	)
+	for _, jv := range volumes.Items {
+		vd := jv.VersionDetails
+		if vd.Status.Current != vd.Desired {
+			inProgress++
+		}
+		if !vd.AutoUpgrade {
+			continue
+		}
+		total++
+		switch {
+		case vd.Status.Current == version.Version:
+			upgraded++
+		case vd.Desired == version.Version:
+		case !version.IsCurrentVersionValid(vd.Status.Current):
+			unsupported++
+		default:
+			pending = append(pending, jv)
+		}
+	}
+	autoUpgradeVolumes.WithLabelValues("upgraded").Set(float64(upgraded))
+	autoUpgradeVolumes.WithLabelValues("in_progress").Set(float64(inProgress))
+	autoUpgradeVolumes.WithLabelValues("pending").Set(float64(len(pending)))
+	autoUpgradeVolumes.WithLabelValues("unsupported").Set(float64(unsupported))
+
+	if len(pending) == 0 {
+		return
+	}
+	if s.MaintenanceWindow != nil && !s.MaintenanceWindow.Contains(time.Now()) {
+		logrus.Infof("%d volumes pending auto upgrade, waiting for the maintenance window", len(pending))
+		return
+	}
+
+	slots := s.MaxConcurrent - inProgress
+	if s.BatchSize < slots {
+		slots = s.BatchSize
+	}
+	degraded := 0
+	for i := range pending {
+		if slots <= 0 {
+			break
+		}
+		jv := &pending[i]
+		if !isVolumeHealthy(ctx, jv) {
+			degraded++
+			s.Recorder.Eventf(jv, corev1.EventTypeWarning, autoUpgradeReason,
+				"skipped auto upgrade to %s, volume is degraded", version.Version)
+			continue
+		}
+		jv.VersionDetails.Desired = version.Version
+		if err := s.Update(ctx, jv); err != nil {
+			logrus.Errorf("failed to start auto upgrade of volume %s: %v", jv.Name, err)
+			continue
+		}
+		slots--
+		s.Recorder.Eventf(jv, corev1.EventTypeNormal, autoUpgradeReason,
+			"started auto upgrade to %s, %d/%d volumes upgraded", version.Version, upgraded, total)
+		autoUpgradeStarted.Inc()
+	}
+	autoUpgradeVolumes.WithLabelValues("degraded").Set(float64(degraded))
+}
+
+// isVolumeHealthy checks if the target and all the replicas of the volume
+// are in RW mode. The status is fetched from the target, as the status of
+// the volumes pending upgrade is not updated by the operator. The request
+// is bounded by targetRequestTimeout so that an unresponsive target doesn't
+// stall the scheduling of the other volumes.
+func isVolumeHealthy(ctx context.Context, jv *jivaAPI.JivaVolume) bool {
+	if jv.Spec.ISCSISpec.TargetIP == "" {
+		return false
+	}
+	cli := jiva.NewControllerClient(jv.Spec.ISCSISpec.TargetIP + ":9501")
+	cli.SetTimeout(targetRequestTimeout)
+	stats := &volume.Stats{}
+	if err := cli.GetWithContext(ctx, "/stats", stats); err != nil {
+		logrus.Infof("failed to get stats of volume %s: %v", jv.Name, err)
+		return false
+	}
+	if stats.TargetStatus != "RW" || len(stats.Replicas) != jv.Spec.Policy.Target.ReplicationFactor {
+		return false
+	}
+	for _, rep := range stats.Replicas {
+		if rep.Mode != "RW" {
+			return false
+		}
+	}
+	return true
+}
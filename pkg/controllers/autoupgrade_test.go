@@ -0,0 +1,130 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := map[string]struct {
+		window      string
+		expectStart time.Duration
+		expectEnd   time.Duration
+		expectErr   bool
+	}{
+		"Test window within a day": {
+			window:      "01:30-04:00",
+			expectStart: time.Hour + 30*time.Minute,
+			expectEnd:   4 * time.Hour,
+		},
+		"Test window spanning midnight": {
+			window:      "22:00 - 02:15",
+			expectStart: 22 * time.Hour,
+			expectEnd:   2*time.Hour + 15*time.Minute,
+		},
+		"Test window without end": {
+			window:    "22:00",
+			expectErr: true,
+		},
+		"Test window with invalid time": {
+			window:    "22:00-25:00",
+			expectErr: true,
+		},
+		"Test window with seconds": {
+			window:    "22:00:00-23:00:00",
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			w, err := ParseMaintenanceWindow(mock.window)
+			if mock.expectErr && err == nil {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if err == nil && (w.Start != mock.expectStart || w.End != mock.expectEnd) {
+				t.Fatalf("Test %q failed: expected window %s-%s, got %s-%s",
+					name, mock.expectStart, mock.expectEnd, w.Start, w.End)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		window        string
+		time          time.Time
+		expectContain bool
+	}{
+		"Test time within the window": {
+			window:        "01:00-04:00",
+			time:          day.Add(2 * time.Hour),
+			expectContain: true,
+		},
+		"Test time at the start of the window": {
+			window:        "01:00-04:00",
+			time:          day.Add(time.Hour),
+			expectContain: true,
+		},
+		"Test time at the end of the window": {
+			window: "01:00-04:00",
+			time:   day.Add(4 * time.Hour),
+		},
+		"Test time before the window": {
+			window: "01:00-04:00",
+			time:   day.Add(30 * time.Minute),
+		},
+		"Test time before midnight in a window spanning midnight": {
+			window:        "22:00-02:00",
+			time:          day.Add(23 * time.Hour),
+			expectContain: true,
+		},
+		"Test time after midnight in a window spanning midnight": {
+			window:        "22:00-02:00",
+			time:          day.Add(time.Hour),
+			expectContain: true,
+		},
+		"Test time outside a window spanning midnight": {
+			window: "22:00-02:00",
+			time:   day.Add(12 * time.Hour),
+		},
+		"Test time in another timezone": {
+			window:        "22:00-02:00",
+			time:          day.Add(23 * time.Hour).In(time.FixedZone("IST", 5*3600+1800)),
+			expectContain: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			w, err := ParseMaintenanceWindow(mock.window)
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if got := w.Contains(mock.time); got != mock.expectContain {
+				t.Fatalf("Test %q failed: expected window %s to contain %s: %t",
+					name, mock.window, mock.time, mock.expectContain)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// volumeCache holds the details of the volumes which are expensive to
+// look up in every reconciliation, it is safe for concurrent use by the
+// reconcilers of different volumes.
+type volumeCache struct {
+	mu sync.RWMutex
+	// podIPs are the IPs of the target pods keyed by the volume
+	podIPs map[types.NamespacedName]string
+	// phases are the phases of the volumes last seen by
+	// this operator instance, for the phase duration metric
+	phases map[types.NamespacedName]phaseRecord
+}
+
+// phaseRecord is a phase of a volume and the time it was first seen
+type phaseRecord struct {
+	phase jivaAPI.JivaVolumePhase
+	since time.Time
+}
+
+func newVolumeCache() *volumeCache {
+	return &volumeCache{
+		podIPs: map[types.NamespacedName]string{},
+		phases: map[types.NamespacedName]phaseRecord{},
+	}
+}
+
+// getPodIP returns the cached IP of the target pod of the volume
+func (c *volumeCache) getPodIP(key types.NamespacedName) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ip, ok := c.podIPs[key]
+	return ip, ok
+}
+
+// setPodIP caches the IP of the target pod of the volume
+func (c *volumeCache) setPodIP(key types.NamespacedName, ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.podIPs[key] = ip
+}
+
+// deletePodIP removes the cached IP of the target pod of the volume
+func (c *volumeCache) deletePodIP(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.podIPs, key)
+}
+
+// setPhase caches the phase of the volume, if the phase has changed it
+// returns the previous phase along with the time it was first seen.
+func (c *volumeCache) setPhase(key types.NamespacedName, phase jivaAPI.JivaVolumePhase) (phaseRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.phases[key]
+	if ok && prev.phase == phase {
+		return phaseRecord{}, false
+	}
+	c.phases[key] = phaseRecord{phase: phase, since: time.Now()}
+	return prev, ok
+}
+
+// delete removes the cached details of the volume
+func (c *volumeCache) delete(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.podIPs, key)
+	delete(c.phases, key)
+}
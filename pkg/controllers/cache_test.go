@@ -0,0 +1,100 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestVolumeCacheConcurrentAccess(t *testing.T) {
+	c := newVolumeCache()
+	phases := []jivaAPI.JivaVolumePhase{
+		jivaAPI.JivaVolumePhaseSyncing,
+		jivaAPI.JivaVolumePhaseReady,
+	}
+
+	// the reconcilers of different volumes along with the
+	// reconciles of the same volume run in parallel
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		key := types.NamespacedName{Name: fmt.Sprintf("pv-%d", i%4), Namespace: testNamespace}
+		wg.Add(1)
+		go func(i int, key types.NamespacedName) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.setPodIP(key, fmt.Sprintf("10.0.0.%d", j))
+				c.getPodIP(key)
+				c.setPhase(key, phases[j%len(phases)])
+				if j%10 == 0 {
+					c.deletePodIP(key)
+				}
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		key := types.NamespacedName{Name: fmt.Sprintf("pv-%d", i), Namespace: testNamespace}
+		c.setPodIP(key, "10.0.0.1")
+		c.delete(key)
+		if ip, ok := c.getPodIP(key); ok {
+			t.Fatalf("expected pod IP of deleted volume %s to be removed, got %s", key, ip)
+		}
+		if prev, changed := c.setPhase(key, jivaAPI.JivaVolumePhaseReady); changed {
+			t.Fatalf("expected phase of deleted volume %s to be removed, got %s", key, prev.phase)
+		}
+	}
+	if len(c.podIPs) != 0 || len(c.phases) != 4 {
+		t.Fatalf("expected only the phases set after the delete to be cached, got %d pod IPs and %d phases",
+			len(c.podIPs), len(c.phases))
+	}
+}
+
+func TestControllerOptions(t *testing.T) {
+	tests := map[string]struct {
+		maxConcurrentReconciles int
+		expectMaxConcurrent     int
+	}{
+		"Test default max concurrent reconciles": {
+			expectMaxConcurrent: 1,
+		},
+		"Test negative max concurrent reconciles": {
+			maxConcurrentReconciles: -1,
+			expectMaxConcurrent:     1,
+		},
+		"Test max concurrent reconciles": {
+			maxConcurrentReconciles: 4,
+			expectMaxConcurrent:     4,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			r := &JivaVolumeReconciler{MaxConcurrentReconciles: mock.maxConcurrentReconciles}
+			if got := r.controllerOptions().MaxConcurrentReconciles; got != mock.expectMaxConcurrent {
+				t.Fatalf("Test %q failed: expected %d concurrent reconciles, got %d",
+					name, mock.expectMaxConcurrent, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	targetPlacementReason = "TargetPlacement"
+
+	// consumerNodeLabel is set on the volume by the node plugin
+	// to the node on which the volume is staged
+	consumerNodeLabel = "nodeID"
+
+	// colocationWeight is the weight of the preferred node affinity of
+	// the target to the consumer node, the highest allowed weight
+	colocationWeight = 100
+)
+
+// reconcileTargetColocation records the consumer node the target should be
+// colocated with in the status of the volume, the target deployment is
+// rolled out with the preferred node affinity by the drift repair. Moving
+// the target disrupts the I/O, so the node is changed only when the volume
+// isn't staged or within the target placement window. The node label is
+// cleared when the volume is unstaged, so the last consumer node is kept
+// in the status to move the target to once the volume is unstaged.
+func (r *JivaVolumeReconciler) reconcileTargetColocation(cr *jivaAPI.JivaVolume) error {
+	changed := false
+	if node := cr.Labels[consumerNodeLabel]; node != "" && node != cr.Status.ConsumerNode {
+		cr.Status.ConsumerNode = node
+		changed = true
+	}
+
+	desired := ""
+	if cr.Spec.Policy.Target.ColocateWithConsumer {
+		desired = cr.Status.ConsumerNode
+	}
+	if desired == cr.Status.ColocatedNode {
+		if changed {
+			return r.updateJivaVolume(cr)
+		}
+		return nil
+	}
+
+	if cr.Spec.MountInfo.StagingPath != "" &&
+		(r.TargetPlacementWindow == nil || !r.TargetPlacementWindow.Contains(time.Now())) {
+		logrus.Debugf("volume %s is staged, deferring the target placement on node %q",
+			cr.Name, desired)
+		if changed {
+			return r.updateJivaVolume(cr)
+		}
+		return nil
+	}
+
+	if desired == "" {
+		logrus.Infof("removing colocation of target of volume %s with node %s",
+			cr.Name, cr.Status.ColocatedNode)
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetPlacementReason,
+			"removing colocation of target with node %s", cr.Status.ColocatedNode)
+	} else {
+		logrus.Infof("colocating target of volume %s with consumer node %s", cr.Name, desired)
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetPlacementReason,
+			"colocating target with consumer node %s", desired)
+	}
+	cr.Status.ColocatedNode = desired
+	return r.updateJivaVolume(cr)
+}
+
+// targetAffinity returns the affinity of the target policy along with
+// the preferred node affinity to the node the target is colocated with
+func targetAffinity(cr *jivaAPI.JivaVolume) *corev1.Affinity {
+	node := cr.Status.ColocatedNode
+	if node == "" {
+		return cr.Spec.Policy.Target.Affinity
+	}
+
+	affinity := &corev1.Affinity{}
+	if cr.Spec.Policy.Target.Affinity != nil {
+		affinity = cr.Spec.Policy.Target.Affinity.DeepCopy()
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	// the node ID is the name of the node, which
+	// may not be the same as its hostname label
+	affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		corev1.PreferredSchedulingTerm{
+			Weight: colocationWeight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchFields: []corev1.NodeSelectorRequirement{{
+					Key:      "metadata.name",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{node},
+				}},
+			},
+		},
+	)
+	return affinity
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// testWindowAlways and testWindowNever are the maintenance
+	// windows which contain all the times and none of them
+	testWindowAlways = &MaintenanceWindow{Start: 0, End: 24 * time.Hour}
+	testWindowNever  = &MaintenanceWindow{Start: 0, End: 0}
+)
+
+func TestReconcileTargetColocation(t *testing.T) {
+	tests := map[string]struct {
+		consumerLabel  string
+		colocate       bool
+		staged         bool
+		window         *MaintenanceWindow
+		consumerNode   string
+		colocatedNode  string
+		expectConsumer string
+		expectNode     string
+	}{
+		"Test unstaged volume colocated with the consumer node": {
+			consumerLabel:  "node-1",
+			colocate:       true,
+			expectConsumer: "node-1",
+			expectNode:     "node-1",
+		},
+		"Test staged volume without window defers the move": {
+			consumerLabel:  "node-2",
+			colocate:       true,
+			staged:         true,
+			consumerNode:   "node-1",
+			colocatedNode:  "node-1",
+			expectConsumer: "node-2",
+			expectNode:     "node-1",
+		},
+		"Test staged volume outside the window defers the move": {
+			consumerLabel:  "node-2",
+			colocate:       true,
+			staged:         true,
+			window:         testWindowNever,
+			consumerNode:   "node-1",
+			colocatedNode:  "node-1",
+			expectConsumer: "node-2",
+			expectNode:     "node-1",
+		},
+		"Test staged volume within the window moves the target": {
+			consumerLabel:  "node-2",
+			colocate:       true,
+			staged:         true,
+			window:         testWindowAlways,
+			consumerNode:   "node-1",
+			colocatedNode:  "node-1",
+			expectConsumer: "node-2",
+			expectNode:     "node-2",
+		},
+		"Test unstaged volume moves the target to the last consumer node": {
+			colocate:       true,
+			window:         testWindowNever,
+			consumerNode:   "node-2",
+			colocatedNode:  "node-1",
+			expectConsumer: "node-2",
+			expectNode:     "node-2",
+		},
+		"Test colocation disabled removes the colocated node": {
+			consumerLabel:  "node-1",
+			consumerNode:   "node-1",
+			colocatedNode:  "node-1",
+			expectConsumer: "node-1",
+		},
+		"Test volume without consumer node": {
+			colocate: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			if mock.consumerLabel != "" {
+				cr.Labels = map[string]string{consumerNodeLabel: mock.consumerLabel}
+			}
+			if mock.staged {
+				cr.Spec.MountInfo.StagingPath = "/staging"
+			}
+			cr.Spec.Policy.Target.ColocateWithConsumer = mock.colocate
+			cr.Status.ConsumerNode = mock.consumerNode
+			cr.Status.ColocatedNode = mock.colocatedNode
+			r := newTestReconciler(t, cr)
+			r.TargetPlacementWindow = mock.window
+
+			if err := r.reconcileTargetColocation(cr); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			got := getTestVolume(t, r, cr.Name)
+			if got.Status.ConsumerNode != mock.expectConsumer {
+				t.Fatalf("Test %q failed: expected consumer node %q, got %q",
+					name, mock.expectConsumer, got.Status.ConsumerNode)
+			}
+			if got.Status.ColocatedNode != mock.expectNode {
+				t.Fatalf("Test %q failed: expected colocated node %q, got %q",
+					name, mock.expectNode, got.Status.ColocatedNode)
+			}
+		})
+	}
+}
+
+func TestTargetAffinity(t *testing.T) {
+	userAffinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      "kubernetes.io/os",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{"linux"},
+					}},
+				}},
+			},
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{
+				Weight: 10,
+				Preference: corev1.NodeSelectorTerm{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      "topology.kubernetes.io/zone",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{"zone-a"},
+					}},
+				},
+			}},
+		},
+		PodAntiAffinity: &corev1.PodAntiAffinity{},
+	}
+	colocation := corev1.PreferredSchedulingTerm{
+		Weight: colocationWeight,
+		Preference: corev1.NodeSelectorTerm{
+			MatchFields: []corev1.NodeSelectorRequirement{{
+				Key:      "metadata.name",
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"node-1"},
+			}},
+		},
+	}
+	tests := map[string]struct {
+		affinity      *corev1.Affinity
+		colocatedNode string
+		expect        *corev1.Affinity
+	}{
+		"Test target not colocated": {
+			affinity: userAffinity,
+			expect:   userAffinity,
+		},
+		"Test target not colocated without affinity": {},
+		"Test colocated target without affinity": {
+			colocatedNode: "node-1",
+			expect: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{colocation},
+				},
+			},
+		},
+		"Test colocated target with affinity": {
+			affinity:      userAffinity,
+			colocatedNode: "node-1",
+			expect: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: userAffinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+						userAffinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0],
+						colocation,
+					},
+				},
+				PodAntiAffinity: &corev1.PodAntiAffinity{},
+			},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			cr.Spec.Policy.Target.Affinity = mock.affinity.DeepCopy()
+			cr.Status.ColocatedNode = mock.colocatedNode
+
+			got := targetAffinity(cr)
+			if !reflect.DeepEqual(got, mock.expect) {
+				t.Fatalf("Test %q failed: expected affinity %+v, got %+v", name, mock.expect, got)
+			}
+			// the affinity of the policy isn't changed
+			if !reflect.DeepEqual(cr.Spec.Policy.Target.Affinity, mock.affinity) {
+				t.Fatalf("Test %q failed: expected policy affinity %+v, got %+v",
+					name, mock.affinity, cr.Spec.Policy.Target.Affinity)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const driftRepairReason = "DriftRepair"
+
+// repairMissingComponents recreates the target service, the target
+// deployment, the replica statefulset and the pod disruption budget
+// if any of them has been deleted.
+func (r *JivaVolumeReconciler) repairMissingComponents(cr *jivaAPI.JivaVolume) error {
+	if err := r.reconcileTargetService(cr); err != nil {
+		return fmt.Errorf("failed to repair target service: %v", err)
+	}
+
+	components := []struct {
+		obj    client.Object
+		name   string
+		create func(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error
+	}{
+		{&appsv1.Deployment{}, cr.Name + "-jiva-ctrl", createControllerDeployment},
+		{&appsv1.StatefulSet{}, cr.Name + "-jiva-rep", createReplicaStatefulSet},
+		{&policyv1.PodDisruptionBudget{}, cr.Name + "-pdb", createReplicaPodDisruptionBudget},
+	}
+	for _, c := range components {
+		err := r.Get(context.TODO(),
+			types.NamespacedName{Name: c.name, Namespace: cr.Namespace}, c.obj)
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if err := c.create(r, cr); err != nil {
+			return fmt.Errorf("failed to recreate %s: %v", c.name, err)
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+			driftRepairReason, "recreated deleted %s", c.name)
+	}
+	return nil
+}
+
+// reconcileTargetService repairs the target service of the volume. A
+// deleted service is recreated with the previous cluster IP if it is
+// still available, the iscsi spec of the volume is updated in case the
+// cluster IP of the service has changed.
+func (r *JivaVolumeReconciler) reconcileTargetService(cr *jivaAPI.JivaVolume) error {
+	desired, err := buildControllerService(cr)
+	if err != nil {
+		return err
+	}
+
+	instance := &corev1.Service{}
+	err = r.Get(context.TODO(),
+		types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, instance)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(cr, desired, r.Scheme); err != nil {
+			return err
+		}
+		desired.Spec.ClusterIP = cr.Spec.ISCSISpec.TargetIP
+		err = r.Create(context.TODO(), desired)
+		if err != nil && desired.Spec.ClusterIP != "" && errors.IsInvalid(err) {
+			logrus.Infof("cluster IP %s of volume %s is not available: %v",
+				desired.Spec.ClusterIP, cr.Name, err)
+			desired.Spec.ClusterIP = ""
+			err = r.Create(context.TODO(), desired)
+		}
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+			driftRepairReason, "recreated deleted %s", desired.Name)
+		instance = desired
+	} else if !equality.Semantic.DeepEqual(instance.Spec.Selector, desired.Spec.Selector) ||
+		!equality.Semantic.DeepEqual(instance.Spec.Ports, desired.Spec.Ports) {
+		instance.Spec.Selector = desired.Spec.Selector
+		instance.Spec.Ports = desired.Spec.Ports
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+			driftRepairReason, "updated selector and ports of %s", instance.Name)
+	}
+
+	if instance.Spec.ClusterIP == cr.Spec.ISCSISpec.TargetIP {
+		return nil
+	}
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, driftRepairReason,
+		"target IP changed from %s to %s", cr.Spec.ISCSISpec.TargetIP, instance.Spec.ClusterIP)
+	if err := setISCSISpec(cr, instance); err != nil {
+		return err
+	}
+	return r.updateJivaVolume(cr)
+}
+
+// reconcileDrift compares the target deployment, the replica statefulset
+// and the pod disruption budget with the desired state of the volume and
+// repairs the drift. The replicas are repaired one at a time followed by
+// the target. It returns true once there is no drift.
+func (r *JivaVolumeReconciler) reconcileDrift(cr *jivaAPI.JivaVolume) (bool, error) {
+	if err := r.repairMissingComponents(cr); err != nil {
+		return false, err
+	}
+
+	if err := r.reconcilePodDisruptionBudget(cr); err != nil {
+		return false, fmt.Errorf("failed to repair pod disruption budget: %v", err)
+	}
+
+	replicaSTS := &appsv1.StatefulSet{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace}, replicaSTS)
+	if err != nil {
+		return false, err
+	}
+	desiredSTS, err := buildReplicaStatefulSet(cr, cr.Spec.ISCSISpec.TargetIP)
+	if err != nil {
+		return false, err
+	}
+	if replicaSTS.Spec.Replicas == nil || *replicaSTS.Spec.Replicas != *desiredSTS.Spec.Replicas {
+		replicaSTS.Spec.Replicas = desiredSTS.Spec.Replicas
+		if err := r.Update(context.TODO(), replicaSTS); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, driftRepairReason,
+			"updated replicas of %s to %d", replicaSTS.Name, *desiredSTS.Spec.Replicas)
+		return false, nil
+	}
+	mutate := withDesiredPodTemplate(&desiredSTS.Spec.Template)
+	if changes := podTemplateChanges(&replicaSTS.Spec.Template, mutate); len(changes) != 0 {
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, driftRepairReason,
+			"updating %s of %s", strings.Join(changes, ", "), replicaSTS.Name)
+	}
+	done, err := r.rolloutReplicas(cr, mutate)
+	if err != nil || !done {
+		return false, err
+	}
+
+	dep := &appsv1.Deployment{}
+	err = r.Get(context.TODO(),
+		types.NamespacedName{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace}, dep)
+	if err != nil {
+		return false, err
+	}
+	desiredDep, err := buildControllerDeployment(cr)
+	if err != nil {
+		return false, err
+	}
+	mutate = withDesiredPodTemplate(&desiredDep.Spec.Template)
+	if changes := podTemplateChanges(&dep.Spec.Template, mutate); len(changes) != 0 {
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, driftRepairReason,
+			"updating %s of %s", strings.Join(changes, ", "), dep.Name)
+	}
+	return r.rolloutTarget(cr, mutate)
+}
+
+// reconcilePodDisruptionBudget repairs the selector
+// and the min available replicas of the pdb
+func (r *JivaVolumeReconciler) reconcilePodDisruptionBudget(cr *jivaAPI.JivaVolume) error {
+	desired := buildReplicaPodDisruptionBudget(cr)
+	instance := &policyv1.PodDisruptionBudget{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, instance)
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(instance.Spec.Selector, desired.Spec.Selector) &&
+		equality.Semantic.DeepEqual(instance.Spec.MinAvailable, desired.Spec.MinAvailable) {
+		return nil
+	}
+	instance.Spec.Selector = desired.Spec.Selector
+	instance.Spec.MinAvailable = desired.Spec.MinAvailable
+	instance.Spec.MaxUnavailable = nil
+	if err := r.Update(context.TODO(), instance); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, driftRepairReason,
+		"updated min available replicas of %s to %s", instance.Name, desired.Spec.MinAvailable.String())
+	return nil
+}
+
+// withDesiredPodTemplate returns a mutator which applies the policy
+// configuration along with the containers of the given pod template.
+// The image, pull policy, command, arguments, environment variables,
+// ports and security context of the containers are restored, missing
+// containers are added back and the containers which are not part of
+// the desired template are removed.
+func withDesiredPodTemplate(desired *corev1.PodTemplateSpec) podTemplateMutator {
+	applyPolicy := withPodTemplate(desired)
+	return func(template *corev1.PodTemplateSpec) {
+		applyPolicy(template)
+		containers := make([]corev1.Container, 0, len(desired.Spec.Containers))
+		for _, want := range desired.Spec.Containers {
+			c := want
+			for _, got := range template.Spec.Containers {
+				if got.Name == want.Name {
+					c = got
+					c.Image = want.Image
+					c.ImagePullPolicy = want.ImagePullPolicy
+					c.Command = want.Command
+					c.Args = want.Args
+					c.Env = want.Env
+					c.Ports = want.Ports
+					c.SecurityContext = want.SecurityContext
+				}
+			}
+			containers = append(containers, c)
+		}
+		template.Spec.Containers = containers
+	}
+}
+
+// podTemplateChanges returns the fields of the
+// pod template which are changed by mutate
+func podTemplateChanges(template *corev1.PodTemplateSpec, mutate podTemplateMutator) []string {
+	updated := template.DeepCopy()
+	mutate(updated)
+
+	changes := []string{}
+	fields := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"labels", template.Labels, updated.Labels},
+		{"annotations", template.Annotations, updated.Annotations},
+		{"security context", template.Spec.SecurityContext, updated.Spec.SecurityContext},
+		{"tolerations", template.Spec.Tolerations, updated.Spec.Tolerations},
+		{"affinity", template.Spec.Affinity, updated.Spec.Affinity},
+		{"topology spread", template.Spec.TopologySpreadConstraints, updated.Spec.TopologySpreadConstraints},
+		{"node selector", template.Spec.NodeSelector, updated.Spec.NodeSelector},
+		{"priority class", template.Spec.PriorityClassName, updated.Spec.PriorityClassName},
+		{"service account", template.Spec.ServiceAccountName, updated.Spec.ServiceAccountName},
+		{"image pull secrets", template.Spec.ImagePullSecrets, updated.Spec.ImagePullSecrets},
+	}
+	for _, f := range fields {
+		if !equality.Semantic.DeepEqual(f.got, f.want) {
+			changes = append(changes, f.name)
+		}
+	}
+
+	if len(template.Spec.Containers) != len(updated.Spec.Containers) {
+		return append(changes, "containers")
+	}
+	for i, want := range updated.Spec.Containers {
+		got := template.Spec.Containers[i]
+		if got.Name != want.Name {
+			return append(changes, "containers")
+		}
+		fields := []struct {
+			name      string
+			got, want interface{}
+		}{
+			{"image", got.Image, want.Image},
+			{"image pull policy", got.ImagePullPolicy, want.ImagePullPolicy},
+			{"command", got.Command, want.Command},
+			{"args", got.Args, want.Args},
+			{"env", got.Env, want.Env},
+			{"ports", got.Ports, want.Ports},
+			{"resources", got.Resources, want.Resources},
+			{"security context", got.SecurityContext, want.SecurityContext},
+		}
+		for _, f := range fields {
+			if !equality.Semantic.DeepEqual(f.got, f.want) {
+				changes = append(changes, want.Name+" "+f.name)
+			}
+		}
+	}
+	return changes
+}
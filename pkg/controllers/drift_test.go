@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPodTemplate() *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"openebs.io/component": "jiva-controller"},
+		},
+		Spec: corev1.PodSpec{
+			SecurityContext:    &corev1.PodSecurityContext{},
+			ServiceAccountName: "jiva-operator",
+			Containers: []corev1.Container{
+				{
+					Name:  "jiva-controller",
+					Image: "openebs/jiva:ci",
+					Args:  []string{"controller"},
+					Env:   []corev1.EnvVar{{Name: "REPLICATION_FACTOR", Value: "3"}},
+				},
+				{
+					Name:  "maya-volume-exporter",
+					Image: "openebs/m-exporter:ci",
+				},
+			},
+		},
+	}
+}
+
+func TestPodTemplateChanges(t *testing.T) {
+	tests := map[string]struct {
+		drift         func(template *corev1.PodTemplateSpec)
+		expectChanges []string
+	}{
+		"Test template without drift": {
+			drift:         func(template *corev1.PodTemplateSpec) {},
+			expectChanges: []string{},
+		},
+		"Test changed container image": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].Image = "openebs/jiva:old"
+			},
+			expectChanges: []string{"jiva-controller image"},
+		},
+		"Test changed container env and resources": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].Env = nil
+				template.Spec.Containers[1].Resources = corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				}
+			},
+			expectChanges: []string{"jiva-controller env", "maya-volume-exporter resources"},
+		},
+		"Test removed container": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers = template.Spec.Containers[:1]
+			},
+			expectChanges: []string{"containers"},
+		},
+		"Test added container": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers = append(template.Spec.Containers, corev1.Container{Name: "debug"})
+			},
+			expectChanges: []string{"containers"},
+		},
+		"Test removed label and changed service account": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Labels = nil
+				template.Spec.ServiceAccountName = "default"
+			},
+			expectChanges: []string{"labels", "service account"},
+		},
+		"Test added tolerations and scrape annotations": {
+			drift: func(template *corev1.PodTemplateSpec) {
+				template.Annotations = defaultAnnotations()
+				template.Spec.Tolerations = []corev1.Toleration{{Key: "key", Operator: corev1.TolerationOpExists}}
+			},
+			expectChanges: []string{"annotations", "tolerations"},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			template := newTestPodTemplate()
+			mock.drift(template)
+			observed := template.DeepCopy()
+
+			changes := podTemplateChanges(template, withDesiredPodTemplate(newTestPodTemplate()))
+			if !reflect.DeepEqual(changes, mock.expectChanges) {
+				t.Fatalf("Test %q failed: expected changes %v, got %v", name, mock.expectChanges, changes)
+			}
+			if !equality.Semantic.DeepEqual(template, observed) {
+				t.Fatalf("Test %q failed: expected template not to be modified", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// evacuateNodeAnnotation if set to true on a node, the jiva
+	// replicas are moved off the node one volume at a time
+	evacuateNodeAnnotation = "openebs.io/jiva-evacuate"
+	// evacuationStatusAnnotation holds the summary of the
+	// evacuation of the node
+	evacuationStatusAnnotation = "openebs.io/jiva-evacuation-status"
+	selectedNodeAnnotation     = "volume.kubernetes.io/selected-node"
+
+	evacuationReason = "ReplicaEvacuation"
+	// evacuationPollInterval is the interval at which the
+	// progress of the evacuation of a node is checked
+	evacuationPollInterval = 30 * time.Second
+
+	evacuationPhaseInProgress = "InProgress"
+	evacuationPhaseCompleted  = "Completed"
+)
+
+// nodeEvacuationStatus is the summary of the evacuation of a node
+type nodeEvacuationStatus struct {
+	Phase     string   `json:"phase"`
+	Total     int      `json:"total"`
+	Evacuated int      `json:"evacuated"`
+	Current   string   `json:"current,omitempty"`
+	Skipped   []string `json:"skipped,omitempty"`
+}
+
+// NodeEvacuationReconciler evacuates the jiva replicas from the nodes
+// annotated for maintenance. The node is cordoned and the replicas are
+// moved one volume at a time, the evacuation of a replica is performed
+// by the JivaVolume controller.
+type NodeEvacuationReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+
+// Reconcile picks the next volume with a replica on the node once the
+// evacuation of the previous volume is complete and updates the summary
+// of the evacuation on the node.
+func (r *NodeEvacuationReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	node := &corev1.Node{}
+	if err := r.Get(ctx, request.NamespacedName, node); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if node.Annotations[evacuateNodeAnnotation] != "true" {
+		if _, ok := node.Annotations[evacuationStatusAnnotation]; !ok {
+			return reconcile.Result{}, nil
+		}
+		newNode := node.DeepCopy()
+		delete(newNode.Annotations, evacuationStatusAnnotation)
+		return reconcile.Result{}, r.Patch(ctx, newNode, client.MergeFrom(node))
+	}
+
+	if !node.Spec.Unschedulable {
+		logrus.Infof("cordoning node %s for replica evacuation", node.Name)
+		newNode := node.DeepCopy()
+		newNode.Spec.Unschedulable = true
+		if err := r.Patch(ctx, newNode, client.MergeFrom(node)); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Recorder.Event(node, corev1.EventTypeNormal, evacuationReason, "cordoned node for replica evacuation")
+		node = newNode
+	}
+
+	volumes, err := r.volumesOnNode(ctx, node.Name)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	prev := nodeEvacuationStatus{}
+	if raw, ok := node.Annotations[evacuationStatusAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &prev); err != nil {
+			logrus.Warningf("failed to parse evacuation status of node %s: %v", node.Name, err)
+		}
+	}
+
+	status := nodeEvacuationStatus{Phase: evacuationPhaseInProgress}
+	var next *jivaAPI.JivaVolume
+	remaining := 0
+	for i := range volumes {
+		jv := &volumes[i]
+		ev := jv.Status.Evacuation
+		switch {
+		case ev != nil && ev.Node == node.Name && ev.Step == jivaAPI.EvacuationStepSkipped:
+			status.Skipped = append(status.Skipped, jv.Name)
+			continue
+		case ev != nil && ev.Node == node.Name:
+			status.Current = jv.Name
+		case next == nil && ev == nil && jv.DeletionTimestamp == nil:
+			next = jv
+		}
+		remaining++
+	}
+
+	// one volume is evacuated at a time
+	if status.Current == "" && next != nil {
+		logrus.Infof("evacuating replica of volume %s from node %s", next.Name, node.Name)
+		next.Status.Evacuation = &jivaAPI.ReplicaEvacuationStatus{
+			Node:      node.Name,
+			Step:      jivaAPI.EvacuationStepPending,
+			StartTime: metav1.Now(),
+		}
+		if err := r.Update(ctx, next); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Recorder.Eventf(node, corev1.EventTypeNormal, evacuationReason,
+			"evacuating replica of volume %s", next.Name)
+		status.Current = next.Name
+	}
+
+	status.Total = prev.Total
+	if status.Total < prev.Evacuated+remaining+len(status.Skipped) {
+		status.Total = prev.Evacuated + remaining + len(status.Skipped)
+	}
+	status.Evacuated = status.Total - remaining - len(status.Skipped)
+	if remaining == 0 {
+		status.Phase = evacuationPhaseCompleted
+		if prev.Phase != evacuationPhaseCompleted {
+			r.Recorder.Eventf(node, corev1.EventTypeNormal, evacuationReason,
+				"evacuation completed, %d/%d volumes evacuated, skipped: %v",
+				status.Evacuated, status.Total, status.Skipped)
+		}
+	} else if prev.Evacuated < status.Evacuated {
+		r.Recorder.Eventf(node, corev1.EventTypeNormal, evacuationReason,
+			"%d/%d volumes evacuated", status.Evacuated, status.Total)
+	}
+
+	if err := r.setEvacuationStatus(ctx, node, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	if remaining == 0 {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: evacuationPollInterval}, nil
+}
+
+// volumesOnNode returns the volumes which have a replica PVC on
+// the node, or whose replica is being evacuated from the node.
+func (r *NodeEvacuationReconciler) volumesOnNode(ctx context.Context, nodeName string) ([]jivaAPI.JivaVolume, error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err := r.List(ctx, pvcs, client.MatchingLabels{
+		"openebs.io/cas-type":  "jiva",
+		"openebs.io/component": "jiva-replica",
+	})
+	if err != nil {
+		return nil, err
+	}
+	onNode := map[types.NamespacedName]bool{}
+	for _, pvc := range pvcs.Items {
+		if pvc.Annotations[selectedNodeAnnotation] != nodeName ||
+			pvc.Labels[retainedReplicaLabel] != "" {
+			continue
+		}
+		onNode[types.NamespacedName{
+			Name:      pvc.Labels["openebs.io/persistent-volume"],
+			Namespace: pvc.Namespace,
+		}] = true
+	}
+
+	jvs := &jivaAPI.JivaVolumeList{}
+	if err := r.List(ctx, jvs); err != nil {
+		return nil, err
+	}
+	volumes := []jivaAPI.JivaVolume{}
+	for _, jv := range jvs.Items {
+		evacuating := jv.Status.Evacuation != nil && jv.Status.Evacuation.Node == nodeName
+		if evacuating || onNode[types.NamespacedName{Name: jv.Name, Namespace: jv.Namespace}] {
+			volumes = append(volumes, jv)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+// setEvacuationStatus updates the evacuation summary on the
+// node, the node is updated only if the summary has changed
+func (r *NodeEvacuationReconciler) setEvacuationStatus(ctx context.Context, node *corev1.Node,
+	status nodeEvacuationStatus) error {
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if node.Annotations[evacuationStatusAnnotation] == string(raw) {
+		return nil
+	}
+	newNode := node.DeepCopy()
+	if newNode.Annotations == nil {
+		newNode.Annotations = map[string]string{}
+	}
+	newNode.Annotations[evacuationStatusAnnotation] = string(raw)
+	return r.Patch(ctx, newNode, client.MergeFrom(node))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeEvacuationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	annotated := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, evacuate := obj.GetAnnotations()[evacuateNodeAnnotation]
+		_, status := obj.GetAnnotations()[evacuationStatusAnnotation]
+		return evacuate || status
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nodeevacuation").
+		For(&corev1.Node{}, builder.WithPredicates(annotated)).
+		Complete(r)
+}
+
+// reconcileEvacuation evacuates the replica of the volume from the node set
+// by the node evacuation controller. An additional replica is added first,
+// as the node is cordoned it is created on another node. Once it has been
+// rebuilt, the PVC & PV of the replica on the node are removed and the pod
+// is deleted, the statefulset recreates the replica with its ordinal on
+// another node where it is rebuilt from the healthy replicas. Scaling down
+// the statefulset removes the highest ordinal, so the additional replica is
+// removed only after the replacement is in RW mode, the volume never has
+// fewer healthy replicas than its replication factor. It returns true while
+// the evacuation is in progress.
+func (r *JivaVolumeReconciler) reconcileEvacuation(cr *jivaAPI.JivaVolume) (bool, error) {
+	ev := cr.Status.Evacuation
+	if ev == nil {
+		return false, nil
+	}
+
+	node := &corev1.Node{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: ev.Node}, node)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) || node.Annotations[evacuateNodeAnnotation] != "true" {
+		switch ev.Step {
+		case jivaAPI.EvacuationStepPending, jivaAPI.EvacuationStepSkipped:
+			logrus.Infof("evacuation of node %s cancelled for volume %s", ev.Node, cr.Name)
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+				"evacuation of node %s cancelled", ev.Node)
+			cr.Status.Evacuation = nil
+			return false, r.updateJivaVolume(cr)
+		case jivaAPI.EvacuationStepAddingReplica:
+			logrus.Infof("evacuation of node %s cancelled for volume %s, removing the additional replica",
+				ev.Node, cr.Name)
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+				"evacuation of node %s cancelled, removing the additional replica", ev.Node)
+			ev.Step = jivaAPI.EvacuationStepRemovingReplica
+			ev.Message = "removing the additional replica"
+			return true, r.updateJivaVolume(cr)
+		}
+		// the replica on the node has already been
+		// removed, the evacuation is completed
+	}
+	if ev.Step == jivaAPI.EvacuationStepSkipped {
+		return false, nil
+	}
+
+	pvc, err := r.replicaClaimOnNode(cr, ev.Node)
+	if err != nil {
+		return false, err
+	}
+
+	switch ev.Step {
+	case jivaAPI.EvacuationStepPending:
+		if pvc == nil {
+			break
+		}
+		if !allReplicasRW(cr) || isScaleup(cr) {
+			return true, r.setEvacuationMessage(cr, "waiting for all the replicas to be in RW mode")
+		}
+
+		// the step is recorded before the statefulset is scaled up,
+		// so that the scaleup is resumed if the update fails
+		logrus.Infof("evacuating replica %s of volume %s from node %s, adding an additional replica",
+			pvc.Name, cr.Name, ev.Node)
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+			"adding a replica to replace replica %s on node %s", pvc.Name, ev.Node)
+		ev.ClaimName = pvc.Name
+		ev.ReplicationFactor = cr.Spec.Policy.Target.ReplicationFactor
+		ev.Step = jivaAPI.EvacuationStepAddingReplica
+		ev.Message = "rebuilding the additional replica"
+		return true, r.updateJivaVolume(cr)
+	case jivaAPI.EvacuationStepAddingReplica:
+		if cr.Spec.Policy.Target.ReplicationFactor != ev.ReplicationFactor+1 {
+			return true, r.performScaleup(cr, ev.ReplicationFactor+1)
+		}
+		if !allReplicasRW(cr) {
+			return true, r.setEvacuationMessage(cr, "rebuilding the additional replica")
+		}
+		if pvc != nil {
+			logrus.Infof("removing replica %s of volume %s from node %s", pvc.Name, cr.Name, ev.Node)
+			if err := r.removeReplicaClaim(cr, pvc); err != nil {
+				return false, err
+			}
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+				"removed replica %s from node %s, replacing it with a new replica", pvc.Name, ev.Node)
+		}
+		ev.Step = jivaAPI.EvacuationStepRebuilding
+		ev.Message = "rebuilding the replacement replica"
+		return true, r.updateJivaVolume(cr)
+	case jivaAPI.EvacuationStepRebuilding:
+		replaced, err := r.isReplicaReplaced(cr, ev)
+		if err != nil {
+			return false, err
+		}
+		if pvc != nil || !replaced || !allReplicasRW(cr) {
+			return true, r.setEvacuationMessage(cr, "rebuilding the replacement replica")
+		}
+		ev.Step = jivaAPI.EvacuationStepRemovingReplica
+		ev.Message = "removing the additional replica"
+		return true, r.updateJivaVolume(cr)
+	case jivaAPI.EvacuationStepRemovingReplica:
+		removed, err := r.removeAdditionalReplica(cr, ev)
+		if err != nil {
+			return false, err
+		}
+		if !removed || !allReplicasRW(cr) {
+			return true, r.setEvacuationMessage(cr, "removing the additional replica")
+		}
+		if pvc != nil {
+			// the evacuation was cancelled
+			// before the replica was removed
+			cr.Status.Evacuation = nil
+			return false, r.updateJivaVolume(cr)
+		}
+	}
+	if pvc != nil {
+		return true, nil
+	}
+
+	logrus.Infof("evacuated replica of volume %s from node %s", cr.Name, ev.Node)
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+		"evacuated replica from node %s", ev.Node)
+	cr.Status.Evacuation = nil
+	return false, r.updateJivaVolume(cr)
+}
+
+// removeAdditionalReplica scales the replicas back to the replication
+// factor before the evacuation and removes the PVC & PV of the additional
+// replica, so that a later scaleup doesn't reuse its stale data. It returns
+// true once the pod of the additional replica is gone.
+func (r *JivaVolumeReconciler) removeAdditionalReplica(cr *jivaAPI.JivaVolume,
+	ev *jivaAPI.ReplicaEvacuationStatus) (bool, error) {
+	if ev.ReplicationFactor == 0 {
+		return true, nil
+	}
+	if cr.Spec.Policy.Target.ReplicationFactor != ev.ReplicationFactor {
+		logrus.Infof("removing the additional replica of volume %s", cr.Name)
+		return false, r.performScaleup(cr, ev.ReplicationFactor)
+	}
+
+	podName := fmt.Sprintf("%s-jiva-rep-%d", cr.Name, ev.ReplicationFactor)
+	pods, err := r.listReplicaPods(cr)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Name == podName {
+			return false, nil
+		}
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	err = r.Get(context.TODO(), types.NamespacedName{
+		Name:      replicaVolumeName + "-" + podName,
+		Namespace: cr.Namespace,
+	}, pvc)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := r.removeSTSVolume(pvc); err != nil {
+		return false, err
+	}
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, evacuationReason,
+		"removed the additional replica %s", podName)
+	return true, nil
+}
+
+// replicaClaimOnNode returns the replica PVC of the volume
+// bound to the given node or nil if there is none
+func (r *JivaVolumeReconciler) replicaClaimOnNode(cr *jivaAPI.JivaVolume, nodeName string) (*corev1.PersistentVolumeClaim, error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err := r.List(context.TODO(), pvcs, client.InNamespace(cr.Namespace),
+		client.MatchingLabels(defaultReplicaMatchLabels(cr.Spec.PV)))
+	if err != nil {
+		return nil, err
+	}
+	for i, pvc := range pvcs.Items {
+		if pvc.Annotations[selectedNodeAnnotation] == nodeName {
+			return &pvcs.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// removeReplicaClaim removes the replica PVC & PV and deletes the
+// pod using it, so that the replica is recreated with a new PVC
+func (r *JivaVolumeReconciler) removeReplicaClaim(cr *jivaAPI.JivaVolume, pvc *corev1.PersistentVolumeClaim) error {
+	pods, err := r.listReplicaPods(cr)
+	if err != nil {
+		return err
+	}
+	for i, pod := range pods.Items {
+		for _, vol := range pod.Spec.Volumes {
+			if vol.PersistentVolumeClaim != nil && vol.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return r.replaceReplica(cr, &pods.Items[i])
+			}
+		}
+	}
+	return r.removeSTSVolume(pvc)
+}
+
+// isReplicaReplaced checks if the pod of the evacuated replica
+// has been recreated and is running on another node
+func (r *JivaVolumeReconciler) isReplicaReplaced(cr *jivaAPI.JivaVolume, ev *jivaAPI.ReplicaEvacuationStatus) (bool, error) {
+	pods, err := r.listReplicaPods(cr)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods.Items {
+		for _, vol := range pod.Spec.Volumes {
+			if vol.PersistentVolumeClaim == nil || vol.PersistentVolumeClaim.ClaimName != ev.ClaimName {
+				continue
+			}
+			return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning &&
+				pod.Spec.NodeName != ev.Node, nil
+		}
+	}
+	return false, nil
+}
+
+// setEvacuationMessage updates the evacuation progress message,
+// the object is updated only if the message has changed
+func (r *JivaVolumeReconciler) setEvacuationMessage(cr *jivaAPI.JivaVolume, msg string) error {
+	if cr.Status.Evacuation.Message == msg {
+		return nil
+	}
+	cr.Status.Evacuation.Message = msg
+	return r.updateJivaVolume(cr)
+}
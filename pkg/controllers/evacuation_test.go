@@ -0,0 +1,315 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// testReplicaCluster plays the part of the statefulset controller, the
+// scheduler and the target for the replicas of a volume. The replica pods
+// are created on the first schedulable node without a replica and a new
+// replica stays in WO mode till the next sync.
+type testReplicaCluster struct {
+	t     *testing.T
+	r     *JivaVolumeReconciler
+	name  string
+	nodes []string
+	// rebuilding are the replica pods in WO mode
+	rebuilding map[string]bool
+	syncs      int
+}
+
+func (c *testReplicaCluster) sync() {
+	c.syncs++
+	sts := &appsv1.StatefulSet{}
+	if !objectExists(c.t, c.r, c.name+"-jiva-rep", testNamespace, sts) {
+		c.t.Fatalf("replica statefulset not found")
+	}
+	replicas := int(*sts.Spec.Replicas)
+	cr := getTestVolume(c.t, c.r, c.name)
+	pods, err := c.r.listReplicaPods(cr)
+	if err != nil {
+		c.t.Fatalf("failed to list replica pods: %v", err)
+	}
+
+	existing := map[int]*corev1.Pod{}
+	used := map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		var ordinal int
+		if _, err := fmt.Sscanf(pod.Name, c.name+"-jiva-rep-%d", &ordinal); err != nil {
+			c.t.Fatalf("invalid replica pod name %s", pod.Name)
+		}
+		if ordinal >= replicas {
+			if err := c.r.Delete(context.TODO(), pod); err != nil {
+				c.t.Fatalf("failed to delete pod %s: %v", pod.Name, err)
+			}
+			continue
+		}
+		existing[ordinal] = pod
+		used[pod.Spec.NodeName] = true
+	}
+
+	rebuilding := map[string]bool{}
+	statuses := []jivaAPI.ReplicaStatus{}
+	for ordinal := 0; ordinal < replicas; ordinal++ {
+		pod, ok := existing[ordinal]
+		if !ok {
+			var pvc *corev1.PersistentVolumeClaim
+			pod, pvc = newTestReplica(cr, ordinal)
+			if objectExists(c.t, c.r, pvc.Name, pvc.Namespace, pvc) {
+				pod.Spec.NodeName = pvc.Annotations[selectedNodeAnnotation]
+			} else {
+				for _, node := range c.nodes {
+					if !used[node] {
+						pod.Spec.NodeName = node
+						break
+					}
+				}
+				if pod.Spec.NodeName == "" {
+					c.t.Fatalf("no node available for replica %s", pod.Name)
+				}
+				pvc.Annotations = map[string]string{selectedNodeAnnotation: pod.Spec.NodeName}
+				if err := c.r.Create(context.TODO(), pvc); err != nil {
+					c.t.Fatalf("failed to create pvc %s: %v", pvc.Name, err)
+				}
+			}
+			used[pod.Spec.NodeName] = true
+			pod.Status.PodIP = fmt.Sprintf("10.0.%d.%d", c.syncs, ordinal+1)
+			if err := c.r.Create(context.TODO(), pod); err != nil {
+				c.t.Fatalf("failed to create pod %s: %v", pod.Name, err)
+			}
+			rebuilding[pod.Name] = true
+		}
+		mode := "RW"
+		if rebuilding[pod.Name] {
+			mode = "WO"
+		}
+		statuses = append(statuses, jivaAPI.ReplicaStatus{
+			Address: "tcp://" + pod.Status.PodIP + ":9502",
+			Mode:    mode,
+		})
+	}
+	c.rebuilding = rebuilding
+
+	cr.Status.ReplicaStatuses = statuses
+	cr.Status.ReplicaCount = len(statuses)
+	if err := c.r.Update(context.TODO(), cr); err != nil {
+		c.t.Fatalf("failed to update volume status: %v", err)
+	}
+}
+
+// replicaNodes returns the nodes of the replica pods of the volume
+func (c *testReplicaCluster) replicaNodes() map[string]bool {
+	pods, err := c.r.listReplicaPods(getTestVolume(c.t, c.r, c.name))
+	if err != nil {
+		c.t.Fatalf("failed to list replica pods: %v", err)
+	}
+	nodes := map[string]bool{}
+	for _, pod := range pods.Items {
+		nodes[pod.Spec.NodeName] = true
+	}
+	return nodes
+}
+
+func newTestEvacuation(t *testing.T, rf int) *testReplicaCluster {
+	cr := newTestVolume("pv", rf)
+	cr.Status.Evacuation = &jivaAPI.ReplicaEvacuationStatus{
+		Node: "node-0",
+		Step: jivaAPI.EvacuationStepPending,
+	}
+	objs := []client.Object{
+		cr,
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(rf))},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name: "jiva-controller",
+						Env:  []corev1.EnvVar{{Name: "REPLICATION_FACTOR", Value: fmt.Sprint(rf)}},
+					}}},
+				},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-0",
+				Annotations: map[string]string{evacuateNodeAnnotation: "true"},
+			},
+			Spec: corev1.NodeSpec{Unschedulable: true},
+		},
+	}
+	nodes := []string{}
+	for i := 1; i <= rf+1; i++ {
+		nodes = append(nodes, fmt.Sprintf("node-%d", i))
+		objs = append(objs, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodes[i-1]}})
+	}
+	// the replicas are on node-0 to node-<rf-1>
+	for i := 0; i < rf; i++ {
+		pod, pvc := newTestReplica(cr, i)
+		pod.Spec.NodeName = fmt.Sprintf("node-%d", i)
+		pvc.Annotations = map[string]string{selectedNodeAnnotation: pod.Spec.NodeName}
+		objs = append(objs, pod, pvc)
+	}
+	return &testReplicaCluster{
+		t:     t,
+		r:     newTestReconciler(t, objs...),
+		name:  cr.Name,
+		nodes: nodes,
+	}
+}
+
+func TestReconcileEvacuation(t *testing.T) {
+	for _, rf := range []int{1, 2, 3} {
+		rf := rf
+		t.Run(fmt.Sprintf("Test evacuation of a volume with %d replicas", rf), func(t *testing.T) {
+			c := newTestEvacuation(t, rf)
+			steps := []jivaAPI.EvacuationStep{}
+			evacuating := true
+			for i := 0; i < 30 && evacuating; i++ {
+				cr := getTestVolume(t, c.r, c.name)
+				if cr.Status.Evacuation != nil &&
+					(len(steps) == 0 || steps[len(steps)-1] != cr.Status.Evacuation.Step) {
+					steps = append(steps, cr.Status.Evacuation.Step)
+				}
+				var err error
+				evacuating, err = c.r.reconcileEvacuation(cr)
+				if err != nil {
+					t.Fatalf("expected error to be nil, got: %v", err)
+				}
+				c.sync()
+
+				// the volume must never have fewer healthy
+				// replicas than its replication factor
+				healthy := 0
+				for _, rep := range getTestVolume(t, c.r, c.name).Status.ReplicaStatuses {
+					if rep.Mode == "RW" {
+						healthy++
+					}
+				}
+				if healthy < rf {
+					t.Fatalf("expected at least %d healthy replicas in step %v, got %d", rf, steps, healthy)
+				}
+			}
+			if evacuating {
+				t.Fatalf("expected evacuation to be completed, steps: %v", steps)
+			}
+
+			expectSteps := []jivaAPI.EvacuationStep{
+				jivaAPI.EvacuationStepPending,
+				jivaAPI.EvacuationStepAddingReplica,
+				jivaAPI.EvacuationStepRebuilding,
+				jivaAPI.EvacuationStepRemovingReplica,
+			}
+			if fmt.Sprint(steps) != fmt.Sprint(expectSteps) {
+				t.Fatalf("expected steps %v, got %v", expectSteps, steps)
+			}
+			cr := getTestVolume(t, c.r, c.name)
+			if cr.Status.Evacuation != nil || cr.Spec.Policy.Target.ReplicationFactor != rf {
+				t.Fatalf("expected evacuation to be cleared with replication factor %d, got %v, %d",
+					rf, cr.Status.Evacuation, cr.Spec.Policy.Target.ReplicationFactor)
+			}
+			if nodes := c.replicaNodes(); nodes["node-0"] || len(nodes) != rf {
+				t.Fatalf("expected %d replicas off node-0, got nodes %v", rf, nodes)
+			}
+			additional := fmt.Sprintf("%s-%s-jiva-rep-%d", replicaVolumeName, c.name, rf)
+			if objectExists(t, c.r, additional, testNamespace, &corev1.PersistentVolumeClaim{}) {
+				t.Fatalf("expected pvc %s of the additional replica to be removed", additional)
+			}
+		})
+	}
+}
+
+func TestReconcileEvacuationCancelled(t *testing.T) {
+	tests := map[string]struct {
+		cancelAt jivaAPI.EvacuationStep
+		// expectEvacuated is true if the replica is moved
+		// off the node though the evacuation was cancelled
+		expectEvacuated bool
+	}{
+		"Test evacuation cancelled before it started": {
+			cancelAt: jivaAPI.EvacuationStepPending,
+		},
+		"Test evacuation cancelled while adding the replica": {
+			cancelAt: jivaAPI.EvacuationStepAddingReplica,
+		},
+		"Test evacuation cancelled while rebuilding the replacement": {
+			cancelAt:        jivaAPI.EvacuationStepRebuilding,
+			expectEvacuated: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			c := newTestEvacuation(t, 3)
+			evacuating := true
+			for i := 0; i < 30 && evacuating; i++ {
+				cr := getTestVolume(t, c.r, c.name)
+				if cr.Status.Evacuation != nil && cr.Status.Evacuation.Step == mock.cancelAt {
+					node := &corev1.Node{}
+					objectExists(t, c.r, "node-0", "", node)
+					if node.Annotations[evacuateNodeAnnotation] == "true" {
+						node.Annotations = nil
+						node.Spec.Unschedulable = false
+						if err := c.r.Update(context.TODO(), node); err != nil {
+							t.Fatalf("Test %q failed: failed to update node: %v", name, err)
+						}
+					}
+				}
+				var err error
+				evacuating, err = c.r.reconcileEvacuation(cr)
+				if err != nil {
+					t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+				}
+				c.sync()
+			}
+			if evacuating {
+				t.Fatalf("Test %q failed: expected evacuation to be completed", name)
+			}
+
+			cr := getTestVolume(t, c.r, c.name)
+			if cr.Status.Evacuation != nil || cr.Spec.Policy.Target.ReplicationFactor != 3 {
+				t.Fatalf("Test %q failed: expected evacuation to be cleared with replication factor 3, got %v, %d",
+					name, cr.Status.Evacuation, cr.Spec.Policy.Target.ReplicationFactor)
+			}
+			sts := &appsv1.StatefulSet{}
+			objectExists(t, c.r, c.name+"-jiva-rep", testNamespace, sts)
+			if *sts.Spec.Replicas != 3 {
+				t.Fatalf("Test %q failed: expected 3 replicas, got %d", name, *sts.Spec.Replicas)
+			}
+			if nodes := c.replicaNodes(); nodes["node-0"] == mock.expectEvacuated || len(nodes) != 3 {
+				t.Fatalf("Test %q failed: expected replica to be evacuated: %t, got nodes %v",
+					name, mock.expectEvacuated, nodes)
+			}
+		})
+	}
+}
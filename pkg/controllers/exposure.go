@@ -0,0 +1,437 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+
+	operr "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/kubernetes/service"
+	"github.com/openebs/jiva-operator/version"
+)
+
+const (
+	targetExposureReason = "TargetExposure"
+
+	// iscsiPort is the port of the iscsi target of the controller
+	iscsiPort = 3260
+)
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
+
+// reconcileTargetExposure exposes the iscsi port of the target outside the
+// cluster through a NodePort or a LoadBalancer service as per the target
+// policy. The jiva target doesn't authenticate the initiators, so a network
+// policy allows only the initiators in the allowed CIDRs to connect to the
+// iscsi port from outside the cluster, along with the pods and the nodes
+// of the cluster. The addresses of the exposed target are recorded in the
+// iscsi spec and the status of the volume.
+func (r *JivaVolumeReconciler) reconcileTargetExposure(cr *jivaAPI.JivaVolume) error {
+	spec := cr.Spec.Policy.Target.ExternalService
+	if spec == nil {
+		if err := r.removeTargetExposure(cr); err != nil {
+			return err
+		}
+		return r.setExternalPortals(cr, nil)
+	}
+
+	// the network policy is created first so that the
+	// target is never exposed without the allow-list
+	if err := r.reconcileInitiatorsPolicy(cr, spec); err != nil {
+		return operr.Wrapf(err, "failed to reconcile network policy of target")
+	}
+	svc, err := r.reconcileExternalService(cr, spec)
+	if err != nil {
+		return operr.Wrapf(err, "failed to reconcile external service of target")
+	}
+	portals, err := r.externalPortals(cr, svc)
+	if err != nil {
+		return operr.Wrapf(err, "failed to get external portals of target")
+	}
+	return r.setExternalPortals(cr, portals)
+}
+
+func externalServiceName(cr *jivaAPI.JivaVolume) string {
+	return cr.Name + "-jiva-ctrl-ext-svc"
+}
+
+func initiatorsPolicyName(cr *jivaAPI.JivaVolume) string {
+	return cr.Name + "-jiva-ctrl-initiators"
+}
+
+func targetSelector(cr *jivaAPI.JivaVolume) map[string]string {
+	return map[string]string{
+		"openebs.io/cas-type":          "jiva",
+		"openebs.io/component":         "jiva-controller",
+		"openebs.io/persistent-volume": cr.Spec.PV,
+	}
+}
+
+func buildExternalService(cr *jivaAPI.JivaVolume, spec *jivaAPI.ExternalServiceSpec) (*corev1.Service, error) {
+	builder := service.NewBuilder().
+		WithName(externalServiceName(cr)).
+		WithLabelsNew(map[string]string{
+			"openebs.io/cas-type":          "jiva",
+			"openebs.io/component":         "jiva-controller-external-service",
+			"openebs.io/persistent-volume": cr.Spec.PV,
+			"openebs.io/version":           version.Version,
+		}).
+		WithNamespace(cr.Namespace).
+		WithType(spec.Type).
+		WithSelectorsNew(targetSelector(cr)).
+		WithPorts([]corev1.ServicePort{
+			{
+				Name:       "iscsi",
+				Port:       iscsiPort,
+				Protocol:   "TCP",
+				TargetPort: intstr.IntOrString{IntVal: iscsiPort},
+			},
+		})
+	// the builder rejects empty annotations which are optional here
+	if len(spec.Annotations) != 0 {
+		builder = builder.WithAnnotationsNew(spec.Annotations)
+	}
+	svcObj, err := builder.Build()
+	if err != nil {
+		return nil, operr.Wrapf(err, "failed to build external service object")
+	}
+	// the source IP of the initiators is preserved only if the
+	// traffic isn't forwarded to the target through other nodes,
+	// else the network policy can't tell the initiators apart
+	svcObj.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+	if spec.Type == corev1.ServiceTypeLoadBalancer {
+		svcObj.Spec.LoadBalancerSourceRanges = spec.AllowedInitiators
+	}
+	return svcObj, nil
+}
+
+func (r *JivaVolumeReconciler) reconcileExternalService(cr *jivaAPI.JivaVolume,
+	spec *jivaAPI.ExternalServiceSpec) (*corev1.Service, error) {
+	desired, err := buildExternalService(cr, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &corev1.Service{}
+	err = r.Get(context.TODO(),
+		types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, instance)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(cr, desired, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(context.TODO(), desired); err != nil {
+			return nil, err
+		}
+		logrus.Infof("exposed target of volume %s through %s service %s",
+			cr.Name, spec.Type, desired.Name)
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetExposureReason,
+			"exposed target through %s service %s", spec.Type, desired.Name)
+		return desired, nil
+	}
+
+	// keep the node port allocated to the service
+	if instance.Spec.Type != corev1.ServiceTypeClusterIP {
+		for i := range desired.Spec.Ports {
+			for _, port := range instance.Spec.Ports {
+				if port.Name == desired.Spec.Ports[i].Name {
+					desired.Spec.Ports[i].NodePort = port.NodePort
+				}
+			}
+		}
+	}
+	changed := instance.Spec.Type != desired.Spec.Type ||
+		instance.Spec.ExternalTrafficPolicy != desired.Spec.ExternalTrafficPolicy ||
+		!equality.Semantic.DeepEqual(instance.Spec.Selector, desired.Spec.Selector) ||
+		!equality.Semantic.DeepEqual(instance.Spec.Ports, desired.Spec.Ports) ||
+		!equality.Semantic.DeepEqual(instance.Spec.LoadBalancerSourceRanges, desired.Spec.LoadBalancerSourceRanges)
+	for key, value := range desired.Annotations {
+		changed = changed || instance.Annotations[key] != value
+	}
+	if !changed {
+		return instance, nil
+	}
+
+	if instance.Annotations == nil {
+		instance.Annotations = map[string]string{}
+	}
+	for key, value := range desired.Annotations {
+		instance.Annotations[key] = value
+	}
+	instance.Spec.Type = desired.Spec.Type
+	instance.Spec.ExternalTrafficPolicy = desired.Spec.ExternalTrafficPolicy
+	instance.Spec.Selector = desired.Spec.Selector
+	instance.Spec.Ports = desired.Spec.Ports
+	instance.Spec.LoadBalancerSourceRanges = desired.Spec.LoadBalancerSourceRanges
+	if err := r.Update(context.TODO(), instance); err != nil {
+		return nil, err
+	}
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetExposureReason,
+		"updated %s service %s", spec.Type, instance.Name)
+	return instance, nil
+}
+
+// buildInitiatorsPolicy returns the network policy of the target pod which
+// allows the pods and the nodes of the cluster to connect to any port of
+// the target and the allowed initiators to connect only to the iscsi port
+func buildInitiatorsPolicy(cr *jivaAPI.JivaVolume, spec *jivaAPI.ExternalServiceSpec,
+	nodeIPs []string) *networkingv1.NetworkPolicy {
+	cluster := []networkingv1.NetworkPolicyPeer{
+		{NamespaceSelector: &metav1.LabelSelector{}},
+	}
+	for _, ip := range nodeIPs {
+		cidr := ip + "/32"
+		if net.ParseIP(ip).To4() == nil {
+			cidr = ip + "/128"
+		}
+		cluster = append(cluster, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+		})
+	}
+
+	initiators := []networkingv1.NetworkPolicyPeer{}
+	for _, cidr := range spec.AllowedInitiators {
+		initiators = append(initiators, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+		})
+	}
+	protocol := corev1.ProtocolTCP
+	port := intstr.FromInt(iscsiPort)
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      initiatorsPolicyName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"openebs.io/cas-type":          "jiva",
+				"openebs.io/persistent-volume": cr.Spec.PV,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: targetSelector(cr)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: cluster},
+				{
+					From: initiators,
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &protocol, Port: &port},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *JivaVolumeReconciler) reconcileInitiatorsPolicy(cr *jivaAPI.JivaVolume,
+	spec *jivaAPI.ExternalServiceSpec) error {
+	nodeIPs, err := r.nodeIPs()
+	if err != nil {
+		return operr.Wrapf(err, "failed to list node addresses")
+	}
+	desired := buildInitiatorsPolicy(cr, spec, nodeIPs)
+
+	instance := &networkingv1.NetworkPolicy{}
+	err = r.Get(context.TODO(),
+		types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, instance)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(cr, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(context.TODO(), desired); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetExposureReason,
+			"allowed initiators %v to connect to target", spec.AllowedInitiators)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(instance.Spec, desired.Spec) {
+		return nil
+	}
+	instance.Spec = desired.Spec
+	if err := r.Update(context.TODO(), instance); err != nil {
+		return err
+	}
+	logrus.Infof("updated network policy %s of volume %s", instance.Name, cr.Name)
+	return nil
+}
+
+// nodeIPs returns the sorted internal and external
+// addresses of all the nodes of the cluster
+func (r *JivaVolumeReconciler) nodeIPs() ([]string, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(context.TODO(), nodes); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	ips := []string{}
+	for _, node := range nodes.Items {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if net.ParseIP(addr.Address) == nil || seen[addr.Address] {
+				continue
+			}
+			seen[addr.Address] = true
+			ips = append(ips, addr.Address)
+		}
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+// externalPortals returns the addresses of the target for the initiators
+// outside the cluster. The node port is reachable only on the node of the
+// target pod as the external traffic policy of the service is Local.
+func (r *JivaVolumeReconciler) externalPortals(cr *jivaAPI.JivaVolume, svc *corev1.Service) ([]string, error) {
+	portals := []string{}
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if host == "" {
+				host = ingress.Hostname
+			}
+			if host != "" {
+				portals = append(portals, net.JoinHostPort(host, strconv.Itoa(iscsiPort)))
+			}
+		}
+	case corev1.ServiceTypeNodePort:
+		var nodePort int32
+		for _, port := range svc.Spec.Ports {
+			if port.Name == "iscsi" {
+				nodePort = port.NodePort
+			}
+		}
+		host, err := r.targetNodeAddress(cr)
+		if err != nil {
+			return nil, err
+		}
+		if host != "" && nodePort != 0 {
+			portals = append(portals, net.JoinHostPort(host, strconv.Itoa(int(nodePort))))
+		}
+	}
+	sort.Strings(portals)
+	return portals, nil
+}
+
+// targetNodeAddress returns the external address, or else the internal
+// address of the node of the running target pod, if there is one
+func (r *JivaVolumeReconciler) targetNodeAddress(cr *jivaAPI.JivaVolume) (string, error) {
+	labelSelector, _ := labels.Parse(controllerComponentLabel + cr.Name)
+	pods := &corev1.PodList{}
+	err := r.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return "", err
+	}
+	nodeName := ""
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			nodeName = pod.Spec.NodeName
+		}
+	}
+	if nodeName == "" {
+		return "", nil
+	}
+
+	node := &corev1.Node{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	address := ""
+	for _, addr := range node.Status.Addresses {
+		switch addr.Type {
+		case corev1.NodeExternalIP:
+			return addr.Address, nil
+		case corev1.NodeInternalIP:
+			address = addr.Address
+		}
+	}
+	return address, nil
+}
+
+// setExternalPortals records the external portals
+// of the target in the iscsi spec and the status
+func (r *JivaVolumeReconciler) setExternalPortals(cr *jivaAPI.JivaVolume, portals []string) error {
+	if len(portals) == 0 {
+		portals = nil
+	}
+	if equality.Semantic.DeepEqual(cr.Spec.ISCSISpec.ExternalPortals, portals) &&
+		equality.Semantic.DeepEqual(cr.Status.ExternalPortals, portals) {
+		return nil
+	}
+	logrus.Infof("external portals of target of volume %s changed from %v to %v",
+		cr.Name, cr.Status.ExternalPortals, portals)
+	cr.Spec.ISCSISpec.ExternalPortals = portals
+	cr.Status.ExternalPortals = portals
+	return r.updateJivaVolume(cr)
+}
+
+// removeTargetExposure deletes the external service of the target
+// followed by the network policy once the exposure is disabled
+func (r *JivaVolumeReconciler) removeTargetExposure(cr *jivaAPI.JivaVolume) error {
+	objs := []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name: externalServiceName(cr), Namespace: cr.Namespace}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name: initiatorsPolicyName(cr), Namespace: cr.Namespace}},
+	}
+	for _, obj := range objs {
+		err := r.Get(context.TODO(),
+			types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, obj)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetExposureReason,
+			"removed %s of target exposure", obj.GetName())
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func newTestNode(name string, addresses ...corev1.NodeAddress) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Addresses: addresses},
+	}
+}
+
+// newTestTarget returns the target pod of the volume on the given node
+func newTestTarget(cr *jivaAPI.JivaVolume, nodeName string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-jiva-ctrl-0",
+			Namespace: cr.Namespace,
+			Labels:    targetSelector(cr),
+		},
+		Spec:   corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestBuildInitiatorsPolicy(t *testing.T) {
+	cr := newTestVolume("pv", 1)
+	spec := &jivaAPI.ExternalServiceSpec{
+		Type:              corev1.ServiceTypeNodePort,
+		AllowedInitiators: []string{"192.168.1.0/24", "fd00::/64"},
+	}
+	policy := buildInitiatorsPolicy(cr, spec, []string{"10.0.0.1", "fd00::1"})
+
+	if policy.Name != initiatorsPolicyName(cr) || policy.Namespace != cr.Namespace {
+		t.Fatalf("expected network policy %s/%s, got %s/%s",
+			cr.Namespace, initiatorsPolicyName(cr), policy.Namespace, policy.Name)
+	}
+	if !reflect.DeepEqual(policy.Spec.PodSelector.MatchLabels, targetSelector(cr)) {
+		t.Fatalf("expected pod selector %v, got %v",
+			targetSelector(cr), policy.Spec.PodSelector.MatchLabels)
+	}
+	if len(policy.Spec.Ingress) != 2 {
+		t.Fatalf("expected 2 ingress rules, got %d", len(policy.Spec.Ingress))
+	}
+
+	cluster := policy.Spec.Ingress[0]
+	if len(cluster.Ports) != 0 {
+		t.Fatalf("expected cluster rule to allow all the ports, got %v", cluster.Ports)
+	}
+	if len(cluster.From) != 3 || cluster.From[0].NamespaceSelector == nil {
+		t.Fatalf("expected cluster rule to allow the pods and 2 nodes, got %v", cluster.From)
+	}
+	nodeCIDRs := []string{cluster.From[1].IPBlock.CIDR, cluster.From[2].IPBlock.CIDR}
+	if !reflect.DeepEqual(nodeCIDRs, []string{"10.0.0.1/32", "fd00::1/128"}) {
+		t.Fatalf("expected node CIDRs [10.0.0.1/32 fd00::1/128], got %v", nodeCIDRs)
+	}
+
+	initiators := policy.Spec.Ingress[1]
+	cidrs := []string{}
+	for _, peer := range initiators.From {
+		if peer.IPBlock == nil {
+			t.Fatalf("expected initiators rule to allow only ip blocks, got %v", initiators.From)
+		}
+		cidrs = append(cidrs, peer.IPBlock.CIDR)
+	}
+	if !reflect.DeepEqual(cidrs, spec.AllowedInitiators) {
+		t.Fatalf("expected initiator CIDRs %v, got %v", spec.AllowedInitiators, cidrs)
+	}
+	if len(initiators.Ports) != 1 || initiators.Ports[0].Port.IntValue() != iscsiPort ||
+		*initiators.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Fatalf("expected initiators rule to allow only TCP port %d, got %v",
+			iscsiPort, initiators.Ports)
+	}
+}
+
+func TestExternalPortals(t *testing.T) {
+	nodePortSvc := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{{Name: "iscsi", Port: iscsiPort, NodePort: 30260}},
+		},
+	}
+	tests := map[string]struct {
+		svc           *corev1.Service
+		node          *corev1.Node
+		phase         corev1.PodPhase
+		expectPortals []string
+	}{
+		"Test load balancer with ip and hostname": {
+			svc: &corev1.Service{
+				Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+				Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{IP: "203.0.113.10"},
+						{Hostname: "target.example.com"},
+						{},
+					},
+				}},
+			},
+			expectPortals: []string{"203.0.113.10:3260", "target.example.com:3260"},
+		},
+		"Test load balancer without ingress": {
+			svc:           &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}},
+			expectPortals: []string{},
+		},
+		"Test node port on node with external ip": {
+			svc: nodePortSvc,
+			node: newTestNode("node-1",
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.1"}),
+			phase:         corev1.PodRunning,
+			expectPortals: []string{"203.0.113.1:30260"},
+		},
+		"Test node port on node with internal ip": {
+			svc: nodePortSvc,
+			node: newTestNode("node-1",
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}),
+			phase:         corev1.PodRunning,
+			expectPortals: []string{"10.0.0.1:30260"},
+		},
+		"Test node port without running target": {
+			svc: nodePortSvc,
+			node: newTestNode("node-1",
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}),
+			phase:         corev1.PodPending,
+			expectPortals: []string{},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			objs := []client.Object{cr}
+			if mock.node != nil {
+				objs = append(objs, mock.node, newTestTarget(cr, mock.node.Name, mock.phase))
+			}
+			r := newTestReconciler(t, objs...)
+
+			portals, err := r.externalPortals(cr, mock.svc)
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if !reflect.DeepEqual(portals, mock.expectPortals) {
+				t.Fatalf("Test %q failed: expected portals %v, got %v",
+					name, mock.expectPortals, portals)
+			}
+		})
+	}
+}
+
+func TestReconcileTargetExposure(t *testing.T) {
+	cr := newTestVolume("pv", 1)
+	cr.Spec.Policy.Target.ExternalService = &jivaAPI.ExternalServiceSpec{
+		Type:              corev1.ServiceTypeLoadBalancer,
+		AllowedInitiators: []string{"192.168.1.0/24"},
+	}
+	node := newTestNode("node-1", corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"})
+	r := newTestReconciler(t, cr, node)
+
+	if err := r.reconcileTargetExposure(cr); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	policy := &networkingv1.NetworkPolicy{}
+	if !objectExists(t, r, initiatorsPolicyName(cr), cr.Namespace, policy) {
+		t.Fatalf("expected network policy %s to be created", initiatorsPolicyName(cr))
+	}
+	if got := policy.Spec.Ingress[0].From[1].IPBlock.CIDR; got != "10.0.0.1/32" {
+		t.Fatalf("expected network policy to allow node 10.0.0.1/32, got %s", got)
+	}
+	svc := &corev1.Service{}
+	if !objectExists(t, r, externalServiceName(cr), cr.Namespace, svc) {
+		t.Fatalf("expected service %s to be created", externalServiceName(cr))
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer ||
+		svc.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal ||
+		!reflect.DeepEqual(svc.Spec.LoadBalancerSourceRanges, []string{"192.168.1.0/24"}) {
+		t.Fatalf("expected local LoadBalancer service restricted to the initiators, got %+v", svc.Spec)
+	}
+	if got := getTestVolume(t, r, cr.Name).Status.ExternalPortals; got != nil {
+		t.Fatalf("expected no portals before the load balancer is ready, got %v", got)
+	}
+
+	// the load balancer gets its address
+	svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+	if err := r.Status().Update(context.TODO(), svc); err != nil {
+		t.Fatalf("failed to update service status: %v", err)
+	}
+	cr = getTestVolume(t, r, cr.Name)
+	if err := r.reconcileTargetExposure(cr); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	cr = getTestVolume(t, r, cr.Name)
+	expectPortals := []string{"203.0.113.10:3260"}
+	if !reflect.DeepEqual(cr.Status.ExternalPortals, expectPortals) ||
+		!reflect.DeepEqual(cr.Spec.ISCSISpec.ExternalPortals, expectPortals) {
+		t.Fatalf("expected portals %v in spec and status, got %v and %v", expectPortals,
+			cr.Spec.ISCSISpec.ExternalPortals, cr.Status.ExternalPortals)
+	}
+
+	// the exposure is disabled
+	cr.Spec.Policy.Target.ExternalService = nil
+	if err := r.reconcileTargetExposure(cr); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	if objectExists(t, r, externalServiceName(cr), cr.Namespace, &corev1.Service{}) {
+		t.Fatalf("expected service %s to be removed", externalServiceName(cr))
+	}
+	if objectExists(t, r, initiatorsPolicyName(cr), cr.Namespace, &networkingv1.NetworkPolicy{}) {
+		t.Fatalf("expected network policy %s to be removed", initiatorsPolicyName(cr))
+	}
+	cr = getTestVolume(t, r, cr.Name)
+	if cr.Status.ExternalPortals != nil || cr.Spec.ISCSISpec.ExternalPortals != nil {
+		t.Fatalf("expected portals to be cleared, got %v and %v",
+			cr.Spec.ISCSISpec.ExternalPortals, cr.Status.ExternalPortals)
+	}
+}
+
+func TestRemoveTargetExposure(t *testing.T) {
+	tests := map[string]struct {
+		exposed bool
+	}{
+		"Test exposed target":     {exposed: true},
+		"Test target not exposed": {exposed: false},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			spec := &jivaAPI.ExternalServiceSpec{
+				Type:              corev1.ServiceTypeNodePort,
+				AllowedInitiators: []string{"192.168.1.0/24"},
+			}
+			objs := []client.Object{cr}
+			if mock.exposed {
+				svc, err := buildExternalService(cr, spec)
+				if err != nil {
+					t.Fatalf("Test %q failed: failed to build service: %v", name, err)
+				}
+				objs = append(objs, svc, buildInitiatorsPolicy(cr, spec, nil))
+			}
+			r := newTestReconciler(t, objs...)
+
+			if err := r.removeTargetExposure(cr); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if objectExists(t, r, externalServiceName(cr), cr.Namespace, &corev1.Service{}) {
+				t.Fatalf("Test %q failed: expected service %s to be removed",
+					name, externalServiceName(cr))
+			}
+			if objectExists(t, r, initiatorsPolicyName(cr), cr.Namespace, &networkingv1.NetworkPolicy{}) {
+				t.Fatalf("Test %q failed: expected network policy %s to be removed",
+					name, initiatorsPolicyName(cr))
+			}
+		})
+	}
+}
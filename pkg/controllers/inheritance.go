@@ -0,0 +1,288 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	operr "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// defaultPolicyAnnotation if set to "true" on a JivaVolumePolicy, the
+	// policy is used for the volumes provisioned without a policy
+	defaultPolicyAnnotation = "openebs.io/is-default-policy"
+
+	// maxPolicyDepth is the maximum length of a chain of base policies
+	maxPolicyDepth = 10
+)
+
+// getDefaultPolicy returns the JivaVolumePolicy annotated as the default
+// policy in the namespace, or nil if there is none. If more than one policy
+// is annotated the most recently created one is used.
+func getDefaultPolicy(ctx context.Context, cl client.Reader, namespace string) (*jivaAPI.JivaVolumePolicy, error) {
+	policies := &jivaAPI.JivaVolumePolicyList{}
+	if err := cl.List(ctx, policies, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var defaultPolicy *jivaAPI.JivaVolumePolicy
+	count := 0
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if policy.Annotations[defaultPolicyAnnotation] != "true" || policy.DeletionTimestamp != nil {
+			continue
+		}
+		count++
+		if defaultPolicy == nil || defaultPolicy.CreationTimestamp.Before(&policy.CreationTimestamp) {
+			defaultPolicy = policy
+		}
+	}
+	if count > 1 {
+		logrus.Warningf("%d policies are annotated as the default policy in namespace %s, using %s",
+			count, namespace, defaultPolicy.Name)
+	}
+	return defaultPolicy, nil
+}
+
+// resolvePolicySpec merges the spec of the policy over the specs of its
+// base policies. It returns the effective spec, without the defaults, and
+// the generations of the base policies keyed by their names.
+func resolvePolicySpec(ctx context.Context, cl client.Reader,
+	policy *jivaAPI.JivaVolumePolicy) (jivaAPI.JivaVolumePolicySpec, map[string]int64, error) {
+	chain := []*jivaAPI.JivaVolumePolicy{policy}
+	visited := map[string]bool{policy.Name: true}
+	current := policy
+	for current.Spec.BasePolicy != "" {
+		name := current.Spec.BasePolicy
+		if visited[name] {
+			return jivaAPI.JivaVolumePolicySpec{}, nil,
+				fmt.Errorf("policy %s has a cycle in its base policies at %s", policy.Name, name)
+		}
+		if len(chain) >= maxPolicyDepth {
+			return jivaAPI.JivaVolumePolicySpec{}, nil,
+				fmt.Errorf("policy %s has more than %d base policies", policy.Name, maxPolicyDepth-1)
+		}
+		base := &jivaAPI.JivaVolumePolicy{}
+		err := cl.Get(ctx, types.NamespacedName{Name: name, Namespace: policy.Namespace}, base)
+		if err != nil {
+			return jivaAPI.JivaVolumePolicySpec{}, nil,
+				operr.Wrapf(err, "failed to get base policy %s of policy %s", name, current.Name)
+		}
+		visited[name] = true
+		chain = append(chain, base)
+		current = base
+	}
+
+	var generations map[string]int64
+	spec := jivaAPI.JivaVolumePolicySpec{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		spec = mergePolicySpec(spec, chain[i].Spec)
+		if i > 0 {
+			if generations == nil {
+				generations = map[string]int64{}
+			}
+			generations[chain[i].Name] = chain[i].Generation
+		}
+	}
+	spec.BasePolicy = ""
+	return spec, generations, nil
+}
+
+// policyInherits returns true if the policy is the given
+// policy or inherits from it through its base policies
+func policyInherits(policy *jivaAPI.JivaVolumePolicy, name string,
+	policies map[string]*jivaAPI.JivaVolumePolicy) bool {
+	for depth := 0; policy != nil && depth < maxPolicyDepth; depth++ {
+		if policy.Name == name {
+			return true
+		}
+		policy = policies[policy.Spec.BasePolicy]
+	}
+	return false
+}
+
+// mergePolicySpec returns the base spec overridden field by field
+// by the fields which are set in the override spec
+func mergePolicySpec(base, override jivaAPI.JivaVolumePolicySpec) jivaAPI.JivaVolumePolicySpec {
+	merged := *base.DeepCopy()
+	override = *override.DeepCopy()
+
+	if override.BasePolicy != "" {
+		merged.BasePolicy = override.BasePolicy
+	}
+	if override.ReplicaSC != "" {
+		merged.ReplicaSC = override.ReplicaSC
+	}
+	if override.ServiceAccountName != "" {
+		merged.ServiceAccountName = override.ServiceAccountName
+	}
+	if override.PriorityClassName != "" {
+		merged.PriorityClassName = override.PriorityClassName
+	}
+	if override.DataRetention != "" {
+		merged.DataRetention = override.DataRetention
+	}
+
+	if override.Target.DisableMonitor != nil {
+		merged.Target.DisableMonitor = override.Target.DisableMonitor
+	}
+	if override.Target.ColocateWithConsumer {
+		merged.Target.ColocateWithConsumer = true
+	}
+	if override.Target.ReplicationFactor != 0 {
+		merged.Target.ReplicationFactor = override.Target.ReplicationFactor
+	}
+	merged.Target.PodTemplateResources = mergePodTemplateResources(
+		merged.Target.PodTemplateResources, override.Target.PodTemplateResources)
+	merged.Target.AuxResources = mergeResources(merged.Target.AuxResources, override.Target.AuxResources)
+	if override.Target.QoS != nil {
+		merged.Target.QoS = override.Target.QoS
+	}
+	if override.Target.MonitorImage != "" {
+		merged.Target.MonitorImage = override.Target.MonitorImage
+	}
+	if override.Target.ExternalService != nil {
+		merged.Target.ExternalService = override.Target.ExternalService
+	}
+
+	merged.Replica.PodTemplateResources = mergePodTemplateResources(
+		merged.Replica.PodTemplateResources, override.Replica.PodTemplateResources)
+	if override.Replica.TopologySpread != nil {
+		merged.Replica.TopologySpread = override.Replica.TopologySpread
+	}
+	return merged
+}
+
+// mergePodTemplateResources merges the resources per resource name, the
+// tolerations per taint, the affinity per type, the node selector, the
+// labels and the annotations per key and the env per variable name, the
+// override wins for the same key. The image pull secrets and the security
+// contexts of the override replace the inherited ones.
+func mergePodTemplateResources(base, override jivaAPI.PodTemplateResources) jivaAPI.PodTemplateResources {
+	merged := base
+	merged.Resources = mergeResources(base.Resources, override.Resources)
+	merged.Tolerations = mergeTolerations(base.Tolerations, override.Tolerations)
+	if len(merged.Tolerations) == 0 {
+		merged.Tolerations = nil
+	}
+
+	if override.Affinity != nil {
+		if merged.Affinity == nil {
+			merged.Affinity = &corev1.Affinity{}
+		}
+		if override.Affinity.NodeAffinity != nil {
+			merged.Affinity.NodeAffinity = override.Affinity.NodeAffinity
+		}
+		if override.Affinity.PodAffinity != nil {
+			merged.Affinity.PodAffinity = override.Affinity.PodAffinity
+		}
+		if override.Affinity.PodAntiAffinity != nil {
+			merged.Affinity.PodAntiAffinity = override.Affinity.PodAntiAffinity
+		}
+	}
+
+	merged.NodeSelector = mergeStringMap(base.NodeSelector, override.NodeSelector)
+	merged.Labels = mergeStringMap(base.Labels, override.Labels)
+	merged.Annotations = mergeStringMap(base.Annotations, override.Annotations)
+	merged.Env = mergeEnv(base.Env, override.Env)
+
+	if override.Image != "" {
+		merged.Image = override.Image
+	}
+	if override.ImagePullPolicy != "" {
+		merged.ImagePullPolicy = override.ImagePullPolicy
+	}
+	if len(override.ImagePullSecrets) != 0 {
+		merged.ImagePullSecrets = override.ImagePullSecrets
+	}
+	if override.PodSecurityContext != nil {
+		merged.PodSecurityContext = override.PodSecurityContext
+	}
+	if override.SecurityContext != nil {
+		merged.SecurityContext = override.SecurityContext
+	}
+	return merged
+}
+
+// mergeStringMap returns the base map overridden per key, the base
+// map is returned as it is if the override map is empty
+func mergeStringMap(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := map[string]string{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeEnv drops the base env variables which are
+// overridden and appends the override env variables
+func mergeEnv(base, override []corev1.EnvVar) []corev1.EnvVar {
+	if len(override) == 0 {
+		return base
+	}
+	merged := []corev1.EnvVar{}
+	for _, env := range base {
+		overridden := false
+		for _, o := range override {
+			overridden = overridden || o.Name == env.Name
+		}
+		if !overridden {
+			merged = append(merged, env)
+		}
+	}
+	return append(merged, override...)
+}
+
+func mergeResources(base, override *corev1.ResourceRequirements) *corev1.ResourceRequirements {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		return override
+	}
+	return &corev1.ResourceRequirements{
+		Requests: mergeResourceList(base.Requests, override.Requests),
+		Limits:   mergeResourceList(base.Limits, override.Limits),
+	}
+}
+
+func mergeResourceList(base, override corev1.ResourceList) corev1.ResourceList {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := corev1.ResourceList{}
+	for name, q := range base {
+		merged[name] = q
+	}
+	for name, q := range override {
+		merged[name] = q
+	}
+	return merged
+}
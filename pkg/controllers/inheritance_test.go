@@ -0,0 +1,364 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestMergePolicySpec(t *testing.T) {
+	tests := map[string]struct {
+		base     jivaAPI.JivaVolumePolicySpec
+		override jivaAPI.JivaVolumePolicySpec
+		expect   jivaAPI.JivaVolumePolicySpec
+	}{
+		"Test empty override": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+			},
+		},
+		"Test override of set fields": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC:         "openebs-hostpath",
+				PriorityClassName: "low",
+				Target:            jivaAPI.TargetSpec{ReplicationFactor: 3},
+			},
+			override: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-device",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 1},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC:         "openebs-device",
+				PriorityClassName: "low",
+				Target:            jivaAPI.TargetSpec{ReplicationFactor: 1},
+			},
+		},
+		"Test merge of maps per key": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						NodeSelector: map[string]string{"zone": "a", "disk": "ssd"},
+					},
+				},
+			},
+			override: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						NodeSelector: map[string]string{"zone": "b"},
+					},
+				},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						NodeSelector: map[string]string{"zone": "b", "disk": "ssd"},
+					},
+				},
+			},
+		},
+		"Test merge of env per variable name": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Env: []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "1"}},
+					},
+				},
+			},
+			override: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Env: []corev1.EnvVar{{Name: "B", Value: "2"}},
+					},
+				},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Env: []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+					},
+				},
+			},
+		},
+		"Test merge of resources per resource name": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Resources: &corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("100m"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+						},
+					},
+				},
+			},
+			override: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Resources: &corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+							Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+						},
+					},
+				},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Resources: &corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("200m"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+							Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+						},
+					},
+				},
+			},
+		},
+		"Test override of tolerations of the same taint": {
+			base: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Tolerations: []corev1.Toleration{
+							{Key: "a", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+							{Key: "b", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			},
+			override: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Tolerations: []corev1.Toleration{
+							{Key: "b", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			},
+			expect: jivaAPI.JivaVolumePolicySpec{
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Tolerations: []corev1.Toleration{
+							{Key: "a", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+							{Key: "b", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			base := *mock.base.DeepCopy()
+			got := mergePolicySpec(mock.base, mock.override)
+			if !reflect.DeepEqual(got, mock.expect) {
+				t.Fatalf("Test %q failed: expected spec %+v, got %+v", name, mock.expect, got)
+			}
+			if !reflect.DeepEqual(base, mock.base) {
+				t.Fatalf("Test %q failed: expected base spec not to be modified", name)
+			}
+		})
+	}
+}
+
+// newTestPolicy returns a policy with the given base policy
+func newTestPolicy(name, base string, generation int64) *jivaAPI.JivaVolumePolicy {
+	return &jivaAPI.JivaVolumePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  testNamespace,
+			Generation: generation,
+		},
+		Spec: jivaAPI.JivaVolumePolicySpec{BasePolicy: base},
+	}
+}
+
+// newTestPolicyChain returns the policies policy-0 to policy-<n-1>,
+// where each policy has the next one as its base policy
+func newTestPolicyChain(n int) []client.Object {
+	policies := []client.Object{}
+	for i := 0; i < n; i++ {
+		base := ""
+		if i < n-1 {
+			base = fmt.Sprintf("policy-%d", i+1)
+		}
+		policies = append(policies, newTestPolicy(fmt.Sprintf("policy-%d", i), base, int64(i+1)))
+	}
+	return policies
+}
+
+func TestResolvePolicySpec(t *testing.T) {
+	tests := map[string]struct {
+		policies          func() []client.Object
+		expectSpec        jivaAPI.JivaVolumePolicySpec
+		expectGenerations map[string]int64
+		expectErr         bool
+	}{
+		"Test policy without base policy": {
+			policies: func() []client.Object {
+				policy := newTestPolicy("policy-0", "", 1)
+				policy.Spec.ReplicaSC = "openebs-hostpath"
+				return []client.Object{policy}
+			},
+			expectSpec: jivaAPI.JivaVolumePolicySpec{ReplicaSC: "openebs-hostpath"},
+		},
+		"Test policy with a chain of base policies": {
+			policies: func() []client.Object {
+				policy := newTestPolicy("policy-0", "policy-1", 1)
+				policy.Spec.Target.ReplicationFactor = 1
+				base := newTestPolicy("policy-1", "policy-2", 2)
+				base.Spec.Target.ReplicationFactor = 2
+				base.Spec.PriorityClassName = "high"
+				root := newTestPolicy("policy-2", "", 3)
+				root.Spec.Target.ReplicationFactor = 3
+				root.Spec.PriorityClassName = "low"
+				root.Spec.ReplicaSC = "openebs-hostpath"
+				return []client.Object{policy, base, root}
+			},
+			expectSpec: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC:         "openebs-hostpath",
+				PriorityClassName: "high",
+				Target:            jivaAPI.TargetSpec{ReplicationFactor: 1},
+			},
+			expectGenerations: map[string]int64{"policy-1": 2, "policy-2": 3},
+		},
+		"Test policy with the maximum number of base policies": {
+			policies: func() []client.Object {
+				return newTestPolicyChain(maxPolicyDepth)
+			},
+			expectGenerations: func() map[string]int64 {
+				generations := map[string]int64{}
+				for i := 1; i < maxPolicyDepth; i++ {
+					generations[fmt.Sprintf("policy-%d", i)] = int64(i + 1)
+				}
+				return generations
+			}(),
+		},
+		"Test policy with too many base policies": {
+			policies: func() []client.Object {
+				return newTestPolicyChain(maxPolicyDepth + 1)
+			},
+			expectErr: true,
+		},
+		"Test policy with a cycle in its base policies": {
+			policies: func() []client.Object {
+				return []client.Object{
+					newTestPolicy("policy-0", "policy-1", 1),
+					newTestPolicy("policy-1", "policy-2", 1),
+					newTestPolicy("policy-2", "policy-1", 1),
+				}
+			},
+			expectErr: true,
+		},
+		"Test policy with a missing base policy": {
+			policies: func() []client.Object {
+				return []client.Object{newTestPolicy("policy-0", "policy-1", 1)}
+			},
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			policies := mock.policies()
+			r := newTestReconciler(t, policies...)
+			policy := policies[0].(*jivaAPI.JivaVolumePolicy)
+
+			spec, generations, err := resolvePolicySpec(context.TODO(), r, policy)
+			if mock.expectErr {
+				if err == nil {
+					t.Fatalf("Test %q failed: expected error not to be nil", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if !reflect.DeepEqual(spec, mock.expectSpec) {
+				t.Fatalf("Test %q failed: expected spec %+v, got %+v", name, mock.expectSpec, spec)
+			}
+			if !reflect.DeepEqual(generations, mock.expectGenerations) {
+				t.Fatalf("Test %q failed: expected generations %v, got %v", name, mock.expectGenerations, generations)
+			}
+		})
+	}
+}
+
+func TestPolicyInherits(t *testing.T) {
+	policies := map[string]*jivaAPI.JivaVolumePolicy{
+		"policy-0": newTestPolicy("policy-0", "policy-1", 1),
+		"policy-1": newTestPolicy("policy-1", "", 1),
+		"policy-2": newTestPolicy("policy-2", "", 1),
+		"cycle-0":  newTestPolicy("cycle-0", "cycle-1", 1),
+		"cycle-1":  newTestPolicy("cycle-1", "cycle-0", 1),
+	}
+	tests := map[string]struct {
+		policy        string
+		base          string
+		expectInherit bool
+	}{
+		"Test policy itself": {
+			policy:        "policy-1",
+			base:          "policy-1",
+			expectInherit: true,
+		},
+		"Test policy derived from the base policy": {
+			policy:        "policy-0",
+			base:          "policy-1",
+			expectInherit: true,
+		},
+		"Test base policy of the policy": {
+			policy: "policy-1",
+			base:   "policy-0",
+		},
+		"Test unrelated policy": {
+			policy: "policy-2",
+			base:   "policy-1",
+		},
+		"Test policy with a cycle in its base policies": {
+			policy: "cycle-0",
+			base:   "policy-1",
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			got := policyInherits(policies[mock.policy], mock.base, policies)
+			if got != mock.expectInherit {
+				t.Fatalf("Test %q failed: expected %s to inherit from %s: %t",
+					name, mock.policy, mock.base, mock.expectInherit)
+			}
+		})
+	}
+}
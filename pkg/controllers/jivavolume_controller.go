@@ -24,8 +24,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/docker/go-units"
-
 	"github.com/openebs/jiva-operator/pkg/jiva"
 	"github.com/openebs/jiva-operator/pkg/kubernetes/container"
 	deploy "github.com/openebs/jiva-operator/pkg/kubernetes/deployment"
@@ -33,12 +31,15 @@ import (
 	"github.com/openebs/jiva-operator/pkg/kubernetes/pvc"
 	svc "github.com/openebs/jiva-operator/pkg/kubernetes/service"
 	sts "github.com/openebs/jiva-operator/pkg/kubernetes/statefulset"
+	"github.com/openebs/jiva-operator/pkg/utils"
 	"github.com/openebs/jiva-operator/pkg/volume"
 	"github.com/openebs/jiva-operator/version"
 	operr "github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -49,9 +50,14 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
@@ -62,6 +68,20 @@ type JivaVolumeReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// ReplicaFailureGracePeriod is the duration for which a replica is
+	// allowed to stay unhealthy before it is replaced
+	ReplicaFailureGracePeriod time.Duration
+	// TargetFailoverGracePeriod is the duration for which the node of the
+	// target pod is allowed to stay NotReady before the pod is force deleted
+	TargetFailoverGracePeriod time.Duration
+	// TargetPlacementWindow if set, a colocated target of a staged
+	// volume is moved to the consumer node only within the window
+	TargetPlacementWindow *MaintenanceWindow
+	// MaxConcurrentReconciles is the maximum number of
+	// volumes reconciled in parallel, defaults to 1
+	MaxConcurrentReconciles int
+
+	cache *volumeCache
 }
 
 type upgradeParams struct {
@@ -72,9 +92,9 @@ type upgradeParams struct {
 type upgradeFunc func(u *upgradeParams) (*jivaAPI.JivaVolume, error)
 
 var (
-	upgradeMap  = map[string]upgradeFunc{}
-	podIPMap    = map[string]string{}
-	selectorMap = map[string]string{}
+	// upgradeMap has the version specific changes to the volume, keyed by
+	// the current version, which are applied before the images are upgraded
+	upgradeMap = map[string]upgradeFunc{}
 )
 
 const (
@@ -82,8 +102,9 @@ const (
 	defaultStorageClass      = "openebs-hostpath"
 	replicaAntiAffinityKey   = "openebs.io/replica-anti-affinity"
 	defaultReplicationFactor = 3
-	defaultDisableMonitor    = false
+	defaultDisableMonitor    = true
 	openebsPVC               = "openebs.io/persistent-volume-claim"
+	replicaComponentSelector = "openebs.io/component=jiva-replica,openebs.io/persistent-volume="
 )
 
 type policyOptFuncs func(*jivaAPI.JivaVolumePolicySpec, jivaAPI.JivaVolumePolicySpec)
@@ -105,6 +126,8 @@ var (
 // +kubebuilder:rbac:groups=openebs.io.openebs.io,resources=jivavolumes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=openebs.io.openebs.io,resources=jivavolumes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=openebs.io.openebs.io,resources=jivavolumes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=openebs.io.openebs.io,resources=jivavolumepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -113,41 +136,70 @@ var (
 // perform operations to make the cluster state reflect the state specified by
 // the user.
 //
+// The reconciliation never blocks waiting for the volume components, the
+// actions waiting on them are recorded in the status of the volume and the
+// volume is requeued till they are completed.
+//
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.0/pkg/reconcile
-func (r *JivaVolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *JivaVolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 
 	// Fetch the JivaVolume instance
 	instance := &jivaAPI.JivaVolume{}
-	err := r.Get(context.TODO(), req.NamespacedName, instance)
+	err = r.Get(context.TODO(), req.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Owned objects are automatically garbage collected, the replica volumes
+			// are removed by the teardown before the finalizer is released.
 			// Return and don't requeue
+			r.cache.delete(req.NamespacedName)
+			deleteVolumeMetrics(req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	err = r.reconcileVersion(instance)
+	defer func() {
+		if err == nil && result.IsZero() {
+			result = pendingActionsResult(instance)
+		}
+		r.recordVolumeMetrics(instance)
+	}()
+
+	if instance.DeletionTimestamp != nil {
+		return reconcile.Result{}, r.teardownJiva(instance)
+	}
+	if err := r.ensureFinalizer(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	upgrading, err := r.reconcileVersion(ctx, instance)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if upgrading {
+		// the volume version is updated once the
+		// upgrade of the data plane is complete
+		return reconcile.Result{RequeueAfter: upgradePollInterval}, nil
+	}
 
 	ok, err := r.shouldReconcile(instance)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	if _, ok := podIPMap[instance.Name]; !ok {
-		err = r.updatePodIPMap(instance)
-		if err != nil {
-			// log err only, as controller must be in container creating state
-			// don't return err as it will dump stack trace unneccesary
-			logrus.Infof("not able to get controller pod ip for volume %s: %s", instance.Name, err.Error())
-			time.Sleep(1 * time.Second)
+	if err := r.reconcileTargetFailover(instance); err != nil {
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+			targetFailoverReason, "failed to fail over target pod, due to error: %v", err)
+		return reconcile.Result{}, fmt.Errorf("failed to fail over target of volume %s: %s",
+			instance.Name, err.Error())
+	}
+
+	if _, ok := r.cache.getPodIP(req.NamespacedName); !ok {
+		if err := r.reconcileTargetPod(instance); err != nil {
+			return reconcile.Result{}, err
 		}
 	}
 
@@ -159,20 +211,19 @@ func (r *JivaVolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	case jivaAPI.JivaVolumePhaseReady:
 		// fetching the latest status before performing
 		// other operations
-		err = r.getAndUpdateVolumeStatus(instance)
+		err = r.getAndUpdateVolumeStatus(ctx, instance)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
-		if r.isScaleup(instance) {
-			logrus.Info("performing scaleup operation on " + instance.Name)
-			err = r.performScaleup(instance)
+		if isScaleup(instance) {
+			err = r.reconcileScaleup(instance)
 			if err != nil {
 				r.Recorder.Eventf(instance, corev1.EventTypeWarning,
 					"ReplicaScaleup", "failed to scaleup volume, due to error: %v", err)
 				return reconcile.Result{}, fmt.Errorf("failed to scaleup volume %s: %s",
 					instance.Name, err.Error())
 			}
-			return reconcile.Result{}, r.getAndUpdateVolumeStatus(instance)
+			return reconcile.Result{}, nil
 		}
 		if err := r.moveReplicasForMissingNodes(instance); err != nil {
 			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
@@ -180,12 +231,67 @@ func (r *JivaVolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return reconcile.Result{}, fmt.Errorf("failed to move replica %s: %s",
 				instance.Name, err.Error())
 		}
+		if err := r.replaceFailedReplicas(instance); err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				"ReplicaReplacement", "failed to replace replica, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to replace replica %s: %s",
+				instance.Name, err.Error())
+		}
+		evacuating, err := r.reconcileEvacuation(instance)
+		if err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				evacuationReason, "failed to evacuate replica, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to evacuate replica of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		if evacuating {
+			return reconcile.Result{}, nil
+		}
+		if err := r.reconcileTopologySpread(instance); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to update topology spread of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		if err := r.reconcileTargetColocation(instance); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to update target placement of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		if err := r.reconcileTargetExposure(instance); err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				targetExposureReason, "failed to expose target, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to update target exposure of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		applied, err := r.reconcilePolicy(instance)
+		if err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				"PolicyUpdate", "failed to apply volume policy, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to apply policy to volume %s: %s",
+				instance.Name, err.Error())
+		}
+		if !applied {
+			return reconcile.Result{}, nil
+		}
+		repaired, err := r.reconcileDrift(instance)
+		if err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				driftRepairReason, "failed to repair volume components, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to repair components of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		if !repaired {
+			logrus.Debugf("waiting for the components of volume %s to be repaired", instance.Name)
+		}
 		return reconcile.Result{}, nil
 	case jivaAPI.JivaVolumePhaseSyncing, jivaAPI.JivaVolumePhaseUnkown:
-		return reconcile.Result{}, r.getAndUpdateVolumeStatus(instance)
+		if err := r.repairMissingComponents(instance); err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning,
+				driftRepairReason, "failed to repair volume components, due to error: %v", err)
+			return reconcile.Result{}, fmt.Errorf("failed to repair components of volume %s: %s",
+				instance.Name, err.Error())
+		}
+		return reconcile.Result{}, r.getAndUpdateVolumeStatus(ctx, instance)
 	case jivaAPI.JivaVolumePhaseDeleting:
-		logrus.Info("start tearing down jiva components", "JivaVolume: ", instance.Name)
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, r.teardownJiva(instance)
 	case "", jivaAPI.JivaVolumePhasePending, jivaAPI.JivaVolumePhaseFailed:
 		if ok {
 			logrus.Info("start bootstraping jiva components", "JivaVolume: ", instance.Name)
@@ -229,8 +335,28 @@ func (r *JivaVolumeReconciler) updatePodIPMap(cr *jivaAPI.JivaVolume) error {
 	if len(runningPodIPs) != 1 {
 		return fmt.Errorf("expected 1 controller pod got %d", len(pods.Items))
 	}
-	podIPMap[cr.Name] = runningPodIPs[0]
+	r.cache.setPodIP(types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, runningPodIPs[0])
+
+	return nil
+}
 
+// reconcileTargetPod caches the IP of the target pod, if the pod is not
+// running yet it is recorded as a pending action of the volume.
+func (r *JivaVolumeReconciler) reconcileTargetPod(cr *jivaAPI.JivaVolume) error {
+	name := cr.Name + "-jiva-ctrl"
+	if err := r.updatePodIPMap(cr); err != nil {
+		// log err only, as controller must be in container creating state
+		// don't return err as it will dump stack trace unneccesary
+		logrus.Infof("not able to get controller pod ip for volume %s: %s", cr.Name, err.Error())
+		if addPendingAction(cr, pendingTargetPod, name, targetPodPendingMsg) {
+			return r.updateJivaVolume(cr)
+		}
+		return nil
+	}
+	completed := r.completeTargetFailover(cr)
+	if removePendingAction(cr, pendingTargetPod, name) || completed {
+		return r.updateJivaVolume(cr)
+	}
 	return nil
 }
 
@@ -245,35 +371,109 @@ func isNodeReady(node *corev1.Node) bool {
 	return false
 }
 
-func (r *JivaVolumeReconciler) isScaleup(cr *jivaAPI.JivaVolume) bool {
-	if cr.Spec.DesiredReplicationFactor > cr.Spec.Policy.Target.ReplicationFactor {
-		if cr.Spec.Policy.Target.ReplicationFactor != cr.Status.ReplicaCount {
-			r.Recorder.Eventf(cr, corev1.EventTypeWarning,
-				"ReplicaScaleup", "failed to scaleup volume, replica count: %v in status not equal to replicationfactor: %v",
-				cr.Status.ReplicaCount, cr.Spec.Policy.Target.ReplicationFactor)
-			logrus.Errorf("failed to scaleup, replica count: %v in status not equal to replicationfactor: %v",
-				cr.Status.ReplicaCount, cr.Spec.Policy.Target.ReplicationFactor)
+// isScaleup checks if a replica scaleup has been requested
+// or is still in progress for the volume
+func isScaleup(cr *jivaAPI.JivaVolume) bool {
+	return cr.Spec.DesiredReplicationFactor > cr.Spec.Policy.Target.ReplicationFactor ||
+		cr.Status.Scaleup != nil
+}
+
+// allReplicasRW checks if all the replicas as per the replication
+// factor are connected to the target and are in RW mode
+func allReplicasRW(cr *jivaAPI.JivaVolume) bool {
+	if cr.Status.ReplicaCount != cr.Spec.Policy.Target.ReplicationFactor {
+		return false
+	}
+	for _, rep := range cr.Status.ReplicaStatuses {
+		if rep.Mode != "RW" {
 			return false
 		}
-		for _, rep := range cr.Status.ReplicaStatuses {
-			if rep.Mode != "RW" {
-				r.Recorder.Eventf(cr, corev1.EventTypeWarning,
-					"ReplicaScaleup", "failed to scaleup volume, all replicas for volume %v should be in RW state", cr.Name)
-				logrus.Errorf("failed to scaleup, all replicas for volume %v should be in RW state", cr.Name)
-				return false
-			}
+	}
+	return true
+}
+
+// reconcileScaleup adds the replicas one at a time till the desired
+// replication factor is reached. A new replica is added only after all
+// the existing replicas are in RW mode, i.e. the previously added replica
+// has been rebuilt. The progress is derived from the spec and the replica
+// statefulset, so the scaleup is resumed across operator restarts.
+func (r *JivaVolumeReconciler) reconcileScaleup(cr *jivaAPI.JivaVolume) error {
+	rf := cr.Spec.Policy.Target.ReplicationFactor
+	desired := cr.Spec.DesiredReplicationFactor
+
+	if desired <= rf {
+		if !allReplicasRW(cr) {
+			return r.setScaleupStatus(cr,
+				fmt.Sprintf("adding replica %d/%d, rebuilding", rf, cr.Status.Scaleup.Desired))
 		}
-		if cr.Spec.DesiredReplicationFactor-cr.Spec.Policy.Target.ReplicationFactor != 1 {
-			r.Recorder.Eventf(cr, corev1.EventTypeWarning,
-				"ReplicaScaleup", "failed to scaleup volume, only single replica scaleup is allowed, desired: %v actual: %v",
-				cr.Spec.DesiredReplicationFactor, cr.Spec.Policy.Target.ReplicationFactor)
-			logrus.Errorf("failed to scaleup, only single replica scaleup is allowed, desired: %v actual: %v",
-				cr.Spec.DesiredReplicationFactor, cr.Spec.Policy.Target.ReplicationFactor)
-			return false
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+			"ReplicaScaleup", "scaleup to %d replicas completed", rf)
+		logrus.Infof("scaleup of volume %s to %d replicas completed", cr.Name, rf)
+		cr.Status.Scaleup = nil
+		return r.updateJivaVolume(cr)
+	}
+
+	replicaSTS := &appsv1.StatefulSet{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace}, replicaSTS)
+	if err != nil {
+		return err
+	}
+
+	// a previous step has updated the replica sts but failed
+	// before updating the JivaVolume, complete that step first
+	if replicaSTS.Spec.Replicas != nil && int(*replicaSTS.Spec.Replicas) > rf {
+		next := int(*replicaSTS.Spec.Replicas)
+		logrus.Infof("resuming scaleup of volume %s to %d replicas", cr.Name, next)
+		r.updateScaleupStatus(cr,
+			fmt.Sprintf("adding replica %d/%d, rebuilding", next, desired))
+		return r.performScaleup(cr, next)
+	}
+
+	if !allReplicasRW(cr) {
+		msg := fmt.Sprintf("waiting for %d replicas to be in RW mode", rf)
+		if cr.Status.Scaleup != nil {
+			msg = fmt.Sprintf("adding replica %d/%d, rebuilding", rf, desired)
 		}
-		return true
+		return r.setScaleupStatus(cr, msg)
+	}
+
+	next := rf + 1
+	logrus.Infof("performing scaleup operation on %s, adding replica %d/%d", cr.Name, next, desired)
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+		"ReplicaScaleup", "adding replica %d/%d", next, desired)
+	replicaScaleups.With(
+		volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+	).Inc()
+	r.updateScaleupStatus(cr,
+		fmt.Sprintf("adding replica %d/%d, rebuilding", next, desired))
+	return r.performScaleup(cr, next)
+}
+
+// updateScaleupStatus sets the scaleup progress in cr without
+// updating the object
+func (r *JivaVolumeReconciler) updateScaleupStatus(cr *jivaAPI.JivaVolume, msg string) {
+	desired := cr.Spec.DesiredReplicationFactor
+	if cr.Status.Scaleup != nil && cr.Status.Scaleup.Desired > desired {
+		desired = cr.Status.Scaleup.Desired
+	}
+	cr.Status.Scaleup = &jivaAPI.ReplicaScaleupStatus{
+		Desired:        desired,
+		Current:        cr.Spec.Policy.Target.ReplicationFactor,
+		Message:        msg,
+		LastUpdateTime: metav1.Now(),
 	}
-	return false
+}
+
+// setScaleupStatus updates the scaleup progress of the volume,
+// the object is updated only if the progress has changed
+func (r *JivaVolumeReconciler) setScaleupStatus(cr *jivaAPI.JivaVolume, msg string) error {
+	if cr.Status.Scaleup != nil && cr.Status.Scaleup.Message == msg &&
+		cr.Status.Scaleup.Current == cr.Spec.Policy.Target.ReplicationFactor {
+		return nil
+	}
+	r.updateScaleupStatus(cr, msg)
+	return r.updateJivaVolume(cr)
 }
 
 // isHAVolume checks if the volume has atleast
@@ -295,34 +495,31 @@ func isHAVolume(cr *jivaAPI.JivaVolume) bool {
 	return false
 }
 
+// moveReplicasForMissingNodes removes the PVC & PV of the pending replicas
+// whose node has been removed and deletes the pods, so that the replicas
+// are recreated on the other nodes. The deleted pods are recorded as
+// pending actions till they are recreated by the statefulset.
 func (r *JivaVolumeReconciler) moveReplicasForMissingNodes(cr *jivaAPI.JivaVolume) error {
+	pods, err := r.listReplicaPods(cr)
+	if err != nil {
+		return err
+	}
 
+	changed := resolveReplicaMovements(cr, pods)
 	// if the volume does not HA replicas in
 	// RW mode skip the process
 	if !isHAVolume(cr) {
+		if changed {
+			return r.updateJivaVolume(cr)
+		}
 		return nil
 	}
 
-	var (
-		replicaLabel   = "openebs.io/component=jiva-replica,openebs.io/persistent-volume="
-		nodeAnnotation = "volume.kubernetes.io/selected-node"
-	)
-	labelSelector, err := labels.Parse(
-		replicaLabel + cr.Name)
-	if err != nil {
-		return err
-	}
-	pods := corev1.PodList{}
-	err = r.List(context.TODO(), &pods, &client.ListOptions{
-		Namespace:     cr.Namespace,
-		LabelSelector: labelSelector,
-	})
-	if err != nil {
-		return err
-	}
-	for _, pod := range pods.Items {
+	for i, pod := range pods.Items {
 		// perform steps only if the pod is in pending state
-		if pod.Status.Phase != corev1.PodPending {
+		// and has not been deleted already
+		if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil ||
+			getPendingAction(cr, pendingReplicaMovement, pod.Name) != nil {
 			continue
 		}
 		pvc := &corev1.PersistentVolumeClaim{}
@@ -335,18 +532,19 @@ func (r *JivaVolumeReconciler) moveReplicasForMissingNodes(cr *jivaAPI.JivaVolum
 			// if the PVC is missing then only
 			// delete the sts pod
 			if errors.IsNotFound(err) {
-				err = r.Delete(context.TODO(), &pod)
-				// wait for pod to get deleted and
-				// recreated
-				time.Sleep(10 * time.Second)
+				err = r.Delete(context.TODO(), &pods.Items[i])
 				if err != nil && !errors.IsNotFound(err) {
 					return err
 				}
+				// wait for pod to get deleted and
+				// recreated
+				changed = addPendingAction(cr, pendingReplicaMovement, pod.Name,
+					"waiting for the replica pod to be recreated") || changed
 				continue
 			}
 			return err
 		}
-		nodeName := pvc.GetAnnotations()[nodeAnnotation]
+		nodeName := pvc.GetAnnotations()[selectedNodeAnnotation]
 		// if a pvc and pod is deleted then in next iteration the nodeName
 		// will be empty which will end up in not-found error
 		// this can result in a race between pvc getting bound and operator deleting
@@ -361,27 +559,70 @@ func (r *JivaVolumeReconciler) moveReplicasForMissingNodes(cr *jivaAPI.JivaVolum
 					if err != nil {
 						return err
 					}
-					err = r.Delete(context.TODO(), &pod)
+					err = r.Delete(context.TODO(), &pods.Items[i])
 					if err != nil {
 						return err
 					}
 					// wait for pod to get deleted and
 					// recreated
-					time.Sleep(10 * time.Second)
+					changed = addPendingAction(cr, pendingReplicaMovement, pod.Name,
+						fmt.Sprintf("waiting for the replica pod to be recreated, node %s is missing", nodeName)) || changed
 					r.Recorder.Eventf(cr, corev1.EventTypeWarning,
 						"ReplicaMovement",
 						"replica %s and it's corresponding PVC & PV deleted",
 						pod.Name,
 					)
+					replicaMovements.With(
+						volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+					).Inc()
 				} else {
 					return err
 				}
 			}
 		}
 	}
+	if changed {
+		return r.updateJivaVolume(cr)
+	}
 	return nil
 }
 
+// resolveReplicaMovements removes the pending replica movements whose pods
+// have been recreated, it returns true if the status of the volume changed.
+func resolveReplicaMovements(cr *jivaAPI.JivaVolume, pods *corev1.PodList) bool {
+	changed := false
+	for _, a := range cr.Status.PendingActions {
+		if a.Type != pendingReplicaMovement {
+			continue
+		}
+		for _, pod := range pods.Items {
+			if pod.Name == a.Target && pod.DeletionTimestamp == nil &&
+				!pod.CreationTimestamp.Before(&a.Since) {
+				changed = removePendingAction(cr, pendingReplicaMovement, a.Target) || changed
+			}
+		}
+	}
+	return changed
+}
+
+// listReplicaPods lists the replica pods of the volume
+func (r *JivaVolumeReconciler) listReplicaPods(cr *jivaAPI.JivaVolume) (*corev1.PodList, error) {
+	labelSelector, err := labels.Parse(
+		replicaComponentSelector + cr.Name)
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	err = r.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
+
 // remove the stale PVC and PV for the missing node
 func (r *JivaVolumeReconciler) removeSTSVolume(pvc *corev1.PersistentVolumeClaim) error {
 	pv := &corev1.PersistentVolume{}
@@ -431,14 +672,38 @@ func (r *JivaVolumeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}); err != nil {
 		return err
 	}
+	r.cache = newVolumeCache()
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&jivaAPI.JivaVolume{}).
+		WithOptions(r.controllerOptions()).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&batchv1.Job{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&jivaAPI.JivaVolumePolicy{},
+			handler.EnqueueRequestsFromMapFunc(r.volumesForPolicy),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.volumeForPod),
+			builder.WithPredicates(jivaPodPredicate)).
+		Watches(&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(r.volumesForNode),
+			builder.WithPredicates(nodePredicate)).
 		Complete(r)
 }
 
+// controllerOptions returns the options of the JivaVolume controller,
+// at least one volume is reconciled at a time
+func (r *JivaVolumeReconciler) controllerOptions() controller.Options {
+	maxConcurrentReconciles := r.MaxConcurrentReconciles
+	if maxConcurrentReconciles < 1 {
+		maxConcurrentReconciles = 1
+	}
+	return controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}
+}
+
 func (r *JivaVolumeReconciler) finally(err error, cr *jivaAPI.JivaVolume) {
 	if err != nil {
 		cr.Status.Phase = jivaAPI.JivaVolumePhaseFailed
@@ -472,6 +737,9 @@ func (r *JivaVolumeReconciler) bootstrapJiva(cr *jivaAPI.JivaVolume) (err error)
 		if err = f(r, cr); err != nil {
 			r.Recorder.Eventf(cr, corev1.EventTypeWarning,
 				"Bootstrap", "failed to bootstrap volume, due to error: %v", err)
+			bootstrapFailures.With(
+				volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+			).Inc()
 			break
 		}
 	}
@@ -481,26 +749,7 @@ func (r *JivaVolumeReconciler) bootstrapJiva(cr *jivaAPI.JivaVolume) (err error)
 
 // TODO: add logic to create disruption budget for replicas
 func createReplicaPodDisruptionBudget(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
-	min := cr.Spec.Policy.Target.ReplicationFactor
-	pdbObj := &policyv1.PodDisruptionBudget{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "PodDisruptionBudget",
-			APIVersion: pdbAPIVersion,
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      cr.Name + "-pdb",
-			Namespace: cr.Namespace,
-		},
-		Spec: policyv1.PodDisruptionBudgetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: defaultReplicaMatchLabels(cr.Spec.PV),
-			},
-			MinAvailable: &intstr.IntOrString{
-				Type:   intstr.Int,
-				IntVal: int32(min/2 + 1),
-			},
-		},
-	}
+	pdbObj := buildReplicaPodDisruptionBudget(cr)
 
 	instance := &policyv1.PodDisruptionBudget{}
 	err := r.Get(context.TODO(), types.NamespacedName{Name: pdbObj.Name, Namespace: pdbObj.Namespace}, instance)
@@ -524,7 +773,34 @@ func createReplicaPodDisruptionBudget(r *JivaVolumeReconciler, cr *jivaAPI.JivaV
 	return nil
 }
 
-func (r *JivaVolumeReconciler) performScaleup(cr *jivaAPI.JivaVolume) error {
+// buildReplicaPodDisruptionBudget builds the desired pod disruption
+// budget of the replicas, which allows only the loss of quorum
+func buildReplicaPodDisruptionBudget(cr *jivaAPI.JivaVolume) *policyv1.PodDisruptionBudget {
+	min := cr.Spec.Policy.Target.ReplicationFactor
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: pdbAPIVersion,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-pdb",
+			Namespace: cr.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: defaultReplicaMatchLabels(cr.Spec.PV),
+			},
+			MinAvailable: &intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: int32(min/2 + 1),
+			},
+		},
+	}
+}
+
+// performScaleup updates the replica sts and the target to the given
+// replica count, it is safe to call it again for the same count.
+func (r *JivaVolumeReconciler) performScaleup(cr *jivaAPI.JivaVolume, replicas int) error {
 	// update the replica sts with the desired replica count
 	// this will bring a new hostpath pvc on a new node and a
 	// new pod
@@ -535,7 +811,7 @@ func (r *JivaVolumeReconciler) performScaleup(cr *jivaAPI.JivaVolume) error {
 	if err != nil {
 		return err
 	}
-	desiredReplicas := int32(cr.Spec.DesiredReplicationFactor)
+	desiredReplicas := int32(replicas)
 	newReplicaSTS := replicaSTS.DeepCopy()
 	newReplicaSTS.Spec.Replicas = &desiredReplicas
 	err = r.Patch(context.TODO(), newReplicaSTS, client.MergeFrom(replicaSTS))
@@ -554,7 +830,7 @@ func (r *JivaVolumeReconciler) performScaleup(cr *jivaAPI.JivaVolume) error {
 	newCtrlDeploy := ctrlDeploy.DeepCopy()
 	for i, con := range newCtrlDeploy.Spec.Template.Spec.Containers {
 		if con.Name == "jiva-controller" {
-			newCtrlDeploy.Spec.Template.Spec.Containers[i].Env[0].Value = strconv.Itoa(cr.Spec.DesiredReplicationFactor)
+			newCtrlDeploy.Spec.Template.Spec.Containers[i].Env[0].Value = strconv.Itoa(replicas)
 		}
 	}
 	err = r.Patch(context.TODO(), newCtrlDeploy, client.MergeFrom(ctrlDeploy))
@@ -571,6 +847,35 @@ func (r *JivaVolumeReconciler) performScaleup(cr *jivaAPI.JivaVolume) error {
 }
 
 func createControllerDeployment(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
+	dep, err := buildControllerDeployment(cr)
+	if err != nil {
+		return err
+	}
+
+	instance := &appsv1.Deployment{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: dep.Name, Namespace: dep.Namespace}, instance)
+	if err != nil && errors.IsNotFound(err) {
+		// Set JivaVolume instance as the owner and controller
+		if err := controllerutil.SetControllerReference(cr, dep, r.Scheme); err != nil {
+			return err
+		}
+
+		logrus.Info("Creating a new deployment", "Deploy.Namespace", dep.Namespace, "Deploy.Name", dep.Name)
+		err = r.Create(context.TODO(), dep)
+		if err != nil {
+			return err
+		}
+		// deployment created successfully - don't requeue
+		return nil
+	} else if err != nil {
+		return operr.Wrapf(err, "failed to get the deployment details: %v", dep.Name)
+	}
+
+	return nil
+}
+
+// buildControllerDeployment builds the desired target deployment of the volume
+func buildControllerDeployment(cr *jivaAPI.JivaVolume) (*appsv1.Deployment, error) {
 	reps := int32(1)
 
 	dep, err := deploy.NewBuilder().WithName(cr.Name + "-jiva-ctrl").
@@ -581,43 +886,53 @@ func createControllerDeployment(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume)
 		WithSelectorMatchLabelsNew(defaultControllerMatchLabels(cr.Spec.PV, cr.GetLabels()[openebsPVC])).
 		WithPodTemplateSpecBuilder(
 			func() *pts.Builder {
+				controllerContainer := container.NewBuilder().
+					WithName("jiva-controller").
+					WithImage(policyImage(cr.Spec.Policy.Target.Image,
+						"OPENEBS_IO_JIVA_CONTROLLER_IMAGE", "jiva-controller")).
+					WithPortsNew(defaultControllerPorts()).
+					WithCommandNew([]string{
+						"launch",
+					}).
+					WithArgumentsNew([]string{
+						"controller",
+						"--frontend",
+						"gotgt",
+						"--clusterIP",
+						cr.Spec.ISCSISpec.TargetIP,
+						cr.Name,
+					}).
+					WithEnvsNew(withPolicyEnv(cr.Spec.Policy.Target.Env, []corev1.EnvVar{
+						{
+							Name:  "REPLICATION_FACTOR",
+							Value: strconv.Itoa(cr.Spec.Policy.Target.ReplicationFactor),
+						},
+					})).
+					WithResources(cr.Spec.Policy.Target.Resources).
+					WithImagePullPolicy(imagePullPolicy(cr.Spec.Policy.Target.PodTemplateResources))
+				if cr.Spec.Policy.Target.SecurityContext != nil {
+					controllerContainer = controllerContainer.WithSecurityContext(cr.Spec.Policy.Target.SecurityContext)
+				}
 				ptsBuilder := pts.NewBuilder().
-					WithLabels(defaultControllerLabels(cr.Spec.PV, cr.GetLabels()[openebsPVC])).
+					WithLabels(withReserved(cr.Spec.Policy.Target.Labels,
+						defaultControllerLabels(cr.Spec.PV, cr.GetLabels()[openebsPVC]))).
 					WithServiceAccountName(defaultServiceAccountName).
-					WithAnnotations(defaultAnnotations()).
 					WithTolerations(cr.Spec.Policy.Target.Tolerations...).
-					WithContainerBuilders(
-						container.NewBuilder().
-							WithName("jiva-controller").
-							WithImage(getImage("OPENEBS_IO_JIVA_CONTROLLER_IMAGE",
-								"jiva-controller")).
-							WithPortsNew(defaultControllerPorts()).
-							WithCommandNew([]string{
-								"launch",
-							}).
-							WithArgumentsNew([]string{
-								"controller",
-								"--frontend",
-								"gotgt",
-								"--clusterIP",
-								cr.Spec.ISCSISpec.TargetIP,
-								cr.Name,
-							}).
-							WithEnvsNew([]corev1.EnvVar{
-								{
-									Name:  "REPLICATION_FACTOR",
-									Value: strconv.Itoa(cr.Spec.Policy.Target.ReplicationFactor),
-								},
-							}).
-							WithResources(cr.Spec.Policy.Target.Resources).
-							WithImagePullPolicy(corev1.PullIfNotPresent),
-					)
-				if !cr.Spec.Policy.Target.DisableMonitor {
+					WithContainerBuilders(controllerContainer)
+				annotations := withPolicyMetadata(withReserved(cr.Spec.Policy.Target.Annotations,
+					targetAnnotations(cr)), cr.Spec.Policy.Target.PodTemplateResources)
+				if len(annotations) != 0 {
+					ptsBuilder = ptsBuilder.WithAnnotations(annotations)
+				}
+				if cr.Spec.Policy.Target.PodSecurityContext != nil {
+					ptsBuilder = ptsBuilder.WithSecurityContext(cr.Spec.Policy.Target.PodSecurityContext)
+				}
+				if !isMonitorDisabled(cr) {
 					ptsBuilder = ptsBuilder.WithContainerBuilders(
 						container.NewBuilder().
-							WithImage(getImage("OPENEBS_IO_MAYA_EXPORTER_IMAGE",
-								"exporter")).
-							WithImagePullPolicy(corev1.PullIfNotPresent).
+							WithImage(policyImage(cr.Spec.Policy.Target.MonitorImage,
+								"OPENEBS_IO_MAYA_EXPORTER_IMAGE", "exporter")).
+							WithImagePullPolicy(imagePullPolicy(cr.Spec.Policy.Target.PodTemplateResources)).
 							WithName("maya-volume-exporter").
 							WithCommandNew([]string{"maya-exporter"}).
 							WithPortsNew([]corev1.ContainerPort{
@@ -639,37 +954,20 @@ func createControllerDeployment(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume)
 				if cr.Spec.Policy.Target.NodeSelector != nil {
 					ptsBuilder = ptsBuilder.WithNodeSelector(cr.Spec.Policy.Target.NodeSelector)
 				}
-				if cr.Spec.Policy.Target.Affinity != nil {
-					ptsBuilder = ptsBuilder.WithAffinity(cr.Spec.Policy.Target.Affinity)
+				if len(cr.Spec.Policy.Target.ImagePullSecrets) != 0 {
+					ptsBuilder = ptsBuilder.WithImagePullSecrets(cr.Spec.Policy.Target.ImagePullSecrets...)
+				}
+				if affinity := targetAffinity(cr); affinity != nil {
+					ptsBuilder = ptsBuilder.WithAffinity(affinity)
 				}
 				return ptsBuilder
 			}(),
 		).Build()
 
 	if err != nil {
-		return fmt.Errorf("failed to build deployment object, err: %v", err)
+		return nil, fmt.Errorf("failed to build deployment object, err: %v", err)
 	}
-
-	instance := &appsv1.Deployment{}
-	err = r.Get(context.TODO(), types.NamespacedName{Name: dep.Name, Namespace: dep.Namespace}, instance)
-	if err != nil && errors.IsNotFound(err) {
-		// Set JivaVolume instance as the owner and controller
-		if err := controllerutil.SetControllerReference(cr, dep, r.Scheme); err != nil {
-			return err
-		}
-
-		logrus.Info("Creating a new deployment", "Deploy.Namespace", dep.Namespace, "Deploy.Name", dep.Name)
-		err = r.Create(context.TODO(), dep)
-		if err != nil {
-			return err
-		}
-		// deployment created successfully - don't requeue
-		return nil
-	} else if err != nil {
-		return operr.Wrapf(err, "failed to get the deployment details: %v", dep.Name)
-	}
-
-	return nil
+	return dep, nil
 }
 
 func getImage(key, component string) string {
@@ -685,6 +983,70 @@ func getImage(key, component string) string {
 	return image
 }
 
+// policyImage returns the image set by the policy, otherwise
+// the image of the component set for the operator
+func policyImage(image, key, component string) string {
+	if image != "" {
+		return image
+	}
+	return getImage(key, component)
+}
+
+// imagePullPolicy returns the pull policy set
+// by the policy, defaults to IfNotPresent
+func imagePullPolicy(res jivaAPI.PodTemplateResources) corev1.PullPolicy {
+	if res.ImagePullPolicy == "" {
+		return corev1.PullIfNotPresent
+	}
+	return res.ImagePullPolicy
+}
+
+// withReserved returns the labels or the annotations set by the
+// policy along with the reserved ones set by the operator, the
+// reserved ones take precedence
+func withReserved(policy, reserved map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range policy {
+		merged[k] = v
+	}
+	for k, v := range reserved {
+		merged[k] = v
+	}
+	return merged
+}
+
+// withPolicyEnv returns the env variables set by the operator along
+// with the env variables set by the policy, the variables set by
+// the operator take precedence
+func withPolicyEnv(policy, env []corev1.EnvVar) []corev1.EnvVar {
+	merged := append([]corev1.EnvVar{}, env...)
+	for _, e := range policyEnv(policy) {
+		reserved := false
+		for _, r := range env {
+			reserved = reserved || r.Name == e.Name
+		}
+		if !reserved {
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// policyEnv returns the env variables set by the policy with the API
+// version of the field references defaulted the way the API server
+// does, so that the drift repair doesn't see them as changed
+func policyEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	defaulted := []corev1.EnvVar{}
+	for _, e := range env {
+		e := *e.DeepCopy()
+		if e.ValueFrom != nil && e.ValueFrom.FieldRef != nil && e.ValueFrom.FieldRef.APIVersion == "" {
+			e.ValueFrom.FieldRef.APIVersion = "v1"
+		}
+		defaulted = append(defaulted, e)
+	}
+	return defaulted
+}
+
 func defaultReplicaLabels(pv string) map[string]string {
 	labels := defaultReplicaMatchLabels(pv)
 	labels["openebs.io/version"] = version.Version
@@ -714,6 +1076,15 @@ func defaultControllerMatchLabels(pv string, pvc string) map[string]string {
 	}
 }
 
+// targetAnnotations returns the annotations of the target pod for the
+// prometheus scrape of the exporter sidecar, if the sidecar is enabled
+func targetAnnotations(cr *jivaAPI.JivaVolume) map[string]string {
+	if isMonitorDisabled(cr) {
+		return nil
+	}
+	return defaultAnnotations()
+}
+
 func defaultAnnotations() map[string]string {
 	return map[string]string{
 		"prometheus.io/path":   "/metrics",
@@ -785,17 +1156,9 @@ func defaultServiceLabels(pv string) map[string]string {
 }
 
 func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
-
-	var (
-		err                            error
-		replicaCount                   int32
-		stsObj                         *appsv1.StatefulSet
-		blockOwnerDeletion, controller = false, true
-		svcName                        = cr.Name + "-jiva-ctrl-svc"
-	)
-
+	svcName := cr.Name + "-jiva-ctrl-svc"
 	svc := &corev1.Service{}
-	err = r.Get(context.TODO(),
+	err := r.Get(context.TODO(),
 		types.NamespacedName{
 			Name:      svcName,
 			Namespace: cr.Namespace,
@@ -805,14 +1168,50 @@ func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) e
 		return fmt.Errorf("failed to get svc %s, err: %v", svcName, err)
 	}
 
+	stsObj, err := buildReplicaStatefulSet(cr, svc.Spec.ClusterIP)
+	if err != nil {
+		return err
+	}
+
+	instance := &appsv1.StatefulSet{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: stsObj.Name, Namespace: stsObj.Namespace}, instance)
+	if err != nil && errors.IsNotFound(err) {
+		// Set JivaVolume instance as the owner and controller
+		if err := controllerutil.SetControllerReference(cr, stsObj, r.Scheme); err != nil {
+			return err
+		}
+
+		logrus.Info("Creating a new Statefulset", "Statefulset.Namespace", stsObj.Namespace, "Sts.Name", stsObj.Name)
+		err = r.Create(context.TODO(), stsObj)
+		if err != nil {
+			return err
+		}
+		// Statefulset created successfully - don't requeue
+		return nil
+	} else if err != nil {
+		return operr.Wrapf(err, "failed to get the statefulset details: %v", stsObj.Name)
+	}
+
+	return nil
+}
+
+// buildReplicaStatefulSet builds the desired replica statefulset of the
+// volume, frontendIP is the ClusterIP of the target service.
+func buildReplicaStatefulSet(cr *jivaAPI.JivaVolume, frontendIP string) (*appsv1.StatefulSet, error) {
+	var (
+		err                            error
+		replicaCount                   int32
+		stsObj                         *appsv1.StatefulSet
+		blockOwnerDeletion, controller = false, true
+	)
+
 	rc := cr.Spec.Policy.Target.ReplicationFactor
 	replicaCount = int32(rc)
 	prev := true
 
-	size := strings.Split(cr.Spec.Capacity, "i")[0]
-	capacity, err := units.RAMInBytes(size)
+	capacity, err := utils.CapacityInBytes(cr.Spec.Capacity)
 	if err != nil {
-		return fmt.Errorf("failed to convert human readable size: %v into int64, err: %v", cr.Spec.Capacity, err)
+		return nil, fmt.Errorf("failed to convert human readable size: %v into int64, err: %v", cr.Spec.Capacity, err)
 	}
 
 	defaultLabels := defaultReplicaLabels(cr.Spec.PV)
@@ -828,37 +1227,53 @@ func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) e
 		WithSelectorMatchLabels(defaultReplicaMatchLabels(cr.Spec.PV)).
 		WithPodTemplateSpecBuilder(
 			func() *pts.Builder {
+				replicaContainer := container.NewBuilder().
+					WithName("jiva-replica").
+					WithImage(policyImage(cr.Spec.Policy.Replica.Image,
+						"OPENEBS_IO_JIVA_REPLICA_IMAGE", "jiva-replica")).
+					WithPortsNew(defaultReplicaPorts()).
+					WithCommandNew([]string{
+						"launch",
+					}).
+					WithArgumentsNew([]string{
+						"replica",
+						"--frontendIP",
+						frontendIP,
+						"--size",
+						fmt.Sprint(capacity),
+						"openebs",
+					}).
+					WithImagePullPolicy(imagePullPolicy(cr.Spec.Policy.Replica.PodTemplateResources)).
+					WithResources(cr.Spec.Policy.Replica.Resources).
+					WithVolumeMountsNew([]corev1.VolumeMount{
+						{
+							Name:      "openebs",
+							MountPath: "/openebs",
+						},
+					})
+				// the replica runs privileged unless the
+				// policy sets the security context
+				if cr.Spec.Policy.Replica.SecurityContext != nil {
+					replicaContainer = replicaContainer.WithSecurityContext(cr.Spec.Policy.Replica.SecurityContext)
+				} else {
+					replicaContainer = replicaContainer.WithPrivilegedSecurityContext(&prev)
+				}
+				if len(cr.Spec.Policy.Replica.Env) != 0 {
+					replicaContainer = replicaContainer.WithEnvsNew(policyEnv(cr.Spec.Policy.Replica.Env))
+				}
 				ptsBuilder := pts.NewBuilder().
 					//WithLabels(defaultReplicaLabels(cr.Spec.PV)).
 					WithServiceAccountName(defaultServiceAccountName).
-					WithContainerBuilders(
-						container.NewBuilder().
-							WithName("jiva-replica").
-							WithImage(getImage("OPENEBS_IO_JIVA_REPLICA_IMAGE",
-								"jiva-replica")).
-							WithPortsNew(defaultReplicaPorts()).
-							WithCommandNew([]string{
-								"launch",
-							}).
-							WithArgumentsNew([]string{
-								"replica",
-								"--frontendIP",
-								svc.Spec.ClusterIP,
-								"--size",
-								fmt.Sprint(capacity),
-								"openebs",
-							}).
-							WithImagePullPolicy(corev1.PullIfNotPresent).
-							WithPrivilegedSecurityContext(&prev).
-							WithResources(cr.Spec.Policy.Replica.Resources).
-							WithVolumeMountsNew([]corev1.VolumeMount{
-								{
-									Name:      "openebs",
-									MountPath: "/openebs",
-								},
-							}),
-					).
+					WithContainerBuilders(replicaContainer).
 					WithTolerations(cr.Spec.Policy.Replica.Tolerations...)
+				annotations := withPolicyMetadata(cr.Spec.Policy.Replica.Annotations,
+					cr.Spec.Policy.Replica.PodTemplateResources)
+				if len(annotations) != 0 {
+					ptsBuilder = ptsBuilder.WithAnnotations(annotations)
+				}
+				if cr.Spec.Policy.Replica.PodSecurityContext != nil {
+					ptsBuilder = ptsBuilder.WithSecurityContext(cr.Spec.Policy.Replica.PodSecurityContext)
+				}
 				if len(cr.Spec.Policy.ServiceAccountName) != 0 {
 					ptsBuilder = ptsBuilder.WithServiceAccountName(cr.Spec.Policy.ServiceAccountName)
 				}
@@ -868,15 +1283,19 @@ func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) e
 				if cr.Spec.Policy.Replica.NodeSelector != nil {
 					ptsBuilder = ptsBuilder.WithNodeSelector(cr.Spec.Policy.Replica.NodeSelector)
 				}
+				if len(cr.Spec.Policy.Replica.ImagePullSecrets) != 0 {
+					ptsBuilder = ptsBuilder.WithImagePullSecrets(cr.Spec.Policy.Replica.ImagePullSecrets...)
+				}
 				if cr.Spec.Policy.Replica.Affinity != nil {
 					if cr.Spec.Policy.Replica.Affinity.PodAntiAffinity != nil {
+						var selectorMap map[string]string
 						for _, term := range cr.Spec.Policy.Replica.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
 							selectorMap, _ = metav1.LabelSelectorAsMap(term.LabelSelector)
 						}
 						defaultLabels[replicaAntiAffinityKey] = selectorMap[replicaAntiAffinityKey]
 					}
 				}
-				ptsBuilder = ptsBuilder.WithLabels(defaultLabels)
+				ptsBuilder = ptsBuilder.WithLabels(withReserved(cr.Spec.Policy.Replica.Labels, defaultLabels))
 				affinity := &corev1.Affinity{
 					PodAntiAffinity: &corev1.PodAntiAffinity{
 						RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
@@ -904,6 +1323,19 @@ func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) e
 
 				ptsBuilder = ptsBuilder.WithAffinity(affinity)
 
+				if spread := cr.Spec.Policy.Replica.TopologySpread; spread != nil {
+					ptsBuilder = ptsBuilder.WithTopologySpreadConstraints(
+						corev1.TopologySpreadConstraint{
+							MaxSkew:           spread.MaxSkew,
+							TopologyKey:       spread.TopologyKey,
+							WhenUnsatisfiable: spread.WhenUnsatisfiable,
+							LabelSelector: &metav1.LabelSelector{
+								MatchLabels: defaultReplicaMatchLabels(cr.Spec.PV),
+							},
+						},
+					)
+				}
+
 				return ptsBuilder
 			}(),
 		).
@@ -926,52 +1358,14 @@ func createReplicaStatefulSet(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) e
 		).Build()
 
 	if err != nil {
-		return fmt.Errorf("failed to build statefulset object, err: %v", err)
-	}
-
-	instance := &appsv1.StatefulSet{}
-	err = r.Get(context.TODO(), types.NamespacedName{Name: stsObj.Name, Namespace: stsObj.Namespace}, instance)
-	if err != nil && errors.IsNotFound(err) {
-		// Set JivaVolume instance as the owner and controller
-		if err := controllerutil.SetControllerReference(cr, stsObj, r.Scheme); err != nil {
-			return err
-		}
-
-		logrus.Info("Creating a new Statefulset", "Statefulset.Namespace", stsObj.Namespace, "Sts.Name", stsObj.Name)
-		err = r.Create(context.TODO(), stsObj)
-		if err != nil {
-			return err
-		}
-		// Statefulset created successfully - don't requeue
-		return nil
-	} else if err != nil {
-		return operr.Wrapf(err, "failed to get the statefulset details: %v", stsObj.Name)
+		return nil, fmt.Errorf("failed to build statefulset object, err: %v", err)
 	}
-
-	return nil
+	return stsObj, nil
 }
 
-func updateJivaVolumeWithServiceInfo(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
-	ctrlSVC := &corev1.Service{}
-	if err := r.Get(context.TODO(),
-		types.NamespacedName{
-			Name:      cr.Name + "-jiva-ctrl-svc",
-			Namespace: cr.Namespace,
-		}, ctrlSVC); err != nil {
-		return fmt.Errorf("%s, err: %v", updateErrMsg, err)
-	}
-	cr.Spec.ISCSISpec.TargetIP = ctrlSVC.Spec.ClusterIP
-	var found bool
-	for _, port := range ctrlSVC.Spec.Ports {
-		if port.Name == "iscsi" {
-			found = true
-			cr.Spec.ISCSISpec.TargetPort = port.Port
-			cr.Spec.ISCSISpec.Iqn = "iqn.2016-09.com.openebs.jiva" + ":" + cr.Spec.PV
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("%s, err: can't find targetPort in target service spec: {%+v}", updateErrMsg, ctrlSVC)
+func updateJivaVolumeWithServiceInfo(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume, ctrlSVC *corev1.Service) error {
+	if err := setISCSISpec(cr, ctrlSVC); err != nil {
+		return err
 	}
 
 	logrus.Info("Updating JivaVolume with iscsi spec", "ISCSISpec", cr.Spec.ISCSISpec)
@@ -989,6 +1383,20 @@ func updateJivaVolumeWithServiceInfo(r *JivaVolumeReconciler, cr *jivaAPI.JivaVo
 	return nil
 }
 
+// setISCSISpec sets the iscsi spec of the volume
+// as per the given target service
+func setISCSISpec(cr *jivaAPI.JivaVolume, ctrlSVC *corev1.Service) error {
+	cr.Spec.ISCSISpec.TargetIP = ctrlSVC.Spec.ClusterIP
+	for _, port := range ctrlSVC.Spec.Ports {
+		if port.Name == "iscsi" {
+			cr.Spec.ISCSISpec.TargetPort = port.Port
+			cr.Spec.ISCSISpec.Iqn = "iqn.2016-09.com.openebs.jiva" + ":" + cr.Spec.PV
+			return nil
+		}
+	}
+	return fmt.Errorf("%s, err: can't find targetPort in target service spec: {%+v}", updateErrMsg, ctrlSVC)
+}
+
 func getBaseReplicaTolerations() []corev1.Toleration {
 	return []corev1.Toleration{
 		corev1.Toleration{
@@ -1066,7 +1474,8 @@ func getBaseTargetTolerations() []corev1.Toleration {
 // getDefaultPolicySpec gives the default policy spec for jiva volume.
 func getDefaultPolicySpec() jivaAPI.JivaVolumePolicySpec {
 	return jivaAPI.JivaVolumePolicySpec{
-		ReplicaSC: defaultStorageClass,
+		ReplicaSC:     defaultStorageClass,
+		DataRetention: jivaAPI.DataRetentionDelete,
 		Target: jivaAPI.TargetSpec{
 			PodTemplateResources: jivaAPI.PodTemplateResources{
 				Tolerations: getBaseTargetTolerations(),
@@ -1092,7 +1501,7 @@ func getDefaultPolicySpec() jivaAPI.JivaVolumePolicySpec {
 				},
 			},
 			ReplicationFactor: defaultReplicationFactor,
-			DisableMonitor:    defaultDisableMonitor,
+			DisableMonitor:    ptr.To(defaultDisableMonitor),
 		},
 		Replica: jivaAPI.ReplicaSpec{
 			PodTemplateResources: jivaAPI.PodTemplateResources{
@@ -1112,6 +1521,23 @@ func getDefaultPolicySpec() jivaAPI.JivaVolumePolicySpec {
 	}
 }
 
+// SetMonitorDefault disables the exporter sidecar of the volume if the
+// policy doesn't enable it. It isn't one of the policy defaults, as it is
+// set only when the volume is created, the existing volumes without the
+// field keep the sidecar.
+func SetMonitorDefault(policy *jivaAPI.JivaVolumePolicySpec) {
+	if policy.Target.DisableMonitor == nil {
+		policy.Target.DisableMonitor = ptr.To(defaultDisableMonitor)
+	}
+}
+
+// isMonitorDisabled checks if the exporter sidecar is disabled for the
+// volume. The volumes created before the monitor was disabled by default
+// don't have the field set, these keep the sidecar.
+func isMonitorDisabled(cr *jivaAPI.JivaVolume) bool {
+	return cr.Spec.Policy.Target.DisableMonitor != nil && *cr.Spec.Policy.Target.DisableMonitor
+}
+
 func defaultRF(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaAPI.JivaVolumePolicySpec) {
 	if policy.Target.ReplicationFactor == 0 {
 		policy.Target.ReplicationFactor = defaultPolicy.Target.ReplicationFactor
@@ -1142,22 +1568,63 @@ func defaultReplicaRes(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaA
 	}
 }
 
+func defaultDataRetention(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaAPI.JivaVolumePolicySpec) {
+	if policy.DataRetention == "" {
+		policy.DataRetention = defaultPolicy.DataRetention
+	}
+}
+
+func defaultReplicaTopologySpread(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaAPI.JivaVolumePolicySpec) {
+	spread := policy.Replica.TopologySpread
+	if spread == nil {
+		return
+	}
+	if spread.MaxSkew == 0 {
+		spread.MaxSkew = 1
+	}
+	if spread.WhenUnsatisfiable == "" {
+		spread.WhenUnsatisfiable = corev1.DoNotSchedule
+	}
+}
+
 func defaultTargetTolerations(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaAPI.JivaVolumePolicySpec) {
-	policy.Target.Tolerations = append(defaultPolicy.Target.Tolerations, policy.Target.Tolerations...)
+	policy.Target.Tolerations = mergeTolerations(defaultPolicy.Target.Tolerations, policy.Target.Tolerations)
 }
 
 func defaultReplicaTolerations(policy *jivaAPI.JivaVolumePolicySpec, defaultPolicy jivaAPI.JivaVolumePolicySpec) {
-	policy.Replica.Tolerations = append(defaultPolicy.Replica.Tolerations, policy.Replica.Tolerations...)
+	policy.Replica.Tolerations = mergeTolerations(defaultPolicy.Replica.Tolerations, policy.Replica.Tolerations)
 }
 
-// validatePolicySpec checks the policy provided by the user and sets the
-// defaults to the policy spec of jiva volume.
-func validatePolicySpec(policy *jivaAPI.JivaVolumePolicySpec) {
+// mergeTolerations prepends the base tolerations which are missing
+// from the given tolerations, so that the defaults can be applied
+// more than once to the same policy. A toleration of the same taint
+// in the given tolerations overrides the base toleration.
+func mergeTolerations(base, tolerations []corev1.Toleration) []corev1.Toleration {
+	merged := []corev1.Toleration{}
+	for i := range base {
+		found := false
+		for _, t := range tolerations {
+			if t.MatchToleration(&base[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, base[i])
+		}
+	}
+	return append(merged, tolerations...)
+}
+
+// SetPolicyDefaults sets the defaults to the policy spec of jiva volume,
+// the policies are validated by the admission webhook. The defaults can be
+// applied more than once, as they are applied at admission time as well.
+func SetPolicyDefaults(policy *jivaAPI.JivaVolumePolicySpec) {
 	defaultPolicy := getDefaultPolicySpec()
 	optFuncs := []policyOptFuncs{
 		defaultRF, defaultSC, defaultTargetRes, defaultReplicaRes,
 		defaultTargetTolerations, defaultReplicaTolerations,
-		defaultTargetAuxRes,
+		defaultTargetAuxRes, defaultDataRetention, defaultReplicaTopologySpread,
 	}
 	for _, o := range optFuncs {
 		o(policy, defaultPolicy)
@@ -1165,45 +1632,57 @@ func validatePolicySpec(policy *jivaAPI.JivaVolumePolicySpec) {
 }
 
 func populateJivaVolumePolicy(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
-	policyName := cr.Annotations["openebs.io/volume-policy"]
+	policyName := cr.Annotations[volumePolicyAnnotation]
 	policySpec := getDefaultPolicySpec()
-	// if policy name is provided via annotation get and validate the
-	// policy spec else set the default policy spec.
+	// if policy name is provided via annotation get the policy, else
+	// get the default policy of the namespace. If there is no default
+	// policy set the compiled-in default policy spec.
+	policy := &jivaAPI.JivaVolumePolicy{}
 	if policyName != "" {
-		policy := jivaAPI.JivaVolumePolicy{}
 		err := r.Get(
 			context.TODO(),
 			types.NamespacedName{Name: policyName, Namespace: cr.Namespace},
-			&policy,
+			policy,
 		)
 		if err != nil {
 			return operr.Wrapf(err, "failed to get volume policy %s", policyName)
 		}
-		policySpec = policy.Spec
-		validatePolicySpec(&policySpec)
+	} else {
+		var err error
+		policy, err = getDefaultPolicy(context.TODO(), r, cr.Namespace)
+		if err != nil {
+			return operr.Wrap(err, "failed to get default volume policy")
+		}
+		if policy != nil {
+			logrus.Infof("provisioning volume %s with default policy %s", cr.Name, policy.Name)
+			// the volume follows the changes of the default
+			// policy it has been provisioned with
+			if cr.Annotations == nil {
+				cr.Annotations = map[string]string{}
+			}
+			cr.Annotations[volumePolicyAnnotation] = policy.Name
+		}
+	}
+	if policy != nil {
+		spec, generations, err := resolvePolicySpec(context.TODO(), r, policy)
+		if err != nil {
+			return err
+		}
+		policySpec = spec
+		SetPolicyDefaults(&policySpec)
+		cr.Status.PolicyGeneration = policy.Generation
+		cr.Status.BasePolicyGenerations = generations
 	}
 	cr.Spec.Policy = policySpec
+	SetMonitorDefault(&cr.Spec.Policy)
 	cr.Spec.DesiredReplicationFactor = policySpec.Target.ReplicationFactor
 	return nil
 }
 
 func createControllerService(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) error {
-
-	// By default type is clusterIP
-	svcObj, err := svc.NewBuilder().
-		WithName(cr.Name + "-jiva-ctrl-svc").
-		WithLabelsNew(defaultServiceLabels(cr.Spec.PV)).
-		WithNamespace(cr.Namespace).
-		WithSelectorsNew(map[string]string{
-			"openebs.io/cas-type":          "jiva",
-			"openebs.io/component":         "jiva-controller",
-			"openebs.io/persistent-volume": cr.Spec.PV,
-		}).
-		WithPorts(defaultControllerSVCPorts()).
-		Build()
-
+	svcObj, err := buildControllerService(cr)
 	if err != nil {
-		return fmt.Errorf("failed to build service object, err: %v", err)
+		return err
 	}
 
 	instance := &corev1.Service{}
@@ -1219,18 +1698,39 @@ func createControllerService(r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) er
 		if err != nil {
 			return err
 		}
-		// Wait for service to get created
-		time.Sleep(1 * time.Second)
-		return updateJivaVolumeWithServiceInfo(r, cr)
+		// the cluster IP is allocated by the
+		// time the service is created
+		return updateJivaVolumeWithServiceInfo(r, cr, svcObj)
 	} else if err != nil {
 		return operr.Wrapf(err, "failed to get the service details: %v", svcObj.Name)
 
 	}
 
-	return updateJivaVolumeWithServiceInfo(r, cr)
+	return updateJivaVolumeWithServiceInfo(r, cr, instance)
 
 }
 
+// buildControllerService builds the desired target service of the volume
+func buildControllerService(cr *jivaAPI.JivaVolume) (*corev1.Service, error) {
+	// By default type is clusterIP
+	svcObj, err := svc.NewBuilder().
+		WithName(cr.Name + "-jiva-ctrl-svc").
+		WithLabelsNew(defaultServiceLabels(cr.Spec.PV)).
+		WithNamespace(cr.Namespace).
+		WithSelectorsNew(map[string]string{
+			"openebs.io/cas-type":          "jiva",
+			"openebs.io/component":         "jiva-controller",
+			"openebs.io/persistent-volume": cr.Spec.PV,
+		}).
+		WithPorts(defaultControllerSVCPorts()).
+		Build()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build service object, err: %v", err)
+	}
+	return svcObj, nil
+}
+
 func (r *JivaVolumeReconciler) updateJivaVolume(cr *jivaAPI.JivaVolume) error {
 	if err := r.Update(context.TODO(), cr); err != nil {
 		return fmt.Errorf("failed to update JivaVolume, err: %v", err)
@@ -1257,11 +1757,24 @@ func (r *JivaVolumeReconciler) getJivaVolume(cr *jivaAPI.JivaVolume) error {
 	return nil
 }
 
-// setdefaults set the default value
+// setdefaults set the default value, the progress of ongoing
+// operations is retained
 func setdefaults(cr *jivaAPI.JivaVolume) {
 	cr.Status = jivaAPI.JivaVolumeStatus{
-		Status: "Unknown",
-		Phase:  jivaAPI.JivaVolumePhaseSyncing,
+		Status:  "Unknown",
+		Phase:   jivaAPI.JivaVolumePhaseSyncing,
+		Scaleup: cr.Status.Scaleup,
+
+		FailedReplicas:        cr.Status.FailedReplicas,
+		ReplacedReplicaCount:  cr.Status.ReplacedReplicaCount,
+		PolicyGeneration:      cr.Status.PolicyGeneration,
+		BasePolicyGenerations: cr.Status.BasePolicyGenerations,
+		PendingActions:        cr.Status.PendingActions,
+		TopologySpread:        cr.Status.TopologySpread,
+		Evacuation:            cr.Status.Evacuation,
+		ConsumerNode:          cr.Status.ConsumerNode,
+		ColocatedNode:         cr.Status.ColocatedNode,
+		ExternalPortals:       cr.Status.ExternalPortals,
 	}
 }
 
@@ -1277,7 +1790,7 @@ func (r *JivaVolumeReconciler) updateStatus(err *error, cr *jivaAPI.JivaVolume)
 	}
 }
 
-func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume) error {
+func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(ctx context.Context, cr *jivaAPI.JivaVolume) error {
 	var (
 		cli *jiva.ControllerClient
 		err error
@@ -1290,7 +1803,7 @@ func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume)
 	}
 
 	addr := cr.Spec.ISCSISpec.TargetIP + ":9501"
-	if podIP, ok := podIPMap[cr.Name]; ok {
+	if podIP, ok := r.cache.getPodIP(types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}); ok {
 		addr = podIP + ":9501"
 	}
 
@@ -1300,7 +1813,12 @@ func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume)
 
 	cli = jiva.NewControllerClient(addr)
 	stats := &volume.Stats{}
+	start := time.Now()
 	err = cli.Get("/stats", stats)
+	statsRequestDuration.With(
+		volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+	).Observe(time.Since(start).Seconds())
+	stats.Got = err == nil
 	if err != nil {
 		// log err only, as controller must be in container creating state
 		// don't return err as it will dump stack trace unneccesary
@@ -1308,10 +1826,16 @@ func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume)
 		err = r.updatePodIPMap(cr)
 		if err != nil {
 			logrus.Infof("failed to get controller pod ip for volume %s: %s", cr.Name, err.Error())
-			time.Sleep(1 * time.Second)
+			addPendingAction(cr, pendingTargetPod, cr.Name+"-jiva-ctrl", targetPodPendingMsg)
+		} else {
+			removePendingAction(cr, pendingTargetPod, cr.Name+"-jiva-ctrl")
+			r.completeTargetFailover(cr)
 		}
 	}
 
+	recordStatsMetrics(cr, stats)
+
+	prevReplicaStatuses := cr.Status.ReplicaStatuses
 	cr.Status.Status = stats.TargetStatus
 	cr.Status.ReplicaCount = len(stats.Replicas)
 	cr.Status.ReplicaStatuses = make([]jivaAPI.ReplicaStatus, len(stats.Replicas))
@@ -1321,6 +1845,12 @@ func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume)
 		cr.Status.ReplicaStatuses[i].Mode = rep.Mode
 	}
 
+	// rebuild progress can be tracked only if the
+	// replica details are fetched from the target
+	if stats.Got {
+		r.updateRebuildStatus(ctx, cr, prevReplicaStatuses)
+	}
+
 	if stats.TargetStatus == "RW" {
 		cr.Status.Phase = jivaAPI.JivaVolumePhaseReady
 	} else if stats.TargetStatus == "RO" {
@@ -1332,58 +1862,88 @@ func (r *JivaVolumeReconciler) getAndUpdateVolumeStatus(cr *jivaAPI.JivaVolume)
 	return nil
 }
 
-func (r *JivaVolumeReconciler) reconcileVersion(cr *jivaAPI.JivaVolume) error {
+// reconcileVersion upgrades the volume to the desired version, it
+// returns true while the upgrade of the data plane is in progress
+func (r *JivaVolumeReconciler) reconcileVersion(ctx context.Context, cr *jivaAPI.JivaVolume) (bool, error) {
 	var err error
+	rollback := isUpgradeRollback(cr)
 	// the below code uses deep copy to have the state of object just before
 	// any update call is done so that on failure the last state object can be returned
-	if cr.VersionDetails.Status.Current != cr.VersionDetails.Desired {
-		if !version.IsCurrentVersionValid(cr.VersionDetails.Status.Current) {
-			return fmt.Errorf("invalid current version %s", cr.VersionDetails.Status.Current)
-		}
-		if !version.IsDesiredVersionValid(cr.VersionDetails.Desired) {
-			return fmt.Errorf("invalid desired version %s", cr.VersionDetails.Desired)
+	if cr.VersionDetails.Status.Current != cr.VersionDetails.Desired || rollback {
+		if !rollback {
+			if !version.IsCurrentVersionValid(cr.VersionDetails.Status.Current) {
+				return false, fmt.Errorf("invalid current version %s", cr.VersionDetails.Status.Current)
+			}
+			if !version.IsDesiredVersionValid(cr.VersionDetails.Desired) {
+				return false, fmt.Errorf("invalid desired version %s", cr.VersionDetails.Desired)
+			}
 		}
 		jObj := cr.DeepCopy()
 		if cr.VersionDetails.Status.State != jivaAPI.ReconcileInProgress {
 			jObj.VersionDetails.Status.SetInProgressStatus()
 			err = r.updateJivaVolume(jObj)
 			if err != nil {
-				return err
+				return false, err
 			}
 		}
 		// Update cr with the updated fields so that we don't get
 		// resourceVersion changed error in next steps
 		if err := r.getJivaVolume(cr); err != nil {
-			return fmt.Errorf("%s, err: %v", updateErrMsg, err)
+			return false, fmt.Errorf("%s, err: %v", updateErrMsg, err)
 		}
-		// As no other steps are required just change current version to
-		// desired version
-		path := strings.Split(jObj.VersionDetails.Status.Current, "-")[0]
-		u := &upgradeParams{
-			j:      jObj,
-			client: r.Client,
+		if jObj.VersionDetails.Status.UpgradeStep == "" {
+			path := strings.Split(jObj.VersionDetails.Status.Current, "-")[0]
+			u := &upgradeParams{
+				j:      jObj,
+				client: r.Client,
+			}
+			// Get upgrade function for corresponding path, if path does not
+			// exits then no version specific changes are required and
+			// funcValue will be nil.
+			funcValue := upgradeMap[path]
+			if funcValue != nil {
+				jObj, err = funcValue(u)
+				if err != nil {
+					return false, err
+				}
+			}
+			if err = r.startUpgrade(jObj); err != nil {
+				return false, err
+			}
 		}
-		// Get upgrade function for corresponding path, if path does not
-		// exits then no upgrade is required and funcValue will be nil.
-		funcValue := upgradeMap[path]
-		if funcValue != nil {
-			jObj, err = funcValue(u)
-			if err != nil {
-				return err
+		done, err := r.upgradeDataPlane(ctx, jObj)
+		if err != nil {
+			jObj.VersionDetails.Status.SetErrorStatus("failed to upgrade volume components", err)
+			if err := r.updateJivaVolume(jObj); err != nil {
+				logrus.Error(err, "failed to update upgrade status")
 			}
+			return false, err
+		}
+		if !done {
+			return true, nil
+		}
+		if rollback {
+			r.Recorder.Eventf(jObj, corev1.EventTypeNormal, upgradeReason,
+				"rolled back to %s", jObj.VersionDetails.Desired)
+		} else {
+			r.Recorder.Eventf(jObj, corev1.EventTypeNormal, upgradeReason,
+				"upgraded to %s", jObj.VersionDetails.Desired)
 		}
+		versionReconciles.With(
+			volumeLabelValues(jObj.Spec.PV, jObj.GetLabels()[openebsPVC], jObj.Namespace),
+		).Inc()
 		cr = jObj.DeepCopy()
 		jObj.VersionDetails.SetSuccessStatus()
 		err = r.updateJivaVolume(jObj)
 		if err != nil {
-			return err
+			return false, err
 		}
 		// Update cr with the updated fields so that we don't get
 		// resourceVersion changed error in next steps
 		if err := r.getJivaVolume(cr); err != nil {
-			return fmt.Errorf("%s, err: %v", updateErrMsg, err)
+			return false, fmt.Errorf("%s, err: %v", updateErrMsg, err)
 		}
-		return nil
+		return false, nil
 	}
-	return nil
+	return false, nil
 }
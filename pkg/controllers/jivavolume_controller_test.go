@@ -0,0 +1,356 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const testNamespace = "openebs"
+
+// newTestReconciler returns a reconciler backed by a fake
+// client which holds the given objects
+func newTestReconciler(t *testing.T, objs ...client.Object) *JivaVolumeReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add client-go types to scheme: %v", err)
+	}
+	if err := jivaAPI.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add jiva types to scheme: %v", err)
+	}
+	return &JivaVolumeReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		cache:    newVolumeCache(),
+	}
+}
+
+// newTestVolume returns a volume with the given replication
+// factor whose replicas are all in RW mode
+func newTestVolume(name string, rf int) *jivaAPI.JivaVolume {
+	cr := &jivaAPI.JivaVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Spec: jivaAPI.JivaVolumeSpec{
+			PV:       name,
+			Capacity: "4Gi",
+			Policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: rf},
+			},
+		},
+		Status: jivaAPI.JivaVolumeStatus{
+			Status: "RW",
+			Phase:  jivaAPI.JivaVolumePhaseReady,
+		},
+	}
+	for i := 0; i < rf; i++ {
+		cr.Status.ReplicaStatuses = append(cr.Status.ReplicaStatuses, jivaAPI.ReplicaStatus{
+			Address: fmt.Sprintf("tcp://10.0.0.%d:9502", i+1),
+			Mode:    "RW",
+		})
+	}
+	cr.Status.ReplicaCount = rf
+	return cr
+}
+
+// newTestReplica returns the replica pod with the given ordinal and its
+// PVC, the pod is running with the IP of the replica in newTestVolume
+func newTestReplica(cr *jivaAPI.JivaVolume, ordinal int) (*corev1.Pod, *corev1.PersistentVolumeClaim) {
+	labels := map[string]string{
+		"openebs.io/cas-type":          "jiva",
+		"openebs.io/component":         "jiva-replica",
+		"openebs.io/persistent-volume": cr.Name,
+	}
+	claimName := fmt.Sprintf("%s-%s-jiva-rep-%d", replicaVolumeName, cr.Name, ordinal)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-jiva-rep-%d", cr.Name, ordinal),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name: replicaVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: fmt.Sprintf("10.0.0.%d", ordinal+1),
+		},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimName,
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+	}
+	return pod, pvc
+}
+
+// getTestVolume fetches the latest copy of the volume
+func getTestVolume(t *testing.T, r *JivaVolumeReconciler, name string) *jivaAPI.JivaVolume {
+	cr := &jivaAPI.JivaVolume{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: testNamespace}, cr)
+	if err != nil {
+		t.Fatalf("failed to get volume %s: %v", name, err)
+	}
+	return cr
+}
+
+// objectExists returns true if the object with the given name is found
+func objectExists(t *testing.T, r *JivaVolumeReconciler, name, namespace string, obj client.Object) bool {
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if err != nil && client.IgnoreNotFound(err) != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	return err == nil
+}
+
+func TestMergeTolerations(t *testing.T) {
+	base := getBaseTargetTolerations()
+	tests := map[string]struct {
+		tolerations  []corev1.Toleration
+		expectLength int
+	}{
+		"Test without tolerations": {
+			expectLength: len(base),
+		},
+		"Test with the base tolerations": {
+			tolerations:  getBaseTargetTolerations(),
+			expectLength: len(base),
+		},
+		"Test with an additional toleration": {
+			tolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+			},
+			expectLength: len(base) + 1,
+		},
+		"Test with an override of a base toleration": {
+			tolerations: []corev1.Toleration{
+				{Key: base[0].Key, Operator: corev1.TolerationOpExists, Effect: base[0].Effect},
+			},
+			expectLength: len(base),
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			merged := mergeTolerations(base, mock.tolerations)
+			if len(merged) != mock.expectLength {
+				t.Fatalf("Test %q failed: expected %d tolerations, got %d", name, mock.expectLength, len(merged))
+			}
+			for _, toleration := range mock.tolerations {
+				found := false
+				for i := range merged {
+					found = found || reflect.DeepEqual(merged[i], toleration)
+				}
+				if !found {
+					t.Fatalf("Test %q failed: expected toleration %+v to be kept", name, toleration)
+				}
+			}
+			if again := mergeTolerations(base, merged); !reflect.DeepEqual(again, merged) {
+				t.Fatalf("Test %q failed: expected merge to be idempotent, got %+v after %+v", name, again, merged)
+			}
+		})
+	}
+}
+
+func TestSetPolicyDefaultsIdempotent(t *testing.T) {
+	policy := jivaAPI.JivaVolumePolicySpec{}
+	SetPolicyDefaults(&policy)
+	defaulted := *policy.DeepCopy()
+	SetPolicyDefaults(&policy)
+	if !reflect.DeepEqual(policy, defaulted) {
+		t.Fatalf("expected defaults applied twice to be the same, got %+v after %+v", policy, defaulted)
+	}
+}
+
+// newTestScaleup returns the volume with the given replication
+// factor to be scaled up to the desired replication factor along
+// with its replica statefulset and target deployment
+func newTestScaleup(rf, desired int) (*jivaAPI.JivaVolume, *appsv1.StatefulSet, *appsv1.Deployment) {
+	cr := newTestVolume("pv", rf)
+	cr.Spec.DesiredReplicationFactor = desired
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(rf))},
+	}
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: "jiva-controller",
+						Env:  []corev1.EnvVar{{Name: "REPLICATION_FACTOR", Value: fmt.Sprint(rf)}},
+					}},
+				},
+			},
+		},
+	}
+	return cr, sts, dep
+}
+
+// connectTestReplicas connects the replicas of the statefulset to the
+// target as the target would, the new replicas are rebuilt in WO mode
+// before they are in RW mode
+func connectTestReplicas(t *testing.T, r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume, replicas int) {
+	for i := range cr.Status.ReplicaStatuses {
+		cr.Status.ReplicaStatuses[i].Mode = "RW"
+	}
+	for i := len(cr.Status.ReplicaStatuses); i < replicas; i++ {
+		cr.Status.ReplicaStatuses = append(cr.Status.ReplicaStatuses, jivaAPI.ReplicaStatus{
+			Address: fmt.Sprintf("tcp://10.0.0.%d:9502", i+1),
+			Mode:    "WO",
+		})
+	}
+	cr.Status.ReplicaCount = len(cr.Status.ReplicaStatuses)
+	if err := r.Update(context.TODO(), cr); err != nil {
+		t.Fatalf("failed to update volume status: %v", err)
+	}
+}
+
+func TestReconcileScaleup(t *testing.T) {
+	cr, sts, dep := newTestScaleup(1, 3)
+	r := newTestReconciler(t, cr, sts, dep)
+
+	steps := []int32{}
+	for i := 0; i < 20 && isScaleup(cr); i++ {
+		rw := allReplicasRW(cr)
+		before := *sts.Spec.Replicas
+		if err := r.reconcileScaleup(cr); err != nil {
+			t.Fatalf("expected error to be nil, got: %v", err)
+		}
+		cr = getTestVolume(t, r, cr.Name)
+		objectExists(t, r, sts.Name, sts.Namespace, sts)
+		if *sts.Spec.Replicas != before {
+			if !rw {
+				t.Fatalf("expected a replica to be added only when all the replicas are in RW mode")
+			}
+			steps = append(steps, *sts.Spec.Replicas)
+		}
+		if cr.Spec.Policy.Target.ReplicationFactor != int(*sts.Spec.Replicas) {
+			t.Fatalf("expected replication factor %d, got %d",
+				*sts.Spec.Replicas, cr.Spec.Policy.Target.ReplicationFactor)
+		}
+		connectTestReplicas(t, r, cr, int(*sts.Spec.Replicas))
+		cr = getTestVolume(t, r, cr.Name)
+	}
+
+	if !reflect.DeepEqual(steps, []int32{2, 3}) {
+		t.Fatalf("expected replicas to be added one at a time, got steps %v", steps)
+	}
+	if cr.Status.Scaleup != nil {
+		t.Fatalf("expected scaleup to be completed, got %+v", cr.Status.Scaleup)
+	}
+	objectExists(t, r, dep.Name, dep.Namespace, dep)
+	if env := dep.Spec.Template.Spec.Containers[0].Env[0].Value; env != "3" {
+		t.Fatalf("expected target replication factor 3, got %s", env)
+	}
+}
+
+func TestReconcileScaleupResume(t *testing.T) {
+	tests := map[string]struct {
+		rf, desired, stsReplicas int
+		scaleup                  *jivaAPI.ReplicaScaleupStatus
+		rebuilding               bool
+		expectRF, expectReplicas int
+		expectScaleup            bool
+	}{
+		"Test scaleup interrupted after updating the statefulset": {
+			rf: 1, desired: 3, stsReplicas: 2,
+			scaleup:  &jivaAPI.ReplicaScaleupStatus{Desired: 3, Current: 1},
+			expectRF: 2, expectReplicas: 2,
+			expectScaleup: true,
+		},
+		"Test scaleup waiting for the added replica to be rebuilt": {
+			rf: 2, desired: 3, stsReplicas: 2,
+			scaleup:    &jivaAPI.ReplicaScaleupStatus{Desired: 3, Current: 2},
+			rebuilding: true,
+			expectRF:   2, expectReplicas: 2,
+			expectScaleup: true,
+		},
+		"Test scaleup waiting for the last replica to be rebuilt": {
+			rf: 3, desired: 3, stsReplicas: 3,
+			scaleup:    &jivaAPI.ReplicaScaleupStatus{Desired: 3, Current: 3},
+			rebuilding: true,
+			expectRF:   3, expectReplicas: 3,
+			expectScaleup: true,
+		},
+		"Test scaleup with the last replica rebuilt": {
+			rf: 3, desired: 3, stsReplicas: 3,
+			scaleup:  &jivaAPI.ReplicaScaleupStatus{Desired: 3, Current: 3},
+			expectRF: 3, expectReplicas: 3,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr, sts, dep := newTestScaleup(mock.rf, mock.desired)
+			cr.Status.Scaleup = mock.scaleup
+			if mock.rebuilding {
+				cr.Status.ReplicaStatuses[mock.rf-1].Mode = "WO"
+			}
+			sts.Spec.Replicas = ptr.To(int32(mock.stsReplicas))
+			r := newTestReconciler(t, cr, sts, dep)
+
+			if err := r.reconcileScaleup(cr); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			got := getTestVolume(t, r, cr.Name)
+			objectExists(t, r, sts.Name, sts.Namespace, sts)
+			if got.Spec.Policy.Target.ReplicationFactor != mock.expectRF ||
+				int(*sts.Spec.Replicas) != mock.expectReplicas {
+				t.Fatalf("Test %q failed: expected replication factor %d with %d replicas, got %d with %d replicas",
+					name, mock.expectRF, mock.expectReplicas,
+					got.Spec.Policy.Target.ReplicationFactor, *sts.Spec.Replicas)
+			}
+			if (got.Status.Scaleup != nil) != mock.expectScaleup {
+				t.Fatalf("Test %q failed: expected scaleup in progress: %t, got %+v",
+					name, mock.expectScaleup, got.Status.Scaleup)
+			}
+			if got.Status.Scaleup != nil && got.Status.Scaleup.Desired != mock.desired {
+				t.Fatalf("Test %q failed: expected desired replicas %d, got %d",
+					name, mock.desired, got.Status.Scaleup.Desired)
+			}
+		})
+	}
+}
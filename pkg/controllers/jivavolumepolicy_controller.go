@@ -0,0 +1,343 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	policyValidationReason = "PolicyValidation"
+
+	conditionReasonFound    = "Found"
+	conditionReasonNotFound = "NotFound"
+	conditionReasonNotSet   = "NotSet"
+)
+
+// JivaVolumePolicyReconciler validates the resources referenced by the
+// JivaVolumePolicies and reports the volumes which use each policy.
+type JivaVolumePolicyReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+
+	// apiReader reads the service accounts and the secrets from the
+	// API server, so that the service accounts and the secrets of
+	// all the namespaces aren't cached to look up a few of them
+	apiReader client.Reader
+}
+
+// +kubebuilder:rbac:groups=openebs.io,resources=jivavolumepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=openebs.io,resources=jivavolumepolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile validates the policy and updates its status with the result
+// of the validation and the volumes which use the policy.
+func (r *JivaVolumePolicyReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	policy := &jivaAPI.JivaVolumePolicy{}
+	if err := r.Get(ctx, request.NamespacedName, policy); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	status := policy.Status.DeepCopy()
+	status.ObservedGeneration = policy.Generation
+	if err := r.validatePolicy(ctx, policy, status); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	volumes, err := r.volumesUsingPolicy(ctx, policy)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	status.Volumes = volumes
+	status.VolumeCount = len(volumes)
+
+	if equality.Semantic.DeepEqual(status, &policy.Status) {
+		return reconcile.Result{}, nil
+	}
+
+	if status.Phase != policy.Status.Phase {
+		if status.Phase == jivaAPI.JivaVolumePolicyPhaseInvalid {
+			logrus.Warningf("policy %s/%s is invalid", policy.Namespace, policy.Name)
+			r.Recorder.Event(policy, corev1.EventTypeWarning, policyValidationReason, invalidConditionsMessage(status))
+		} else if policy.Status.Phase != "" {
+			r.Recorder.Event(policy, corev1.EventTypeNormal, policyValidationReason, "all the referenced resources exist")
+		}
+	}
+	policy.Status = *status
+	return reconcile.Result{}, r.Status().Update(ctx, policy)
+}
+
+// validatePolicy checks that the base policies, the storage class, the
+// priority class, the service account and the image pull secrets
+// referenced by the policy, or inherited from its base policies, exist
+// and sets the conditions and the phase of the status accordingly.
+func (r *JivaVolumePolicyReconciler) validatePolicy(ctx context.Context,
+	policy *jivaAPI.JivaVolumePolicy, status *jivaAPI.JivaVolumePolicyStatus) error {
+	status.Phase = jivaAPI.JivaVolumePolicyPhaseValid
+
+	baseCondition := metav1.Condition{
+		Type:               jivaAPI.PolicyConditionBasePolicy,
+		Status:             metav1.ConditionTrue,
+		Reason:             conditionReasonNotSet,
+		Message:            "no base policy is set",
+		ObservedGeneration: policy.Generation,
+	}
+	spec, _, err := resolvePolicySpec(ctx, r.Client, policy)
+	switch {
+	case err != nil:
+		spec = policy.Spec
+		baseCondition.Status = metav1.ConditionFalse
+		baseCondition.Reason = conditionReasonNotFound
+		baseCondition.Message = err.Error()
+		status.Phase = jivaAPI.JivaVolumePolicyPhaseInvalid
+	case policy.Spec.BasePolicy != "":
+		baseCondition.Reason = conditionReasonFound
+		baseCondition.Message = fmt.Sprintf("base policy %s found", policy.Spec.BasePolicy)
+	}
+	meta.SetStatusCondition(&status.Conditions, baseCondition)
+	SetPolicyDefaults(&spec)
+
+	checks := []struct {
+		conditionType string
+		kind          string
+		name          string
+		obj           client.Object
+		key           types.NamespacedName
+		reader        client.Reader
+	}{
+		{jivaAPI.PolicyConditionReplicaSC, "storage class", spec.ReplicaSC,
+			&storagev1.StorageClass{}, types.NamespacedName{Name: spec.ReplicaSC}, r.Client},
+		{jivaAPI.PolicyConditionPriorityClass, "priority class", spec.PriorityClassName,
+			&schedulingv1.PriorityClass{}, types.NamespacedName{Name: spec.PriorityClassName}, r.Client},
+		{jivaAPI.PolicyConditionServiceAccount, "service account", spec.ServiceAccountName,
+			&corev1.ServiceAccount{}, types.NamespacedName{Name: spec.ServiceAccountName, Namespace: policy.Namespace}, r.apiReader},
+	}
+
+	for _, c := range checks {
+		condition := metav1.Condition{
+			Type:               c.conditionType,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: policy.Generation,
+		}
+		if c.name == "" {
+			condition.Reason = conditionReasonNotSet
+			condition.Message = fmt.Sprintf("no %s is set", c.kind)
+			meta.SetStatusCondition(&status.Conditions, condition)
+			continue
+		}
+		err := c.reader.Get(ctx, c.key, c.obj)
+		switch {
+		case errors.IsNotFound(err):
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = conditionReasonNotFound
+			condition.Message = fmt.Sprintf("%s %s not found", c.kind, c.name)
+			status.Phase = jivaAPI.JivaVolumePolicyPhaseInvalid
+		case err != nil:
+			return err
+		default:
+			condition.Reason = conditionReasonFound
+			condition.Message = fmt.Sprintf("%s %s found", c.kind, c.name)
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+	return r.validateImagePullSecrets(ctx, policy, &spec, status)
+}
+
+// validateImagePullSecrets checks that the image pull secrets of
+// the target and the replicas exist in the namespace of the policy
+func (r *JivaVolumePolicyReconciler) validateImagePullSecrets(ctx context.Context, policy *jivaAPI.JivaVolumePolicy,
+	spec *jivaAPI.JivaVolumePolicySpec, status *jivaAPI.JivaVolumePolicyStatus) error {
+	condition := metav1.Condition{
+		Type:               jivaAPI.PolicyConditionImagePullSecrets,
+		Status:             metav1.ConditionTrue,
+		Reason:             conditionReasonNotSet,
+		Message:            "no image pull secret is set",
+		ObservedGeneration: policy.Generation,
+	}
+	names, seen := []string{}, map[string]bool{}
+	for _, ref := range append(spec.Target.ImagePullSecrets, spec.Replica.ImagePullSecrets...) {
+		if !seen[ref.Name] {
+			seen[ref.Name] = true
+			names = append(names, ref.Name)
+		}
+	}
+
+	missing := []string{}
+	for _, name := range names {
+		err := r.apiReader.Get(ctx, types.NamespacedName{Name: name, Namespace: policy.Namespace}, &corev1.Secret{})
+		switch {
+		case errors.IsNotFound(err):
+			missing = append(missing, name)
+		case err != nil:
+			return err
+		}
+	}
+	switch {
+	case len(missing) != 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditionReasonNotFound
+		condition.Message = fmt.Sprintf("image pull secrets %s not found", strings.Join(missing, ", "))
+		status.Phase = jivaAPI.JivaVolumePolicyPhaseInvalid
+	case len(names) != 0:
+		condition.Reason = conditionReasonFound
+		condition.Message = fmt.Sprintf("image pull secrets %s found", strings.Join(names, ", "))
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+	return nil
+}
+
+// invalidConditionsMessage joins the messages of the failed conditions
+func invalidConditionsMessage(status *jivaAPI.JivaVolumePolicyStatus) string {
+	msg := "invalid policy:"
+	for _, c := range status.Conditions {
+		if c.Status == metav1.ConditionFalse {
+			msg += " " + c.Message + ";"
+		}
+	}
+	return msg
+}
+
+// volumesUsingPolicy returns the sorted names of the volumes
+// which reference the policy through the policy annotation
+func (r *JivaVolumePolicyReconciler) volumesUsingPolicy(ctx context.Context, policy *jivaAPI.JivaVolumePolicy) ([]string, error) {
+	volumes := &jivaAPI.JivaVolumeList{}
+	if err := r.List(ctx, volumes, client.InNamespace(policy.Namespace)); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, jv := range volumes.Items {
+		if jv.Annotations[volumePolicyAnnotation] == policy.Name {
+			names = append(names, jv.Name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return names, nil
+}
+
+// policyForVolume maps a JivaVolume to the policy in its annotation
+func (r *JivaVolumePolicyReconciler) policyForVolume(ctx context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetAnnotations()[volumePolicyAnnotation]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()},
+	}}
+}
+
+// policiesMatching returns a map function which maps an object to
+// the policies whose effective spec, with the defaults, matches
+// the given func
+func (r *JivaVolumePolicyReconciler) policiesMatching(match func(spec *jivaAPI.JivaVolumePolicySpec, name string) bool) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		policies := &jivaAPI.JivaVolumePolicyList{}
+		if err := r.List(ctx, policies); err != nil {
+			logrus.Errorf("failed to list policies for %s: %v", obj.GetName(), err)
+			return nil
+		}
+		requests := []reconcile.Request{}
+		for i := range policies.Items {
+			policy := &policies.Items[i]
+			spec, _, err := resolvePolicySpec(ctx, r.Client, policy)
+			if err != nil {
+				spec = policy.Spec
+			}
+			SetPolicyDefaults(&spec)
+			if match(&spec, obj.GetName()) {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace},
+				})
+			}
+		}
+		return requests
+	}
+}
+
+// policiesInheriting maps a policy to the policies which inherit from it
+func (r *JivaVolumePolicyReconciler) policiesInheriting(ctx context.Context, obj client.Object) []reconcile.Request {
+	policyList := &jivaAPI.JivaVolumePolicyList{}
+	if err := r.List(ctx, policyList, client.InNamespace(obj.GetNamespace())); err != nil {
+		logrus.Errorf("failed to list policies inheriting %s: %v", obj.GetName(), err)
+		return nil
+	}
+	policies := map[string]*jivaAPI.JivaVolumePolicy{}
+	for i := range policyList.Items {
+		policies[policyList.Items[i].Name] = &policyList.Items[i]
+	}
+	requests := []reconcile.Request{}
+	for name, policy := range policies {
+		if name != obj.GetName() && policyInherits(policy, obj.GetName(), policies) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: name, Namespace: policy.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager. The service
+// accounts and the secrets aren't watched, a missing service account or
+// secret is checked again at the sync period of the manager.
+func (r *JivaVolumePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.apiReader = mgr.GetAPIReader()
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&jivaAPI.JivaVolumePolicy{}).
+		Watches(&jivaAPI.JivaVolumePolicy{},
+			handler.EnqueueRequestsFromMapFunc(r.policiesInheriting),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&jivaAPI.JivaVolume{},
+			handler.EnqueueRequestsFromMapFunc(r.policyForVolume),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Watches(&storagev1.StorageClass{},
+			handler.EnqueueRequestsFromMapFunc(r.policiesMatching(
+				func(spec *jivaAPI.JivaVolumePolicySpec, name string) bool { return spec.ReplicaSC == name }))).
+		Watches(&schedulingv1.PriorityClass{},
+			handler.EnqueueRequestsFromMapFunc(r.policiesMatching(
+				func(spec *jivaAPI.JivaVolumePolicySpec, name string) bool { return spec.PriorityClassName == name }))).
+		Complete(r)
+}
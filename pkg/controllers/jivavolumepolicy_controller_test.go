@@ -0,0 +1,197 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// newTestPolicyReconciler returns a policy reconciler backed
+// by a fake client which holds the given objects
+func newTestPolicyReconciler(t *testing.T, objs ...client.Object) *JivaVolumePolicyReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add client-go types to scheme: %v", err)
+	}
+	if err := jivaAPI.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add jiva types to scheme: %v", err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&jivaAPI.JivaVolumePolicy{}).Build()
+	return &JivaVolumePolicyReconciler{
+		Client:    cl,
+		Recorder:  record.NewFakeRecorder(100),
+		apiReader: cl,
+	}
+}
+
+// reconcileTestPolicy reconciles the policy and returns its latest copy
+func reconcileTestPolicy(t *testing.T, r *JivaVolumePolicyReconciler, name string) *jivaAPI.JivaVolumePolicy {
+	key := types.NamespacedName{Name: name, Namespace: testNamespace}
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile policy %s: %v", name, err)
+	}
+	policy := &jivaAPI.JivaVolumePolicy{}
+	if err := r.Get(context.TODO(), key, policy); err != nil {
+		t.Fatalf("failed to get policy %s: %v", name, err)
+	}
+	return policy
+}
+
+func TestReconcilePolicyValidation(t *testing.T) {
+	sc := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "openebs-hostpath"}}
+	pc := &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "jiva", Namespace: testNamespace}}
+	spec := jivaAPI.JivaVolumePolicySpec{
+		ReplicaSC:          "openebs-hostpath",
+		PriorityClassName:  "high",
+		ServiceAccountName: "jiva",
+	}
+	tests := map[string]struct {
+		objs []client.Object
+		// expectFailed is the type of the failed
+		// condition, the policy is valid if it is empty
+		expectFailed string
+	}{
+		"Test policy with all the resources": {
+			objs: []client.Object{sc, pc, sa},
+		},
+		"Test policy with missing replica storage class": {
+			objs:         []client.Object{pc, sa},
+			expectFailed: jivaAPI.PolicyConditionReplicaSC,
+		},
+		"Test policy with missing priority class": {
+			objs:         []client.Object{sc, sa},
+			expectFailed: jivaAPI.PolicyConditionPriorityClass,
+		},
+		"Test policy with missing service account": {
+			objs:         []client.Object{sc, pc},
+			expectFailed: jivaAPI.PolicyConditionServiceAccount,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			policy := &jivaAPI.JivaVolumePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: testNamespace, Generation: 3},
+				Spec:       *spec.DeepCopy(),
+			}
+			r := newTestPolicyReconciler(t, append(mock.objs, policy)...)
+
+			got := reconcileTestPolicy(t, r, policy.Name)
+			expectPhase := jivaAPI.JivaVolumePolicyPhaseValid
+			if mock.expectFailed != "" {
+				expectPhase = jivaAPI.JivaVolumePolicyPhaseInvalid
+			}
+			if got.Status.Phase != expectPhase {
+				t.Fatalf("Test %q failed: expected phase %s, got %s", name, expectPhase, got.Status.Phase)
+			}
+			if got.Status.ObservedGeneration != policy.Generation {
+				t.Fatalf("Test %q failed: expected observed generation %d, got %d",
+					name, policy.Generation, got.Status.ObservedGeneration)
+			}
+			for _, conditionType := range []string{
+				jivaAPI.PolicyConditionReplicaSC,
+				jivaAPI.PolicyConditionPriorityClass,
+				jivaAPI.PolicyConditionServiceAccount,
+			} {
+				condition := meta.FindStatusCondition(got.Status.Conditions, conditionType)
+				if condition == nil {
+					t.Fatalf("Test %q failed: expected condition %s, got %v",
+						name, conditionType, got.Status.Conditions)
+				}
+				expectStatus, expectReason := metav1.ConditionTrue, conditionReasonFound
+				if conditionType == mock.expectFailed {
+					expectStatus, expectReason = metav1.ConditionFalse, conditionReasonNotFound
+				}
+				if condition.Status != expectStatus || condition.Reason != expectReason {
+					t.Fatalf("Test %q failed: expected condition %s to be %s/%s, got %s/%s",
+						name, conditionType, expectStatus, expectReason, condition.Status, condition.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcilePolicyVolumes(t *testing.T) {
+	policy := &jivaAPI.JivaVolumePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: testNamespace},
+		Spec:       jivaAPI.JivaVolumePolicySpec{ReplicaSC: "openebs-hostpath"},
+	}
+	withPolicy := func(cr *jivaAPI.JivaVolume, name string) *jivaAPI.JivaVolume {
+		cr.Annotations = map[string]string{volumePolicyAnnotation: name}
+		return cr
+	}
+	otherNamespace := withPolicy(newTestVolume("pv-4", 1), "policy")
+	otherNamespace.Namespace = "default"
+	r := newTestPolicyReconciler(t, policy,
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "openebs-hostpath"}},
+		withPolicy(newTestVolume("pv-2", 1), "policy"),
+		withPolicy(newTestVolume("pv-1", 1), "policy"),
+		withPolicy(newTestVolume("pv-3", 1), "other"),
+		newTestVolume("pv-5", 1),
+		otherNamespace,
+	)
+
+	got := reconcileTestPolicy(t, r, policy.Name)
+	if !reflect.DeepEqual(got.Status.Volumes, []string{"pv-1", "pv-2"}) || got.Status.VolumeCount != 2 {
+		t.Fatalf("expected volumes [pv-1 pv-2], got %v with count %d",
+			got.Status.Volumes, got.Status.VolumeCount)
+	}
+
+	// the annotation of a volume is removed
+	cr := &jivaAPI.JivaVolume{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "pv-2", Namespace: testNamespace}, cr); err != nil {
+		t.Fatalf("failed to get volume pv-2: %v", err)
+	}
+	delete(cr.Annotations, volumePolicyAnnotation)
+	if err := r.Update(context.TODO(), cr); err != nil {
+		t.Fatalf("failed to update volume pv-2: %v", err)
+	}
+	got = reconcileTestPolicy(t, r, policy.Name)
+	if !reflect.DeepEqual(got.Status.Volumes, []string{"pv-1"}) || got.Status.VolumeCount != 1 {
+		t.Fatalf("expected volumes [pv-1], got %v with count %d",
+			got.Status.Volumes, got.Status.VolumeCount)
+	}
+
+	// the last volume using the policy is deleted
+	if err := r.Delete(context.TODO(), withPolicy(newTestVolume("pv-1", 1), "policy")); err != nil {
+		t.Fatalf("failed to delete volume pv-1: %v", err)
+	}
+	got = reconcileTestPolicy(t, r, policy.Name)
+	if got.Status.Volumes != nil || got.Status.VolumeCount != 0 {
+		t.Fatalf("expected no volumes, got %v with count %d",
+			got.Status.Volumes, got.Status.VolumeCount)
+	}
+}
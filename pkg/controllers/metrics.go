@@ -0,0 +1,312 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/utils"
+)
+
+const (
+	metricsNamespace = "jiva"
+	metricsSubsystem = "volume"
+)
+
+var (
+	// volumeLabels are the labels set on all the per volume metrics
+	volumeLabels = []string{"pv", "pvc", "namespace"}
+
+	replicaRebuildDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replica_rebuild_duration_seconds",
+			Help:      "Time taken by a replica to rebuild from the healthy replicas.",
+			// 30s to ~17h
+			Buckets: prometheus.ExponentialBuckets(30, 2, 12),
+		},
+		volumeLabels,
+	)
+
+	targetFailoverDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "target_failover_duration_seconds",
+			Help:      "Time taken by the target to be running again after its node went NotReady.",
+			// 5s to ~43m
+			Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+		},
+		volumeLabels,
+	)
+
+	volumePhase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "phase",
+			Help:      "Phase of the volume, set to 1 for the current phase.",
+		},
+		append(volumeLabels, "phase"),
+	)
+
+	volumeReplicasDesired = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replicas_desired",
+			Help:      "Number of replicas as per the replication factor of the volume.",
+		},
+		volumeLabels,
+	)
+
+	volumeReplicasConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replicas_connected",
+			Help:      "Number of replicas connected to the target.",
+		},
+		volumeLabels,
+	)
+
+	volumeReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replicas",
+			Help:      "Number of replicas connected to the target by replica mode.",
+		},
+		append(volumeLabels, "mode"),
+	)
+
+	volumeTargetStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "target_status",
+			Help:      "Status reported by the target, set to 1 for the current status.",
+		},
+		append(volumeLabels, "status"),
+	)
+
+	volumeCapacityBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "capacity_bytes",
+			Help:      "Capacity of the volume in bytes.",
+		},
+		volumeLabels,
+	)
+
+	bootstrapFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "bootstrap_failures_total",
+			Help:      "Number of failed attempts to create the components of the volume.",
+		},
+		volumeLabels,
+	)
+
+	replicaScaleups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replica_scaleups_total",
+			Help:      "Number of replicas added to the volume by the scaleup.",
+		},
+		volumeLabels,
+	)
+
+	replicaMovements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "replica_movements_total",
+			Help:      "Number of replicas moved off a missing node.",
+		},
+		volumeLabels,
+	)
+
+	versionReconciles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "version_reconciles_total",
+			Help:      "Number of upgrades and rollbacks of the volume completed.",
+		},
+		volumeLabels,
+	)
+
+	statsRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "stats_request_duration_seconds",
+			Help:      "Latency of the /stats requests to the target.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		volumeLabels,
+	)
+
+	volumePhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "phase_duration_seconds",
+			Help:      "Time spent by the volume in a phase before moving to another phase.",
+			// 1s to ~4.5d
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		},
+		append(volumeLabels, "phase"),
+	)
+
+	autoUpgradeVolumes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "auto_upgrade",
+			Name:      "volumes",
+			Help:      "Number of volumes by upgrade state as seen by the auto upgrade scheduler.",
+		},
+		[]string{"state"},
+	)
+
+	autoUpgradeStarted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "auto_upgrade",
+			Name:      "started_total",
+			Help:      "Number of volume upgrades started by the auto upgrade scheduler.",
+		},
+	)
+)
+
+func init() {
+	// register the metrics with the controller-runtime registry,
+	// which is served on the manager metrics endpoint
+	metrics.Registry.MustRegister(
+		volumePhase,
+		volumeReplicasDesired,
+		volumeReplicasConnected,
+		volumeReplicas,
+		volumeTargetStatus,
+		volumeCapacityBytes,
+		bootstrapFailures,
+		replicaScaleups,
+		replicaMovements,
+		versionReconciles,
+		statsRequestDuration,
+		volumePhaseDuration,
+		replicaRebuildDuration,
+		targetFailoverDuration,
+		autoUpgradeVolumes,
+		autoUpgradeStarted,
+	)
+	for _, m := range statsMetrics {
+		metrics.Registry.MustRegister(m)
+	}
+}
+
+// volumeLabelValues returns the values for volumeLabels of the given volume
+func volumeLabelValues(pv, pvc, namespace string) prometheus.Labels {
+	return prometheus.Labels{
+		"pv":        pv,
+		"pvc":       pvc,
+		"namespace": namespace,
+	}
+}
+
+// replicaModes are the replica modes reported in the replica metrics
+var replicaModes = []string{"RW", "WO", "ERR"}
+
+// recordVolumeMetrics sets the gauges of the volume from its status and
+// observes the time spent in the previous phase if the phase has changed
+func (r *JivaVolumeReconciler) recordVolumeMetrics(cr *jivaAPI.JivaVolume) {
+	labels := volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace)
+	match := prometheus.Labels{"pv": cr.Spec.PV, "namespace": cr.Namespace}
+
+	volumePhase.DeletePartialMatch(match)
+	volumePhase.With(withLabel(labels, "phase", string(cr.Status.Phase))).Set(1)
+	key := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+	if prev, changed := r.cache.setPhase(key, cr.Status.Phase); changed {
+		volumePhaseDuration.With(withLabel(labels, "phase", string(prev.phase))).
+			Observe(time.Since(prev.since).Seconds())
+	}
+
+	volumeReplicasDesired.With(labels).Set(float64(cr.Spec.Policy.Target.ReplicationFactor))
+	volumeReplicasConnected.With(labels).Set(float64(cr.Status.ReplicaCount))
+	modes := map[string]int{}
+	for _, rep := range cr.Status.ReplicaStatuses {
+		modes[rep.Mode]++
+	}
+	for _, mode := range replicaModes {
+		volumeReplicas.With(withLabel(labels, "mode", mode)).Set(float64(modes[mode]))
+	}
+
+	volumeTargetStatus.DeletePartialMatch(match)
+	if cr.Status.Status != "" {
+		volumeTargetStatus.With(withLabel(labels, "status", cr.Status.Status)).Set(1)
+	}
+
+	if capacity, err := utils.CapacityInBytes(cr.Spec.Capacity); err == nil {
+		volumeCapacityBytes.With(labels).Set(float64(capacity))
+	}
+}
+
+// deleteVolumeMetrics removes the metrics of a deleted volume, the name
+// of the volume is the same as the name of its persistent volume
+func deleteVolumeMetrics(key types.NamespacedName) {
+	match := prometheus.Labels{"pv": key.Name, "namespace": key.Namespace}
+	for _, vec := range []*prometheus.MetricVec{
+		volumePhase.MetricVec,
+		volumeReplicasDesired.MetricVec,
+		volumeReplicasConnected.MetricVec,
+		volumeReplicas.MetricVec,
+		volumeTargetStatus.MetricVec,
+		volumeCapacityBytes.MetricVec,
+		bootstrapFailures.MetricVec,
+		replicaScaleups.MetricVec,
+		replicaMovements.MetricVec,
+		versionReconciles.MetricVec,
+		statsRequestDuration.MetricVec,
+		volumePhaseDuration.MetricVec,
+		replicaRebuildDuration.MetricVec,
+		targetFailoverDuration.MetricVec,
+	} {
+		vec.DeletePartialMatch(match)
+	}
+	for _, m := range statsMetrics {
+		m.DeletePartialMatch(match)
+	}
+}
+
+// withLabel returns a copy of the labels with the given label added
+func withLabel(labels prometheus.Labels, name, value string) prometheus.Labels {
+	l := prometheus.Labels{name: value}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return l
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/types"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// histogramSampleCount returns the number of observations
+// of the histogram of the vector with the given labels
+func histogramSampleCount(t *testing.T, vec *prometheus.HistogramVec, labels prometheus.Labels) uint64 {
+	m := &dto.Metric{}
+	if err := vec.With(labels).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestRecordVolumeMetrics(t *testing.T) {
+	cr := newTestVolume("pv-metrics", 3)
+	cr.Labels = map[string]string{openebsPVC: "pvc-metrics"}
+	cr.Status.Phase = jivaAPI.JivaVolumePhasePending
+	cr.Status.ReplicaStatuses[2].Mode = "WO"
+	r := newTestReconciler(t, cr)
+	labels := volumeLabelValues(cr.Spec.PV, "pvc-metrics", cr.Namespace)
+	pending := withLabel(labels, "phase", string(jivaAPI.JivaVolumePhasePending))
+	ready := withLabel(labels, "phase", string(jivaAPI.JivaVolumePhaseReady))
+
+	r.recordVolumeMetrics(cr)
+	if got := testutil.ToFloat64(volumePhase.With(pending)); got != 1 {
+		t.Fatalf("expected phase %s to be 1, got %v", jivaAPI.JivaVolumePhasePending, got)
+	}
+	if got := testutil.ToFloat64(volumeReplicasDesired.With(labels)); got != 3 {
+		t.Fatalf("expected 3 desired replicas, got %v", got)
+	}
+	if got := testutil.ToFloat64(volumeReplicasConnected.With(labels)); got != 3 {
+		t.Fatalf("expected 3 connected replicas, got %v", got)
+	}
+	for mode, expect := range map[string]float64{"RW": 2, "WO": 1, "ERR": 0} {
+		if got := testutil.ToFloat64(volumeReplicas.With(withLabel(labels, "mode", mode))); got != expect {
+			t.Fatalf("expected %v replicas in mode %s, got %v", expect, mode, got)
+		}
+	}
+	if got := testutil.ToFloat64(volumeTargetStatus.With(withLabel(labels, "status", "RW"))); got != 1 {
+		t.Fatalf("expected target status RW to be 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(volumeCapacityBytes.With(labels)); got != 4<<30 {
+		t.Fatalf("expected capacity of 4Gi, got %v", got)
+	}
+	if got := histogramSampleCount(t, volumePhaseDuration, pending); got != 0 {
+		t.Fatalf("expected no phase duration observed for the first phase, got %d", got)
+	}
+
+	// the volume moves to the ready phase
+	cr.Status.Phase = jivaAPI.JivaVolumePhaseReady
+	r.recordVolumeMetrics(cr)
+	if volumePhase.Delete(pending) {
+		t.Fatalf("expected series of phase %s to be removed", jivaAPI.JivaVolumePhasePending)
+	}
+	if got := testutil.ToFloat64(volumePhase.With(ready)); got != 1 {
+		t.Fatalf("expected phase %s to be 1, got %v", jivaAPI.JivaVolumePhaseReady, got)
+	}
+	if got := histogramSampleCount(t, volumePhaseDuration, pending); got != 1 {
+		t.Fatalf("expected duration of phase %s to be observed once, got %d",
+			jivaAPI.JivaVolumePhasePending, got)
+	}
+
+	// the phase doesn't change
+	r.recordVolumeMetrics(cr)
+	if got := histogramSampleCount(t, volumePhaseDuration, pending); got != 1 {
+		t.Fatalf("expected duration of phase %s to be observed once, got %d",
+			jivaAPI.JivaVolumePhasePending, got)
+	}
+	if got := histogramSampleCount(t, volumePhaseDuration, ready); got != 0 {
+		t.Fatalf("expected no duration of the current phase observed, got %d", got)
+	}
+	deleteVolumeMetrics(types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace})
+}
+
+func TestDeleteVolumeMetrics(t *testing.T) {
+	deleted := newTestVolume("pv-deleted", 3)
+	kept := newTestVolume("pv-kept", 3)
+	r := newTestReconciler(t, deleted, kept)
+	for _, cr := range []*jivaAPI.JivaVolume{deleted, kept} {
+		labels := volumeLabelValues(cr.Spec.PV, "", cr.Namespace)
+		r.recordVolumeMetrics(cr)
+		bootstrapFailures.With(labels).Inc()
+		replicaScaleups.With(labels).Inc()
+		replicaMovements.With(labels).Inc()
+		versionReconciles.With(labels).Inc()
+		statsRequestDuration.With(labels).Observe(0.1)
+		volumePhaseDuration.With(withLabel(labels, "phase", "Pending")).Observe(1)
+		replicaRebuildDuration.With(labels).Observe(60)
+		targetFailoverDuration.With(labels).Observe(10)
+	}
+
+	deleteVolumeMetrics(types.NamespacedName{Name: deleted.Name, Namespace: deleted.Namespace})
+	vecs := map[string]*prometheus.MetricVec{
+		"phase":                            volumePhase.MetricVec,
+		"replicas_desired":                 volumeReplicasDesired.MetricVec,
+		"replicas_connected":               volumeReplicasConnected.MetricVec,
+		"replicas":                         volumeReplicas.MetricVec,
+		"target_status":                    volumeTargetStatus.MetricVec,
+		"capacity_bytes":                   volumeCapacityBytes.MetricVec,
+		"bootstrap_failures_total":         bootstrapFailures.MetricVec,
+		"replica_scaleups_total":           replicaScaleups.MetricVec,
+		"replica_movements_total":          replicaMovements.MetricVec,
+		"version_reconciles_total":         versionReconciles.MetricVec,
+		"stats_request_duration_seconds":   statsRequestDuration.MetricVec,
+		"phase_duration_seconds":           volumePhaseDuration.MetricVec,
+		"replica_rebuild_duration_seconds": replicaRebuildDuration.MetricVec,
+		"target_failover_duration_seconds": targetFailoverDuration.MetricVec,
+	}
+	for name, vec := range vecs {
+		// DeletePartialMatch returns the number of the matching series
+		if n := vec.DeletePartialMatch(prometheus.Labels{"pv": deleted.Name}); n != 0 {
+			t.Fatalf("expected series of %s of volume %s to be deleted, got %d", name, deleted.Name, n)
+		}
+		if n := vec.DeletePartialMatch(prometheus.Labels{"pv": kept.Name}); n == 0 {
+			t.Fatalf("expected series of %s of volume %s to be kept", name, kept.Name)
+		}
+	}
+}
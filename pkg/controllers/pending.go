@@ -0,0 +1,119 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// pendingTargetPod waits for the target pod to be running
+	// on a ready node so that its IP can be used for the stats
+	pendingTargetPod = "TargetPod"
+	// pendingReplicaMovement waits for a replica pod deleted
+	// from a missing node to be recreated by the statefulset
+	pendingReplicaMovement = "ReplicaMovement"
+	// pendingTargetFailover waits for the target pod force deleted
+	// from a NotReady node to be running on a ready node
+	pendingTargetFailover = "TargetFailover"
+	// pendingUnstage waits for a deleted volume to be
+	// unstaged from the node before it is torn down
+	pendingUnstage = "Unstage"
+
+	targetPodPendingMsg      = "waiting for the target pod to be running on a ready node"
+	targetFailoverPendingMsg = "waiting for the target pod to fail over to a ready node"
+	unstagePendingMsg        = "waiting for the volume to be unstaged"
+)
+
+// pendingActionIntervals are the intervals at which the volume is
+// reconciled again while an action of the given type is pending
+var pendingActionIntervals = map[string]time.Duration{
+	pendingTargetPod:       5 * time.Second,
+	pendingReplicaMovement: 10 * time.Second,
+	pendingTargetFailover:  5 * time.Second,
+	pendingUnstage:         30 * time.Second,
+}
+
+// getPendingAction returns the pending action of the given
+// type and target or nil if there is none
+func getPendingAction(cr *jivaAPI.JivaVolume, actionType, target string) *jivaAPI.PendingAction {
+	for i, a := range cr.Status.PendingActions {
+		if a.Type == actionType && a.Target == target {
+			return &cr.Status.PendingActions[i]
+		}
+	}
+	return nil
+}
+
+// addPendingAction records the action in the status of the volume
+// without updating the object, it returns true if the status changed.
+func addPendingAction(cr *jivaAPI.JivaVolume, actionType, target, msg string) bool {
+	if a := getPendingAction(cr, actionType, target); a != nil {
+		if a.Message == msg {
+			return false
+		}
+		a.Message = msg
+		return true
+	}
+	cr.Status.PendingActions = append(cr.Status.PendingActions, jivaAPI.PendingAction{
+		Type:    actionType,
+		Target:  target,
+		Message: msg,
+		Since:   metav1.Now(),
+	})
+	return true
+}
+
+// removePendingAction removes the action from the status of the volume
+// without updating the object, it returns true if the status changed.
+func removePendingAction(cr *jivaAPI.JivaVolume, actionType, target string) bool {
+	actions := []jivaAPI.PendingAction{}
+	for _, a := range cr.Status.PendingActions {
+		if a.Type != actionType || a.Target != target {
+			actions = append(actions, a)
+		}
+	}
+	if len(actions) == len(cr.Status.PendingActions) {
+		return false
+	}
+	if len(actions) == 0 {
+		actions = nil
+	}
+	cr.Status.PendingActions = actions
+	return true
+}
+
+// pendingActionsResult returns the result which requeues the volume
+// at the shortest interval of its pending actions.
+func pendingActionsResult(cr *jivaAPI.JivaVolume) reconcile.Result {
+	result := reconcile.Result{}
+	for _, a := range cr.Status.PendingActions {
+		interval, ok := pendingActionIntervals[a.Type]
+		if !ok {
+			continue
+		}
+		if result.RequeueAfter == 0 || interval < result.RequeueAfter {
+			result.RequeueAfter = interval
+		}
+	}
+	return result
+}
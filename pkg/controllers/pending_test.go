@@ -0,0 +1,101 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestPendingActionsResult(t *testing.T) {
+	tests := map[string]struct {
+		actions       []jivaAPI.PendingAction
+		expectRequeue time.Duration
+	}{
+		"Test volume without pending actions": {},
+		"Test volume with a single pending action": {
+			actions: []jivaAPI.PendingAction{
+				{Type: pendingUnstage, Target: "node-1"},
+			},
+			expectRequeue: 30 * time.Second,
+		},
+		"Test volume with multiple pending actions": {
+			actions: []jivaAPI.PendingAction{
+				{Type: pendingUnstage, Target: "node-1"},
+				{Type: pendingReplicaMovement, Target: "pv-jiva-rep-0"},
+				{Type: pendingTargetFailover, Target: "pv-jiva-ctrl-0"},
+			},
+			expectRequeue: 5 * time.Second,
+		},
+		"Test volume with an unknown pending action": {
+			actions: []jivaAPI.PendingAction{
+				{Type: "Unknown", Target: "node-1"},
+			},
+		},
+		"Test volume with unknown and known pending actions": {
+			actions: []jivaAPI.PendingAction{
+				{Type: "Unknown", Target: "node-1"},
+				{Type: pendingReplicaMovement, Target: "pv-jiva-rep-0"},
+			},
+			expectRequeue: 10 * time.Second,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 3)
+			cr.Status.PendingActions = mock.actions
+			result := pendingActionsResult(cr)
+			if result.RequeueAfter != mock.expectRequeue {
+				t.Fatalf("Test %q failed: expected requeue after %s, got %s",
+					name, mock.expectRequeue, result.RequeueAfter)
+			}
+			if result.Requeue {
+				t.Fatalf("Test %q failed: expected requeue to be false", name)
+			}
+		})
+	}
+}
+
+func TestPendingActionChanges(t *testing.T) {
+	cr := newTestVolume("pv", 3)
+
+	if !addPendingAction(cr, pendingTargetPod, "pv-jiva-ctrl-0", targetPodPendingMsg) {
+		t.Fatalf("expected adding a new pending action to change the status")
+	}
+	since := cr.Status.PendingActions[0].Since
+	if addPendingAction(cr, pendingTargetPod, "pv-jiva-ctrl-0", targetPodPendingMsg) {
+		t.Fatalf("expected adding an existing pending action not to change the status")
+	}
+	if !addPendingAction(cr, pendingTargetPod, "pv-jiva-ctrl-0", targetFailoverPendingMsg) {
+		t.Fatalf("expected updating the message of a pending action to change the status")
+	}
+	if len(cr.Status.PendingActions) != 1 || !cr.Status.PendingActions[0].Since.Equal(&since) {
+		t.Fatalf("expected the pending action to be updated in place, got %+v", cr.Status.PendingActions)
+	}
+	if removePendingAction(cr, pendingTargetPod, "pv-jiva-ctrl-1") {
+		t.Fatalf("expected removing a missing pending action not to change the status")
+	}
+	if !removePendingAction(cr, pendingTargetPod, "pv-jiva-ctrl-0") {
+		t.Fatalf("expected removing a pending action to change the status")
+	}
+	if cr.Status.PendingActions != nil {
+		t.Fatalf("expected no pending actions, got %+v", cr.Status.PendingActions)
+	}
+}
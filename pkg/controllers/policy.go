@@ -0,0 +1,155 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// volumePolicyAnnotation is the name of the
+	// JivaVolumePolicy used by the volume
+	volumePolicyAnnotation = "openebs.io/volume-policy"
+	// disablePolicyPropagationAnnotation if set to "true" on the
+	// JivaVolume, the policy changes are not applied to the volume
+	disablePolicyPropagationAnnotation = "openebs.io/disable-policy-propagation"
+)
+
+// volumesForPolicy maps a JivaVolumePolicy to the JivaVolumes provisioned
+// using the policy or a policy which inherits from it
+func (r *JivaVolumeReconciler) volumesForPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	policyList := &jivaAPI.JivaVolumePolicyList{}
+	if err := r.List(ctx, policyList, client.InNamespace(obj.GetNamespace())); err != nil {
+		logrus.Errorf("failed to list policies for policy %s: %v", obj.GetName(), err)
+		return nil
+	}
+	policies := map[string]*jivaAPI.JivaVolumePolicy{}
+	for i := range policyList.Items {
+		policies[policyList.Items[i].Name] = &policyList.Items[i]
+	}
+
+	volumes := &jivaAPI.JivaVolumeList{}
+	if err := r.List(ctx, volumes, client.InNamespace(obj.GetNamespace())); err != nil {
+		logrus.Errorf("failed to list volumes for policy %s: %v", obj.GetName(), err)
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, jv := range volumes.Items {
+		policyName := jv.Annotations[volumePolicyAnnotation]
+		if policyName != obj.GetName() && !policyInherits(policies[policyName], obj.GetName(), policies) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: jv.Name, Namespace: jv.Namespace},
+		})
+	}
+	return requests
+}
+
+// reconcilePolicy applies the changes made to the JivaVolumePolicy of
+// the volume or to its base policies. The replicas are restarted one at
+// a time followed by the target, the generations of the policies are
+// recorded in the status once the changes have been rolled out. The
+// replica storage class can't be changed for a provisioned volume and
+// an increase in the replication factor is performed as a scaleup. It
+// returns true once the policy has been applied.
+func (r *JivaVolumeReconciler) reconcilePolicy(cr *jivaAPI.JivaVolume) (bool, error) {
+	policyName := cr.Annotations[volumePolicyAnnotation]
+	if policyName == "" || cr.Annotations[disablePolicyPropagationAnnotation] == "true" {
+		return true, nil
+	}
+
+	policy := &jivaAPI.JivaVolumePolicy{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: policyName, Namespace: cr.Namespace}, policy)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	policySpec, generations, err := resolvePolicySpec(context.TODO(), r, policy)
+	if err != nil {
+		// the volume keeps the applied policy until the base policies are fixed
+		logrus.Warningf("failed to resolve policy %s of volume %s: %v", policyName, cr.Name, err)
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, "PolicyUpdate", "failed to resolve policy %s: %v", policyName, err)
+		return true, nil
+	}
+	if policy.Generation == cr.Status.PolicyGeneration &&
+		equality.Semantic.DeepEqual(generations, cr.Status.BasePolicyGenerations) {
+		return true, nil
+	}
+
+	SetPolicyDefaults(&policySpec)
+	desiredRF := cr.Spec.DesiredReplicationFactor
+	if policySpec.Target.ReplicationFactor > desiredRF {
+		desiredRF = policySpec.Target.ReplicationFactor
+	}
+	policySpec.Target.ReplicationFactor = cr.Spec.Policy.Target.ReplicationFactor
+	policySpec.ReplicaSC = cr.Spec.Policy.ReplicaSC
+	if policySpec.Target.DisableMonitor == nil {
+		// the volume keeps the sidecar setting it has been created with
+		policySpec.Target.DisableMonitor = cr.Spec.Policy.Target.DisableMonitor
+	}
+
+	if !equality.Semantic.DeepEqual(policySpec, cr.Spec.Policy) ||
+		desiredRF != cr.Spec.DesiredReplicationFactor {
+		logrus.Infof("applying changes of policy %s to volume %s", policyName, cr.Name)
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+			"PolicyUpdate", "applying generation %d of policy %s", policy.Generation, policyName)
+		cr.Spec.Policy = policySpec
+		cr.Spec.DesiredReplicationFactor = desiredRF
+		return false, r.updateJivaVolume(cr)
+	}
+	if isScaleup(cr) {
+		return false, nil
+	}
+
+	replicaSTS, err := buildReplicaStatefulSet(cr, cr.Spec.ISCSISpec.TargetIP)
+	if err != nil {
+		return false, err
+	}
+	done, err := r.rolloutReplicas(cr, withPodTemplate(&replicaSTS.Spec.Template))
+	if err != nil || !done {
+		return false, err
+	}
+
+	dep, err := buildControllerDeployment(cr)
+	if err != nil {
+		return false, err
+	}
+	done, err = r.rolloutTarget(cr, withPodTemplate(&dep.Spec.Template))
+	if err != nil || !done {
+		return false, err
+	}
+
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+		"PolicyUpdate", "applied generation %d of policy %s", policy.Generation, policyName)
+	cr.Status.PolicyGeneration = policy.Generation
+	cr.Status.BasePolicyGenerations = generations
+	return true, r.updateJivaVolume(cr)
+}
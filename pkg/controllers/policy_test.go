@@ -0,0 +1,140 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestReconcilePolicy(t *testing.T) {
+	tests := map[string]struct {
+		annotations       map[string]string
+		policy            *jivaAPI.JivaVolumePolicySpec
+		appliedGeneration int64
+		expectDone        bool
+		expectPriority    string
+		expectRF          int
+		// expectDesiredRF defaults to the replication factor
+		expectDesiredRF int
+	}{
+		"Test volume without policy": {
+			policy:     &jivaAPI.JivaVolumePolicySpec{PriorityClassName: "high"},
+			expectDone: true,
+			expectRF:   3,
+		},
+		"Test volume with propagation disabled": {
+			annotations: map[string]string{
+				volumePolicyAnnotation:             "policy",
+				disablePolicyPropagationAnnotation: "true",
+			},
+			policy:     &jivaAPI.JivaVolumePolicySpec{PriorityClassName: "high"},
+			expectDone: true,
+			expectRF:   3,
+		},
+		"Test volume whose policy is deleted": {
+			annotations: map[string]string{volumePolicyAnnotation: "policy"},
+			expectDone:  true,
+			expectRF:    3,
+		},
+		"Test volume with the policy generation applied": {
+			annotations:       map[string]string{volumePolicyAnnotation: "policy"},
+			policy:            &jivaAPI.JivaVolumePolicySpec{PriorityClassName: "high"},
+			appliedGeneration: 2,
+			expectDone:        true,
+			expectRF:          3,
+		},
+		"Test policy change applied to the volume spec": {
+			annotations:    map[string]string{volumePolicyAnnotation: "policy"},
+			policy:         &jivaAPI.JivaVolumePolicySpec{PriorityClassName: "high"},
+			expectPriority: "high",
+			expectRF:       3,
+		},
+		"Test replication factor increase applied as a scaleup": {
+			annotations: map[string]string{volumePolicyAnnotation: "policy"},
+			policy: &jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{ReplicationFactor: 5},
+			},
+			expectRF:        3,
+			expectDesiredRF: 5,
+		},
+		"Test storage class change ignored": {
+			annotations: map[string]string{volumePolicyAnnotation: "policy"},
+			policy: &jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC:         "other-sc",
+				PriorityClassName: "high",
+			},
+			expectPriority: "high",
+			expectRF:       3,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 3)
+			cr.Annotations = mock.annotations
+			cr.Spec.DesiredReplicationFactor = 3
+			cr.Status.PolicyGeneration = mock.appliedGeneration
+			SetPolicyDefaults(&cr.Spec.Policy)
+			objs := []client.Object{cr}
+			if mock.policy != nil {
+				objs = append(objs, &jivaAPI.JivaVolumePolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       "policy",
+						Namespace:  testNamespace,
+						Generation: 2,
+					},
+					Spec: *mock.policy,
+				})
+			}
+			r := newTestReconciler(t, objs...)
+
+			done, err := r.reconcilePolicy(cr)
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if done != mock.expectDone {
+				t.Fatalf("Test %q failed: expected done to be %t, got %t", name, mock.expectDone, done)
+			}
+
+			if mock.expectDesiredRF == 0 {
+				mock.expectDesiredRF = mock.expectRF
+			}
+			got := getTestVolume(t, r, cr.Name)
+			if got.Spec.Policy.PriorityClassName != mock.expectPriority {
+				t.Fatalf("Test %q failed: expected priority class %q, got %q",
+					name, mock.expectPriority, got.Spec.Policy.PriorityClassName)
+			}
+			if got.Spec.Policy.ReplicaSC != "openebs-hostpath" {
+				t.Fatalf("Test %q failed: expected storage class openebs-hostpath to be kept, got %q",
+					name, got.Spec.Policy.ReplicaSC)
+			}
+			if got.Spec.Policy.Target.ReplicationFactor != mock.expectRF {
+				t.Fatalf("Test %q failed: expected replication factor %d, got %d",
+					name, mock.expectRF, got.Spec.Policy.Target.ReplicationFactor)
+			}
+			if got.Spec.DesiredReplicationFactor != mock.expectDesiredRF {
+				t.Fatalf("Test %q failed: expected desired replication factor %d, got %d",
+					name, mock.expectDesiredRF, got.Spec.DesiredReplicationFactor)
+			}
+		})
+	}
+}
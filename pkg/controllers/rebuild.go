@@ -0,0 +1,164 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/openebs/jiva-operator/pkg/jiva"
+	"github.com/openebs/jiva-operator/pkg/volume"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// replicaRebuildInfoPath is the path of the replica REST API (port 9502)
+// which reports the sync progress of a replica in WO mode
+const replicaRebuildInfoPath = "/replicas/1/rebuildinfo"
+
+// rebuildInfoMinVersion is the first jiva release whose replicas serve
+// replicaRebuildInfoPath. The replicas of older volumes aren't queried,
+// only the start time of their rebuilds is tracked.
+const rebuildInfoMinVersion = "3.7.0"
+
+// replicaRequestTimeout bounds the requests to the replica REST API, so
+// that an unresponsive replica doesn't block the reconciliation
+const replicaRequestTimeout = 2 * time.Second
+
+// updateRebuildStatus sets the rebuild progress of the replicas in WO mode
+// and raises events when a rebuild starts or ends. prev is the replica
+// status of the volume observed during the previous reconciliation.
+func (r *JivaVolumeReconciler) updateRebuildStatus(ctx context.Context, cr *jivaAPI.JivaVolume, prev []jivaAPI.ReplicaStatus) {
+	prevStatuses := map[string]jivaAPI.ReplicaStatus{}
+	for _, rep := range prev {
+		prevStatuses[rep.Address] = rep
+	}
+
+	for i := range cr.Status.ReplicaStatuses {
+		rep := &cr.Status.ReplicaStatuses[i]
+		old, found := prevStatuses[rep.Address]
+		delete(prevStatuses, rep.Address)
+		rebuilding := found && old.Mode == "WO" && old.Rebuild != nil
+
+		switch {
+		case rep.Mode == "WO":
+			if !rebuilding {
+				old.Rebuild = nil
+				r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+					"ReplicaRebuild", "replica %s started rebuilding", rep.Address)
+				logrus.Infof("replica %s of volume %s started rebuilding", rep.Address, cr.Name)
+			}
+			if supportsRebuildInfo(cr) {
+				rep.Rebuild = getRebuildStatus(ctx, rep.Address, old.Rebuild)
+			} else {
+				rep.Rebuild = newRebuildStatus(old.Rebuild)
+			}
+		case rebuilding && rep.Mode == "RW":
+			duration := time.Since(old.Rebuild.StartTime.Time)
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal,
+				"ReplicaRebuild", "replica %s rebuild completed in %s",
+				rep.Address, duration.Round(time.Second))
+			logrus.Infof("replica %s of volume %s rebuild completed in %s",
+				rep.Address, cr.Name, duration.Round(time.Second))
+			replicaRebuildDuration.With(
+				volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+			).Observe(duration.Seconds())
+		case rebuilding:
+			r.Recorder.Eventf(cr, corev1.EventTypeWarning,
+				"ReplicaRebuild", "replica %s rebuild stopped, replica mode: %s",
+				rep.Address, rep.Mode)
+		}
+	}
+
+	// replicas which were rebuilding but are no
+	// longer connected to the target
+	for addr, old := range prevStatuses {
+		if old.Mode == "WO" && old.Rebuild != nil {
+			r.Recorder.Eventf(cr, corev1.EventTypeWarning,
+				"ReplicaRebuild", "replica %s rebuild stopped, replica disconnected", addr)
+		}
+	}
+}
+
+// supportsRebuildInfo checks if the replicas of the volume
+// run a jiva version which reports the rebuild progress
+func supportsRebuildInfo(cr *jivaAPI.JivaVolume) bool {
+	current, err := utilversion.ParseGeneric(cr.VersionDetails.Status.Current)
+	if err != nil {
+		return false
+	}
+	return current.AtLeast(utilversion.MustParseGeneric(rebuildInfoMinVersion))
+}
+
+// newRebuildStatus returns a copy of the progress observed during the
+// previous reconciliation or a new progress starting now if there is none
+func newRebuildStatus(prev *jivaAPI.RebuildStatus) *jivaAPI.RebuildStatus {
+	if prev != nil {
+		return prev.DeepCopy()
+	}
+	return &jivaAPI.RebuildStatus{
+		StartTime: metav1.Now(),
+	}
+}
+
+// getRebuildStatus fetches the rebuild progress of the replica at the given
+// address, prev is the progress observed during the previous reconciliation.
+func getRebuildStatus(ctx context.Context, address string, prev *jivaAPI.RebuildStatus) *jivaAPI.RebuildStatus {
+	now := metav1.Now()
+	status := newRebuildStatus(prev)
+
+	ctx, cancel := context.WithTimeout(ctx, replicaRequestTimeout)
+	defer cancel()
+
+	info := &volume.RebuildInfo{}
+	if err := jiva.NewReplicaClient(address).GetWithContext(ctx, replicaRebuildInfoPath, info); err != nil {
+		// keep the last known progress along with the start time
+		logrus.Debugf("failed to get rebuild info of replica %s: %v", address, err)
+		return status
+	}
+
+	var synced, total int64
+	for _, snap := range info.Snapshots {
+		rwSize, _ := snap.RWSize.Int64()
+		woSize, _ := snap.WOSize.Int64()
+		if woSize > rwSize {
+			woSize = rwSize
+		}
+		total += rwSize
+		synced += woSize
+	}
+
+	status.SyncedBytes = synced
+	status.TotalBytes = total
+	status.Percentage = 0
+	status.EstimatedFinishTime = nil
+	if total > 0 {
+		status.Percentage = int(synced * 100 / total)
+	}
+
+	elapsed := now.Sub(status.StartTime.Time)
+	if synced > 0 && total > synced && elapsed > 0 {
+		remaining := time.Duration(float64(elapsed) * float64(total-synced) / float64(synced))
+		eta := metav1.NewTime(now.Add(remaining))
+		status.EstimatedFinishTime = &eta
+	}
+	return status
+}
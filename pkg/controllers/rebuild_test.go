@@ -0,0 +1,152 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestGetRebuildStatus(t *testing.T) {
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	tests := map[string]struct {
+		response      string
+		statusCode    int
+		delay         time.Duration
+		prev          *jivaAPI.RebuildStatus
+		synced, total int64
+		percentage    int
+		expectETA     bool
+	}{
+		"Test rebuild progress of multiple snapshots": {
+			response: `{"snapshots":[{"name":"s1","rwSize":"100","woSize":"100"},{"name":"s2","rwSize":"100","woSize":"50"}]}`,
+			prev:     &jivaAPI.RebuildStatus{StartTime: startTime},
+			synced:   150, total: 200, percentage: 75,
+			expectETA: true,
+		},
+		"Test synced size larger than the snapshot size": {
+			response: `{"snapshots":[{"name":"s1","rwSize":"100","woSize":"120"}]}`,
+			prev:     &jivaAPI.RebuildStatus{StartTime: startTime},
+			synced:   100, total: 100, percentage: 100,
+		},
+		"Test rebuild which has just started": {
+			response: `{"snapshots":[{"name":"s1","rwSize":"100","woSize":"0"}]}`,
+			synced:   0, total: 100, percentage: 0,
+		},
+		"Test replica which doesn't report the rebuild progress": {
+			response: `404 page not found`,
+			prev:     &jivaAPI.RebuildStatus{StartTime: startTime, SyncedBytes: 10, TotalBytes: 100, Percentage: 10},
+			synced:   10, total: 100, percentage: 10,
+		},
+		"Test replica which fails the request": {
+			response:   `{"snapshots":[]}`,
+			statusCode: http.StatusInternalServerError,
+			prev:       &jivaAPI.RebuildStatus{StartTime: startTime, SyncedBytes: 10, TotalBytes: 100, Percentage: 10},
+			synced:     10, total: 100, percentage: 10,
+		},
+		"Test replica which doesn't respond in time": {
+			response: `{"snapshots":[{"name":"s1","rwSize":"100","woSize":"100"}]}`,
+			delay:    5 * time.Second,
+			prev:     &jivaAPI.RebuildStatus{StartTime: startTime, SyncedBytes: 10, TotalBytes: 100, Percentage: 10},
+			synced:   10, total: 100, percentage: 10,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v1"+replicaRebuildInfoPath {
+					http.NotFound(w, req)
+					return
+				}
+				select {
+				case <-time.After(mock.delay):
+				case <-req.Context().Done():
+					return
+				}
+				if mock.statusCode != 0 {
+					w.WriteHeader(mock.statusCode)
+				}
+				_, _ = w.Write([]byte(mock.response))
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			status := getRebuildStatus(ctx, server.URL, mock.prev)
+			if time.Since(start) > time.Second {
+				t.Fatalf("Test %q failed: request wasn't cancelled with the context", name)
+			}
+			if status.SyncedBytes != mock.synced || status.TotalBytes != mock.total ||
+				status.Percentage != mock.percentage {
+				t.Fatalf("Test %q failed: expected %d/%d bytes (%d%%) to be synced, got %d/%d bytes (%d%%)",
+					name, mock.synced, mock.total, mock.percentage,
+					status.SyncedBytes, status.TotalBytes, status.Percentage)
+			}
+			if mock.prev != nil && !status.StartTime.Equal(&mock.prev.StartTime) {
+				t.Fatalf("Test %q failed: expected start time %v to be kept, got %v",
+					name, mock.prev.StartTime, status.StartTime)
+			}
+			if mock.expectETA != (status.EstimatedFinishTime != nil) {
+				t.Fatalf("Test %q failed: expected estimated finish time to be set: %t, got %v",
+					name, mock.expectETA, status.EstimatedFinishTime)
+			}
+		})
+	}
+}
+
+func TestSupportsRebuildInfo(t *testing.T) {
+	tests := map[string]struct {
+		version       string
+		expectSupport bool
+	}{
+		"Test volume without version": {},
+		"Test volume with an older version": {
+			version: "3.6.0",
+		},
+		"Test volume with the minimum version": {
+			version:       rebuildInfoMinVersion,
+			expectSupport: true,
+		},
+		"Test volume with a newer pre-release version": {
+			version:       "4.0.0-RC1",
+			expectSupport: true,
+		},
+		"Test volume with an invalid version": {
+			version: "latest",
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := &jivaAPI.JivaVolume{}
+			cr.VersionDetails.Status.Current = mock.version
+			if got := supportsRebuildInfo(cr); got != mock.expectSupport {
+				t.Fatalf("Test %q failed: expected support of the rebuild info: %t, got: %t",
+					name, mock.expectSupport, got)
+			}
+		})
+	}
+}
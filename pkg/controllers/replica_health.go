@@ -0,0 +1,199 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// DefaultReplicaFailureGracePeriod is the duration for which a replica
+	// is allowed to stay unhealthy before it is replaced
+	DefaultReplicaFailureGracePeriod = 10 * time.Minute
+
+	replicaFailureErr         = "ReplicaInErrMode"
+	replicaFailureMissing     = "ReplicaNotConnected"
+	replicaFailureCrashLoop   = "ReplicaCrashLooping"
+	replicaVolumeName         = "openebs"
+	crashLoopBackOffWaitState = "CrashLoopBackOff"
+)
+
+// replicaFailureReason returns the reason a replica pod is considered
+// unhealthy or empty string if it is healthy. replicaModes are the
+// replica modes reported by the target keyed by the replica pod IP.
+func replicaFailureReason(pod *corev1.Pod, replicaModes map[string]string) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == crashLoopBackOffWaitState {
+			return replicaFailureCrashLoop
+		}
+	}
+	// pending pods are handled as part of replica movement
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return ""
+	}
+	mode, ok := replicaModes[pod.Status.PodIP]
+	if !ok {
+		return replicaFailureMissing
+	}
+	if mode == "ERR" {
+		return replicaFailureErr
+	}
+	return ""
+}
+
+// replaceFailedReplicas keeps track of the replicas which are in ERR mode,
+// are not connected to the target or are crash looping. If such a replica
+// does not recover within the failure grace period, its PVC & PV are
+// removed and the pod is deleted so that it gets recreated and rebuilt
+// from the healthy replicas. Replicas are replaced one at a time and only
+// if the volume has quorum number of replicas in RW mode.
+func (r *JivaVolumeReconciler) replaceFailedReplicas(cr *jivaAPI.JivaVolume) error {
+	// the replica modes can't be trusted if the target
+	// is not reachable or the volume has lost quorum
+	if !isHAVolume(cr) {
+		return nil
+	}
+
+	pods, err := r.listReplicaPods(cr)
+	if err != nil {
+		return err
+	}
+
+	replicaModes := map[string]string{}
+	rebuilding := false
+	for _, rep := range cr.Status.ReplicaStatuses {
+		replicaModes[replicaIP(rep.Address)] = rep.Mode
+		if rep.Mode == "WO" {
+			rebuilding = true
+		}
+	}
+
+	prevFailures := map[string]jivaAPI.FailedReplica{}
+	for _, f := range cr.Status.FailedReplicas {
+		prevFailures[f.PodName] = f
+	}
+
+	now := metav1.Now()
+	failures := []jivaAPI.FailedReplica{}
+	var (
+		toReplace *corev1.Pod
+		replaced  jivaAPI.FailedReplica
+	)
+	for i, pod := range pods.Items {
+		reason := replicaFailureReason(&pod, replicaModes)
+		if reason == "" {
+			continue
+		}
+		failure := jivaAPI.FailedReplica{
+			PodName: pod.Name,
+			Reason:  reason,
+			Since:   now,
+		}
+		if prev, ok := prevFailures[pod.Name]; ok {
+			failure.Since = prev.Since
+		} else {
+			r.Recorder.Eventf(cr, corev1.EventTypeWarning, "ReplicaHealth",
+				"replica %s is unhealthy: %s", pod.Name, reason)
+		}
+		// replace only one replica at a time and wait for
+		// the ongoing rebuilds to complete
+		if toReplace == nil && !rebuilding &&
+			now.Sub(failure.Since.Time) >= r.replicaFailureGracePeriod() {
+			toReplace = &pods.Items[i]
+			replaced = failure
+			continue
+		}
+		failures = append(failures, failure)
+	}
+
+	if toReplace != nil {
+		if err := r.replaceReplica(cr, toReplace); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, "ReplicaReplacement",
+			"replica %s unhealthy since %s: %s, replaced with a new replica",
+			toReplace.Name, replaced.Since.Format(time.RFC3339), replaced.Reason)
+		cr.Status.ReplacedReplicaCount++
+	}
+
+	if len(failures) == 0 {
+		failures = nil
+	}
+	if toReplace == nil && equality.Semantic.DeepEqual(failures, cr.Status.FailedReplicas) {
+		return nil
+	}
+	cr.Status.FailedReplicas = failures
+	return r.updateJivaVolume(cr)
+}
+
+// replaceReplica removes the PVC & PV of the given replica and deletes the
+// pod, the statefulset recreates the pod with a new PVC which is rebuilt
+// from the healthy replicas.
+func (r *JivaVolumeReconciler) replaceReplica(cr *jivaAPI.JivaVolume, pod *corev1.Pod) error {
+	logrus.Infof("replacing replica %s of volume %s", pod.Name, cr.Name)
+	claimName := ""
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Name == replicaVolumeName && vol.PersistentVolumeClaim != nil {
+			claimName = vol.PersistentVolumeClaim.ClaimName
+		}
+	}
+	if claimName != "" {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Get(context.TODO(),
+			types.NamespacedName{Name: claimName, Namespace: pod.Namespace}, pvc)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			if err := r.removeSTSVolume(pvc); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *JivaVolumeReconciler) replicaFailureGracePeriod() time.Duration {
+	if r.ReplicaFailureGracePeriod <= 0 {
+		return DefaultReplicaFailureGracePeriod
+	}
+	return r.ReplicaFailureGracePeriod
+}
+
+// replicaIP returns the IP from the replica address reported
+// by the target, i.e. tcp://<replica-ip>:9502
+func replicaIP(address string) string {
+	addr := strings.TrimPrefix(address, "tcp://")
+	if i := strings.LastIndex(addr, ":"); i != -1 {
+		addr = addr[:i]
+	}
+	return addr
+}
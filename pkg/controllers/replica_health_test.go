@@ -0,0 +1,169 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestReplaceFailedReplicas(t *testing.T) {
+	tests := map[string]struct {
+		rf int
+		// modes overrides the mode of the replicas by ordinal,
+		// an empty mode removes the replica from the target
+		modes map[int]string
+		// failedFor is the time the replicas were previously
+		// observed to be unhealthy, keyed by ordinal
+		failedFor     map[int]time.Duration
+		expectFailed  []string
+		expectDeleted []int
+	}{
+		"Test healthy replicas": {
+			rf: 3,
+		},
+		"Test replica in ERR mode observed for the first time": {
+			rf:           3,
+			modes:        map[int]string{2: "ERR"},
+			expectFailed: []string{"pv-jiva-rep-2"},
+		},
+		"Test replica in ERR mode within the grace period": {
+			rf:           3,
+			modes:        map[int]string{2: "ERR"},
+			failedFor:    map[int]time.Duration{2: time.Minute},
+			expectFailed: []string{"pv-jiva-rep-2"},
+		},
+		"Test replica not connected beyond the grace period": {
+			rf:            3,
+			modes:         map[int]string{2: ""},
+			failedFor:     map[int]time.Duration{2: time.Hour},
+			expectDeleted: []int{2},
+		},
+		"Test recovered replica": {
+			rf:        3,
+			failedFor: map[int]time.Duration{2: time.Hour},
+		},
+		"Test failed replica beyond the grace period while a replica rebuilds": {
+			rf:           3,
+			modes:        map[int]string{1: "WO", 2: "ERR"},
+			failedFor:    map[int]time.Duration{2: time.Hour},
+			expectFailed: []string{"pv-jiva-rep-2"},
+		},
+		"Test multiple failed replicas beyond the grace period": {
+			rf:            5,
+			modes:         map[int]string{3: "ERR", 4: "ERR"},
+			failedFor:     map[int]time.Duration{3: time.Hour, 4: time.Hour},
+			expectFailed:  []string{"pv-jiva-rep-4"},
+			expectDeleted: []int{3},
+		},
+		"Test failed replicas of a volume without quorum": {
+			rf:        3,
+			modes:     map[int]string{1: "ERR", 2: "ERR"},
+			failedFor: map[int]time.Duration{1: time.Hour, 2: time.Hour},
+			expectFailed: []string{
+				"pv-jiva-rep-1", "pv-jiva-rep-2",
+			},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", mock.rf)
+			statuses := []jivaAPI.ReplicaStatus{}
+			for i, rep := range cr.Status.ReplicaStatuses {
+				mode, ok := mock.modes[i]
+				if ok && mode == "" {
+					continue
+				}
+				if ok {
+					rep.Mode = mode
+				}
+				statuses = append(statuses, rep)
+			}
+			cr.Status.ReplicaStatuses = statuses
+			failedFor := map[string]time.Duration{}
+			for i := 0; i < mock.rf; i++ {
+				d, ok := mock.failedFor[i]
+				if !ok {
+					continue
+				}
+				podName := fmt.Sprintf("%s-jiva-rep-%d", cr.Name, i)
+				failedFor[podName] = d
+				cr.Status.FailedReplicas = append(cr.Status.FailedReplicas, jivaAPI.FailedReplica{
+					PodName: podName,
+					Reason:  replicaFailureErr,
+					Since:   metav1.NewTime(time.Now().Add(-d)),
+				})
+			}
+
+			objs := []client.Object{cr}
+			for i := 0; i < mock.rf; i++ {
+				pod, pvc := newTestReplica(cr, i)
+				objs = append(objs, pod, pvc)
+			}
+			r := newTestReconciler(t, objs...)
+			r.ReplicaFailureGracePeriod = 10 * time.Minute
+
+			if err := r.replaceFailedReplicas(cr); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+
+			got := getTestVolume(t, r, cr.Name)
+			failed := []string{}
+			for _, f := range got.Status.FailedReplicas {
+				failed = append(failed, f.PodName)
+				if d, ok := failedFor[f.PodName]; ok && time.Since(f.Since.Time) < d-time.Second {
+					t.Fatalf("Test %q failed: expected failure time of %s to be kept, got %v",
+						name, f.PodName, f.Since)
+				}
+			}
+			if len(failed) != len(mock.expectFailed) {
+				t.Fatalf("Test %q failed: expected failed replicas %v, got %v", name, mock.expectFailed, failed)
+			}
+			for i := range failed {
+				if failed[i] != mock.expectFailed[i] {
+					t.Fatalf("Test %q failed: expected failed replicas %v, got %v", name, mock.expectFailed, failed)
+				}
+			}
+
+			deleted := map[int]bool{}
+			for _, i := range mock.expectDeleted {
+				deleted[i] = true
+			}
+			for i := 0; i < mock.rf; i++ {
+				pod, pvc := newTestReplica(cr, i)
+				if objectExists(t, r, pod.Name, pod.Namespace, &corev1.Pod{}) == deleted[i] {
+					t.Fatalf("Test %q failed: expected pod %s to be deleted: %t", name, pod.Name, deleted[i])
+				}
+				if objectExists(t, r, pvc.Name, pvc.Namespace, &corev1.PersistentVolumeClaim{}) == deleted[i] {
+					t.Fatalf("Test %q failed: expected pvc %s to be deleted: %t", name, pvc.Name, deleted[i])
+				}
+			}
+			if got.Status.ReplacedReplicaCount != len(mock.expectDeleted) {
+				t.Fatalf("Test %q failed: expected %d replaced replicas, got %d",
+					name, len(mock.expectDeleted), got.Status.ReplacedReplicaCount)
+			}
+		})
+	}
+}
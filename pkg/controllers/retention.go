@@ -0,0 +1,193 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openebs/jiva-operator/pkg/kubernetes/container"
+	pts "github.com/openebs/jiva-operator/pkg/kubernetes/podtemplatespec"
+	"github.com/openebs/jiva-operator/pkg/kubernetes/volume"
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// retainedReplicaLabel is set on the replica PVCs retained after the
+	// deletion of the volume, the value is the name of the volume
+	retainedReplicaLabel = "openebs.io/retained-replica"
+
+	wipeJobBackoffLimit = 3
+	// wipeCommand zeroes the allocated extents of the replica files in
+	// place before removing them. The replica files are sparse and sized
+	// to the volume, so only the extents reported by filefrag are written
+	// and the files never grow beyond the space they already use on the
+	// node. The job fails, and the pvc is kept, if filefrag is missing.
+	wipeCommand = `set -e
+command -v filefrag >/dev/null || { echo "filefrag is required to wipe the replica" >&2; exit 1; }
+find /openebs -mindepth 1 -type f | while read -r file; do
+  filefrag -v -b4096 "$file" |
+    awk '{ gsub(/\.\./, " "); gsub(/:/, " "); if ($1 ~ /^[0-9]+$/ && NF >= 6) print $2, $3 - $2 + 1 }' |
+    while read -r start count; do
+      dd if=/dev/zero of="$file" bs=4096 seek="$start" count="$count" conv=notrunc 2>/dev/null
+    done
+done
+sync
+find /openebs -mindepth 1 -delete`
+)
+
+// retainReplicaVolume detaches the replica PVC from the volume, so
+// that it is not garbage collected, and labels it for later adoption
+func (r *JivaVolumeReconciler) retainReplicaVolume(cr *jivaAPI.JivaVolume, pvc *corev1.PersistentVolumeClaim) error {
+	newPVC := pvc.DeepCopy()
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ref := range newPVC.OwnerReferences {
+		if ref.UID != cr.UID {
+			ownerRefs = append(ownerRefs, ref)
+		}
+	}
+	newPVC.OwnerReferences = ownerRefs
+	if newPVC.Labels == nil {
+		newPVC.Labels = map[string]string{}
+	}
+	newPVC.Labels[retainedReplicaLabel] = cr.Name
+	if err := r.Patch(context.TODO(), newPVC, client.MergeFrom(pvc)); err != nil {
+		return err
+	}
+	logrus.Infof("retained replica pvc %s of volume %s", pvc.Name, cr.Name)
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, teardownReason, "retained replica pvc %s", pvc.Name)
+	return nil
+}
+
+// wipeReplicaVolume runs a job on the node of the replica which overwrites
+// the replica data, it returns true once the job has succeeded.
+func (r *JivaVolumeReconciler) wipeReplicaVolume(cr *jivaAPI.JivaVolume, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.VolumeName == "" {
+		// nothing to wipe for an unbound pvc
+		return true, nil
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: wipeJobName(pvc), Namespace: pvc.Namespace}, job)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		job, err = buildWipeJob(cr, pvc)
+		if err != nil {
+			return false, err
+		}
+		if err := controllerutil.SetControllerReference(cr, job, r.Scheme); err != nil {
+			return false, err
+		}
+		logrus.Infof("wiping replica pvc %s of volume %s", pvc.Name, cr.Name)
+		if err := r.Create(context.TODO(), job); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, teardownReason, "wiping replica pvc %s", pvc.Name)
+		return false, nil
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			// the pvc is not deleted so that the
+			// data can be wiped manually
+			r.Recorder.Eventf(cr, corev1.EventTypeWarning, teardownReason,
+				"failed to wipe replica pvc %s: %s", pvc.Name, cond.Message)
+		}
+	}
+	return false, nil
+}
+
+func wipeJobName(pvc *corev1.PersistentVolumeClaim) string {
+	return "wipe-" + pvc.Spec.VolumeName
+}
+
+// buildWipeJob builds the job which erases the data of the replica
+// pvc, the job is scheduled on the node of the replica by the node
+// affinity of the local PV bound to the pvc.
+func buildWipeJob(cr *jivaAPI.JivaVolume, pvc *corev1.PersistentVolumeClaim) (*batchv1.Job, error) {
+	backoffLimit := int32(wipeJobBackoffLimit)
+	ptsBuilder := pts.NewBuilder().
+		WithLabels(defaultReplicaMatchLabels(cr.Spec.PV)).
+		WithServiceAccountName(defaultServiceAccountName).
+		WithContainerBuilders(
+			container.NewBuilder().
+				WithName("wipe").
+				WithImage(policyImage(cr.Spec.Policy.Replica.Image,
+					"OPENEBS_IO_JIVA_REPLICA_IMAGE", "jiva-replica")).
+				WithImagePullPolicy(imagePullPolicy(cr.Spec.Policy.Replica.PodTemplateResources)).
+				WithCommandNew([]string{"sh", "-c"}).
+				WithArgumentsNew([]string{wipeCommand}).
+				WithVolumeMountsNew([]corev1.VolumeMount{
+					{
+						Name:      replicaVolumeName,
+						MountPath: "/openebs",
+					},
+				}),
+		).
+		WithVolumeBuilders(
+			volume.NewBuilder().
+				WithName(replicaVolumeName).
+				WithPVCSource(pvc.Name),
+		)
+	if len(cr.Spec.Policy.Replica.Tolerations) != 0 {
+		ptsBuilder = ptsBuilder.WithTolerations(cr.Spec.Policy.Replica.Tolerations...)
+	}
+	if len(cr.Spec.Policy.ServiceAccountName) != 0 {
+		ptsBuilder = ptsBuilder.WithServiceAccountName(cr.Spec.Policy.ServiceAccountName)
+	}
+	if len(cr.Spec.Policy.Replica.ImagePullSecrets) != 0 {
+		ptsBuilder = ptsBuilder.WithImagePullSecrets(cr.Spec.Policy.Replica.ImagePullSecrets...)
+	}
+	template, err := ptsBuilder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build wipe job object, err: %v", err)
+	}
+	// the component label is replaced so that the
+	// job pods are not considered as replica pods
+	template.Object.Labels["openebs.io/component"] = "jiva-replica-wipe"
+	template.Object.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wipeJobName(pvc),
+			Namespace: pvc.Namespace,
+			Labels:    template.Object.Labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     *template.Object,
+		},
+	}, nil
+}
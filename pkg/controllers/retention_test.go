@@ -0,0 +1,233 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRetainReplicaVolume(t *testing.T) {
+	otherRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: "other", UID: "other-uid"}
+	tests := map[string]struct {
+		labels          map[string]string
+		ownerRefs       func(volumeRef metav1.OwnerReference) []metav1.OwnerReference
+		expectOwnerRefs []metav1.OwnerReference
+	}{
+		"Test pvc owned by the volume": {
+			ownerRefs: func(volumeRef metav1.OwnerReference) []metav1.OwnerReference {
+				return []metav1.OwnerReference{volumeRef}
+			},
+		},
+		"Test pvc owned by the volume and another object": {
+			ownerRefs: func(volumeRef metav1.OwnerReference) []metav1.OwnerReference {
+				return []metav1.OwnerReference{otherRef, volumeRef}
+			},
+			expectOwnerRefs: []metav1.OwnerReference{otherRef},
+		},
+		"Test pvc without labels": {
+			labels: map[string]string{},
+			ownerRefs: func(volumeRef metav1.OwnerReference) []metav1.OwnerReference {
+				return []metav1.OwnerReference{volumeRef}
+			},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			cr.UID = "volume-uid"
+			_, pvc := newTestReplica(cr, 0)
+			if mock.labels != nil {
+				pvc.Labels = mock.labels
+			}
+			pvc.OwnerReferences = mock.ownerRefs(metav1.OwnerReference{
+				APIVersion: "openebs.io/v1", Kind: "JivaVolume", Name: cr.Name, UID: cr.UID,
+			})
+			r := newTestReconciler(t, cr, pvc)
+
+			if err := r.retainReplicaVolume(cr, pvc); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			got := &corev1.PersistentVolumeClaim{}
+			if !objectExists(t, r, pvc.Name, pvc.Namespace, got) {
+				t.Fatalf("Test %q failed: expected retained pvc to exist", name)
+			}
+			if len(got.OwnerReferences) != 0 || len(mock.expectOwnerRefs) != 0 {
+				if !reflect.DeepEqual(got.OwnerReferences, mock.expectOwnerRefs) {
+					t.Fatalf("Test %q failed: expected owner references %v, got %v",
+						name, mock.expectOwnerRefs, got.OwnerReferences)
+				}
+			}
+			if got.Labels[retainedReplicaLabel] != cr.Name {
+				t.Fatalf("Test %q failed: expected label %s=%s, got labels %v",
+					name, retainedReplicaLabel, cr.Name, got.Labels)
+			}
+		})
+	}
+}
+
+// setTestServiceAccount sets the service account of the operator which
+// is set from the environment of the operator pod, for the test
+func setTestServiceAccount(t *testing.T) {
+	serviceAccount := defaultServiceAccountName
+	defaultServiceAccountName = "openebs-jiva-operator"
+	t.Cleanup(func() { defaultServiceAccountName = serviceAccount })
+}
+
+func TestBuildWipeJob(t *testing.T) {
+	setTestServiceAccount(t)
+	cr := newTestVolume("pv", 1)
+	cr.Spec.Policy.ServiceAccountName = "jiva-sa"
+	cr.Spec.Policy.Replica.Tolerations = []corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+	_, pvc := newTestReplica(cr, 0)
+	pvc.Spec.VolumeName = "pvc-0"
+
+	job, err := buildWipeJob(cr, pvc)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	if job.Name != "wipe-pvc-0" || job.Namespace != pvc.Namespace {
+		t.Fatalf("expected job %s/wipe-pvc-0, got %s/%s", pvc.Namespace, job.Namespace, job.Name)
+	}
+	if job.Spec.BackoffLimit == nil || *job.Spec.BackoffLimit != wipeJobBackoffLimit {
+		t.Fatalf("expected backoff limit %d, got %v", wipeJobBackoffLimit, job.Spec.BackoffLimit)
+	}
+	template := job.Spec.Template
+	if template.Labels["openebs.io/component"] != "jiva-replica-wipe" {
+		t.Fatalf("expected job pods not to be labelled as replica pods, got labels %v", template.Labels)
+	}
+	if template.Spec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		t.Fatalf("expected restart policy %s, got %s", corev1.RestartPolicyOnFailure, template.Spec.RestartPolicy)
+	}
+	if template.Spec.ServiceAccountName != "jiva-sa" {
+		t.Fatalf("expected service account jiva-sa, got %s", template.Spec.ServiceAccountName)
+	}
+	if !reflect.DeepEqual(template.Spec.Tolerations, cr.Spec.Policy.Replica.Tolerations) {
+		t.Fatalf("expected replica tolerations %v, got %v",
+			cr.Spec.Policy.Replica.Tolerations, template.Spec.Tolerations)
+	}
+	if template.Spec.NodeName != "" || template.Spec.NodeSelector != nil {
+		t.Fatalf("expected job to be scheduled by the node affinity of the replica volume")
+	}
+	if len(template.Spec.Volumes) != 1 || template.Spec.Volumes[0].PersistentVolumeClaim == nil ||
+		template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != pvc.Name {
+		t.Fatalf("expected job to mount the replica pvc %s, got volumes %v", pvc.Name, template.Spec.Volumes)
+	}
+	if len(template.Spec.Containers) != 1 {
+		t.Fatalf("expected a single container, got %d", len(template.Spec.Containers))
+	}
+	c := template.Spec.Containers[0]
+	if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].MountPath != "/openebs" ||
+		c.VolumeMounts[0].Name != template.Spec.Volumes[0].Name {
+		t.Fatalf("expected replica pvc to be mounted at /openebs, got %v", c.VolumeMounts)
+	}
+	if !reflect.DeepEqual(append(c.Command, c.Args...), []string{"sh", "-c", wipeCommand}) {
+		t.Fatalf("expected container to run the wipe command, got %v %v", c.Command, c.Args)
+	}
+}
+
+func TestWipeReplicaVolume(t *testing.T) {
+	setTestServiceAccount(t)
+	tests := map[string]struct {
+		unbound     bool
+		jobExists   bool
+		condition   batchv1.JobConditionType
+		expectWiped bool
+		expectJob   bool
+		expectEvent bool
+	}{
+		"Test unbound pvc": {
+			unbound:     true,
+			expectWiped: true,
+		},
+		"Test pvc to be wiped": {
+			expectJob:   true,
+			expectEvent: true,
+		},
+		"Test pvc being wiped": {
+			jobExists: true,
+			expectJob: true,
+		},
+		"Test pvc wiped": {
+			jobExists:   true,
+			condition:   batchv1.JobComplete,
+			expectWiped: true,
+			expectJob:   true,
+		},
+		"Test pvc which failed to be wiped": {
+			jobExists:   true,
+			condition:   batchv1.JobFailed,
+			expectJob:   true,
+			expectEvent: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 1)
+			cr.UID = "volume-uid"
+			_, pvc := newTestReplica(cr, 0)
+			if !mock.unbound {
+				pvc.Spec.VolumeName = "pvc-0"
+			}
+			r := newTestReconciler(t, cr, pvc)
+			if mock.jobExists {
+				job, err := buildWipeJob(cr, pvc)
+				if err != nil {
+					t.Fatalf("Test %q failed: failed to build job: %v", name, err)
+				}
+				if mock.condition != "" {
+					job.Status.Conditions = []batchv1.JobCondition{
+						{Type: mock.condition, Status: corev1.ConditionTrue},
+					}
+				}
+				if err := r.Create(context.TODO(), job); err != nil {
+					t.Fatalf("Test %q failed: failed to create job: %v", name, err)
+				}
+			}
+
+			wiped, err := r.wipeReplicaVolume(cr, pvc)
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if wiped != mock.expectWiped {
+				t.Fatalf("Test %q failed: expected pvc to be wiped: %t, got: %t", name, mock.expectWiped, wiped)
+			}
+			job := &batchv1.Job{}
+			err = r.Get(context.TODO(), types.NamespacedName{Name: "wipe-pvc-0", Namespace: pvc.Namespace}, job)
+			if (err == nil) != mock.expectJob {
+				t.Fatalf("Test %q failed: expected wipe job to exist: %t, got: %v", name, mock.expectJob, err)
+			}
+			if mock.expectJob && !mock.jobExists && !metav1.IsControlledBy(job, cr) {
+				t.Fatalf("Test %q failed: expected wipe job to be owned by the volume", name)
+			}
+			if events := len(r.Recorder.(*record.FakeRecorder).Events); (events != 0) != mock.expectEvent {
+				t.Fatalf("Test %q failed: expected event: %t, got %d events", name, mock.expectEvent, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// podTemplateMutator applies the desired changes to
+// the pod template of the target or the replicas
+type podTemplateMutator func(template *corev1.PodTemplateSpec)
+
+// policyMetadataAnnotation records the keys of the labels and the
+// annotations of the pod template which were set by the policy, so
+// that the keys removed from the policy are removed from the pods
+const policyMetadataAnnotation = "openebs.io/last-applied-policy-metadata"
+
+// policyMetadata is the value of the policyMetadataAnnotation
+type policyMetadata struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// rolloutReplicas applies mutate to the pod template of the replica
+// statefulset and restarts the replicas one at a time. The rollout is
+// controlled using the partition of the rolling update strategy, the
+// partition is decremented only after the previously updated replica
+// is ready and all the replicas are back in RW mode, so that the volume
+// never loses the quorum. It returns true once all the replicas have
+// been updated.
+func (r *JivaVolumeReconciler) rolloutReplicas(cr *jivaAPI.JivaVolume, mutate podTemplateMutator) (bool, error) {
+	replicaSTS := &appsv1.StatefulSet{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace}, replicaSTS)
+	if err != nil {
+		return false, err
+	}
+
+	var replicas int32 = 1
+	if replicaSTS.Spec.Replicas != nil {
+		replicas = *replicaSTS.Spec.Replicas
+	}
+
+	template := replicaSTS.Spec.Template.DeepCopy()
+	mutate(template)
+	if !equality.Semantic.DeepEqual(template, &replicaSTS.Spec.Template) {
+		logrus.Infof("rolling out replica changes of volume %s", cr.Name)
+		replicaSTS.Spec.Template = *template
+		replicaSTS.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+			Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition: &replicas,
+			},
+		}
+		return false, r.Update(context.TODO(), replicaSTS)
+	}
+
+	if replicaSTS.Status.ObservedGeneration < replicaSTS.Generation {
+		return false, nil
+	}
+
+	var partition int32
+	if replicaSTS.Spec.UpdateStrategy.RollingUpdate != nil &&
+		replicaSTS.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		partition = *replicaSTS.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+
+	// wait for the replicas updated so far to be
+	// recreated and rebuilt before moving ahead
+	if replicaSTS.Status.UpdatedReplicas < replicas-partition ||
+		replicaSTS.Status.ReadyReplicas < replicas ||
+		!allReplicasRW(cr) {
+		return false, nil
+	}
+
+	if partition == 0 {
+		return true, nil
+	}
+
+	partition--
+	logrus.Infof("updating replica %d of volume %s", partition, cr.Name)
+	replicaSTS.Spec.UpdateStrategy.RollingUpdate.Partition = &partition
+	return false, r.Update(context.TODO(), replicaSTS)
+}
+
+// rolloutTarget applies mutate to the pod template of the target
+// deployment. It returns true once the updated target pod is available.
+func (r *JivaVolumeReconciler) rolloutTarget(cr *jivaAPI.JivaVolume, mutate podTemplateMutator) (bool, error) {
+	dep := &appsv1.Deployment{}
+	err := r.Get(context.TODO(),
+		types.NamespacedName{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace}, dep)
+	if err != nil {
+		return false, err
+	}
+
+	template := dep.Spec.Template.DeepCopy()
+	mutate(template)
+	if !equality.Semantic.DeepEqual(template, &dep.Spec.Template) {
+		logrus.Infof("rolling out target changes of volume %s", cr.Name)
+		dep.Spec.Template = *template
+		return false, r.Update(context.TODO(), dep)
+	}
+
+	var replicas int32 = 1
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	return dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas == replicas &&
+		dep.Status.AvailableReplicas == replicas, nil
+}
+
+// withPodTemplate returns a mutator which copies the scheduling
+// configuration, the image pull secrets, the security context, the
+// container resources, the labels and the annotations of the given
+// pod template. The labels and the annotations which are no longer
+// set by the policy are removed.
+func withPodTemplate(desired *corev1.PodTemplateSpec) podTemplateMutator {
+	return func(template *corev1.PodTemplateSpec) {
+		// remove the labels and the annotations which
+		// are no longer set by the policy
+		applied := getPolicyMetadata(template.Annotations)
+		for _, k := range applied.Labels {
+			if _, ok := desired.Labels[k]; !ok {
+				delete(template.Labels, k)
+			}
+		}
+		for _, k := range append(applied.Annotations, policyMetadataAnnotation) {
+			if _, ok := desired.Annotations[k]; !ok {
+				delete(template.Annotations, k)
+			}
+		}
+		if template.Labels == nil {
+			template.Labels = map[string]string{}
+		}
+		for k, v := range desired.Labels {
+			template.Labels[k] = v
+		}
+		if len(desired.Annotations) != 0 && template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		for k, v := range desired.Annotations {
+			template.Annotations[k] = v
+		}
+		// the scrape annotations are removed along with the exporter sidecar
+		for k := range defaultAnnotations() {
+			if _, ok := desired.Annotations[k]; !ok {
+				delete(template.Annotations, k)
+			}
+		}
+		template.Spec.Tolerations = desired.Spec.Tolerations
+		template.Spec.Affinity = desired.Spec.Affinity
+		template.Spec.TopologySpreadConstraints = desired.Spec.TopologySpreadConstraints
+		template.Spec.NodeSelector = desired.Spec.NodeSelector
+		template.Spec.PriorityClassName = desired.Spec.PriorityClassName
+		template.Spec.ImagePullSecrets = desired.Spec.ImagePullSecrets
+		// the API server defaults an unset security context to an empty one
+		template.Spec.SecurityContext = desired.Spec.SecurityContext
+		if template.Spec.SecurityContext == nil {
+			template.Spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		if template.Spec.ServiceAccountName != desired.Spec.ServiceAccountName {
+			template.Spec.ServiceAccountName = desired.Spec.ServiceAccountName
+			template.Spec.DeprecatedServiceAccount = desired.Spec.ServiceAccountName
+		}
+		for i := range template.Spec.Containers {
+			for _, c := range desired.Spec.Containers {
+				if template.Spec.Containers[i].Name == c.Name {
+					template.Spec.Containers[i].Resources = c.Resources
+				}
+			}
+		}
+	}
+}
+
+// withPolicyMetadata returns the given annotations of the pod template
+// along with the policyMetadataAnnotation recording the keys of the
+// labels and the annotations set by the policy
+func withPolicyMetadata(annotations map[string]string, policy jivaAPI.PodTemplateResources) map[string]string {
+	if len(policy.Labels) == 0 && len(policy.Annotations) == 0 {
+		return annotations
+	}
+	metadata := policyMetadata{}
+	for k := range policy.Labels {
+		metadata.Labels = append(metadata.Labels, k)
+	}
+	for k := range policy.Annotations {
+		metadata.Annotations = append(metadata.Annotations, k)
+	}
+	sort.Strings(metadata.Labels)
+	sort.Strings(metadata.Annotations)
+	value, _ := json.Marshal(metadata)
+
+	merged := map[string]string{}
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	merged[policyMetadataAnnotation] = string(value)
+	return merged
+}
+
+// getPolicyMetadata returns the keys recorded in the
+// policyMetadataAnnotation of the given annotations
+func getPolicyMetadata(annotations map[string]string) policyMetadata {
+	metadata := policyMetadata{}
+	if value, ok := annotations[policyMetadataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+			logrus.Warningf("failed to parse annotation %s: %v", policyMetadataAnnotation, err)
+		}
+	}
+	return metadata
+}
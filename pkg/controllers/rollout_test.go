@@ -0,0 +1,116 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// newTestPolicyPodTemplate returns the pod template built for
+// the given policy labels and annotations
+func newTestPolicyPodTemplate(labels, annotations map[string]string) *corev1.PodTemplateSpec {
+	policy := jivaAPI.PodTemplateResources{Labels: labels, Annotations: annotations}
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      withReserved(labels, map[string]string{"openebs.io/component": "jiva-replica"}),
+			Annotations: withPolicyMetadata(annotations, policy),
+		},
+	}
+}
+
+func TestWithPodTemplateMetadata(t *testing.T) {
+	tests := map[string]struct {
+		applied, desired *corev1.PodTemplateSpec
+		// extra are the labels and the annotations
+		// which are not set by the operator
+		extra             map[string]string
+		expectLabels      map[string]string
+		expectAnnotations map[string]string
+	}{
+		"Test labels and annotations added to the policy": {
+			applied: newTestPolicyPodTemplate(nil, nil),
+			desired: newTestPolicyPodTemplate(map[string]string{"team": "a"}, map[string]string{"note": "x"}),
+			expectLabels: map[string]string{
+				"openebs.io/component": "jiva-replica",
+				"team":                 "a",
+			},
+			expectAnnotations: map[string]string{
+				"note":                   "x",
+				policyMetadataAnnotation: `{"labels":["team"],"annotations":["note"]}`,
+			},
+		},
+		"Test labels and annotations removed from the policy": {
+			applied: newTestPolicyPodTemplate(
+				map[string]string{"team": "a", "tier": "gold"}, map[string]string{"note": "x"}),
+			desired: newTestPolicyPodTemplate(map[string]string{"team": "b"}, nil),
+			expectLabels: map[string]string{
+				"openebs.io/component": "jiva-replica",
+				"team":                 "b",
+			},
+			expectAnnotations: map[string]string{
+				policyMetadataAnnotation: `{"labels":["team"]}`,
+			},
+		},
+		"Test all labels and annotations removed from the policy": {
+			applied: newTestPolicyPodTemplate(map[string]string{"team": "a"}, map[string]string{"note": "x"}),
+			desired: newTestPolicyPodTemplate(nil, nil),
+			expectLabels: map[string]string{
+				"openebs.io/component": "jiva-replica",
+			},
+			expectAnnotations: map[string]string{},
+		},
+		"Test labels and annotations not set by the policy": {
+			applied: newTestPolicyPodTemplate(map[string]string{"team": "a"}, nil),
+			desired: newTestPolicyPodTemplate(nil, nil),
+			extra:   map[string]string{"kubectl.kubernetes.io/restartedAt": "now"},
+			expectLabels: map[string]string{
+				"openebs.io/component":              "jiva-replica",
+				"kubectl.kubernetes.io/restartedAt": "now",
+			},
+			expectAnnotations: map[string]string{
+				"kubectl.kubernetes.io/restartedAt": "now",
+			},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			template := mock.applied.DeepCopy()
+			if template.Annotations == nil {
+				template.Annotations = map[string]string{}
+			}
+			for k, v := range mock.extra {
+				template.Labels[k] = v
+				template.Annotations[k] = v
+			}
+			withPodTemplate(mock.desired)(template)
+			if !reflect.DeepEqual(template.Labels, mock.expectLabels) {
+				t.Fatalf("Test %q failed: expected labels %v, got %v", name, mock.expectLabels, template.Labels)
+			}
+			if !reflect.DeepEqual(template.Annotations, mock.expectAnnotations) {
+				t.Fatalf("Test %q failed: expected annotations %v, got %v",
+					name, mock.expectAnnotations, template.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/volume"
+)
+
+// exporterNamespace is the namespace of the metrics exported by the
+// maya-volume-exporter sidecar. The stats collector uses the same metric
+// names but the volume labels of the operator, so the dashboards built
+// on the metrics of the sidecar have to be updated to the new labels.
+const exporterNamespace = "openebs"
+
+// volume status values as reported by the maya-volume-exporter
+const (
+	volumeStatusOffline  = 1
+	volumeStatusDegraded = 2
+	volumeStatusHealthy  = 3
+	volumeStatusUnknown  = 4
+)
+
+func newStatsGauge(name, help string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: exporterNamespace,
+			Name:      name,
+			Help:      help,
+		},
+		volumeLabels,
+	)
+}
+
+var (
+	statsReads           = newStatsGauge("reads", "Read Input/Outputs on Volume")
+	statsWrites          = newStatsGauge("writes", "Write Input/Outputs on Volume")
+	statsReadTime        = newStatsGauge("read_time", "Read time on volume")
+	statsWriteTime       = newStatsGauge("write_time", "Write time on volume")
+	statsReadBlockCount  = newStatsGauge("read_block_count", "Read Block count of volume")
+	statsWriteBlockCount = newStatsGauge("write_block_count", "Write Block count of volume")
+	statsReadBytes       = newStatsGauge("total_read_bytes", "Total read bytes")
+	statsWriteBytes      = newStatsGauge("total_write_bytes", "Total write bytes")
+	statsSizeOfVolume    = newStatsGauge("size_of_volume", "Size of the volume requested")
+	statsActualUsed      = newStatsGauge("actual_used", "Actual volume size used")
+	statsLogicalSize     = newStatsGauge("logical_size", "Logical size of volume")
+	statsSectorSize      = newStatsGauge("sector_size", "sector size of volume")
+	statsUpTime          = newStatsGauge("volume_uptime", "Time since volume has registered")
+	statsVolumeStatus    = newStatsGauge("volume_status",
+		"Status of volume: (1, 2, 3, 4) = {Offline, Degraded, Healthy, Unknown}")
+	statsReplicaCount         = newStatsGauge("total_replica_count", "Total no of replicas connected to cas")
+	statsHealthyReplicaCount  = newStatsGauge("healthy_replica_count", "Total no of healthy replicas")
+	statsDegradedReplicaCount = newStatsGauge("degraded_replica_count",
+		"Total no of degraded/ro/wo replicas")
+
+	// statsMetrics are the metrics exported from the stats of the target
+	statsMetrics = []*prometheus.GaugeVec{
+		statsReads,
+		statsWrites,
+		statsReadTime,
+		statsWriteTime,
+		statsReadBlockCount,
+		statsWriteBlockCount,
+		statsReadBytes,
+		statsWriteBytes,
+		statsSizeOfVolume,
+		statsActualUsed,
+		statsLogicalSize,
+		statsSectorSize,
+		statsUpTime,
+		statsVolumeStatus,
+		statsReplicaCount,
+		statsHealthyReplicaCount,
+		statsDegradedReplicaCount,
+	}
+)
+
+// recordStatsMetrics exports the stats of the volume polled from the
+// target, the same way as the maya-volume-exporter sidecar does. If the
+// stats couldn't be fetched only the volume status is updated. The stats
+// of the volumes which run the sidecar are exported by the sidecar, so
+// that the same volume isn't exported twice.
+func recordStatsMetrics(cr *jivaAPI.JivaVolume, stats *volume.Stats) {
+	if !isMonitorDisabled(cr) {
+		match := prometheus.Labels{"pv": cr.Spec.PV, "namespace": cr.Namespace}
+		for _, m := range statsMetrics {
+			m.DeletePartialMatch(match)
+		}
+		return
+	}
+
+	labels := volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace)
+	if !stats.Got {
+		statsVolumeStatus.With(labels).Set(volumeStatusUnknown)
+		return
+	}
+
+	sectorSize := statsValue(stats.SectorSize)
+	statsReads.With(labels).Set(statsValue(stats.Reads))
+	statsWrites.With(labels).Set(statsValue(stats.Writes))
+	statsReadTime.With(labels).Set(statsValue(stats.TotalReadTime) / volume.MicSec)
+	statsWriteTime.With(labels).Set(statsValue(stats.TotalWriteTime) / volume.MicSec)
+	statsReadBlockCount.With(labels).Set(statsValue(stats.TotalReadBlockCount))
+	statsWriteBlockCount.With(labels).Set(statsValue(stats.TotalWriteBlockCount))
+	statsReadBytes.With(labels).Set(statsValue(stats.TotalReadBytes))
+	statsWriteBytes.With(labels).Set(statsValue(stats.TotalWriteBytes))
+	statsSizeOfVolume.With(labels).Set(statsValue(stats.Size) / volume.BytesToGB)
+	statsActualUsed.With(labels).Set(statsValue(stats.UsedBlocks) * sectorSize / volume.BytesToGB)
+	statsLogicalSize.With(labels).Set(statsValue(stats.UsedLogicalBlocks) * sectorSize / volume.BytesToGB)
+	statsSectorSize.With(labels).Set(sectorSize)
+	statsUpTime.With(labels).Set(statsValue(stats.UpTime))
+
+	healthy, degraded := 0, 0
+	for _, rep := range stats.Replicas {
+		if rep.Mode == "RW" {
+			healthy++
+		} else {
+			degraded++
+		}
+	}
+	statsReplicaCount.With(labels).Set(float64(len(stats.Replicas)))
+	statsHealthyReplicaCount.With(labels).Set(float64(healthy))
+	statsDegradedReplicaCount.With(labels).Set(float64(degraded))
+
+	status := volumeStatusDegraded
+	switch {
+	case stats.TargetStatus != "RW":
+		status = volumeStatusOffline
+	case healthy == cr.Spec.Policy.Target.ReplicationFactor:
+		status = volumeStatusHealthy
+	}
+	statsVolumeStatus.With(labels).Set(float64(status))
+}
+
+// statsValue returns the value of a stat, an invalid value is reported as 0
+func statsValue(n json.Number) float64 {
+	v, err := n.Float64()
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/utils/ptr"
+
+	"github.com/openebs/jiva-operator/pkg/volume"
+)
+
+// newTestStats returns the stats of a target
+// with replicas in the given modes
+func newTestStats(targetStatus string, modes ...string) *volume.Stats {
+	stats := &volume.Stats{
+		Got:          true,
+		Reads:        "10",
+		Writes:       "20",
+		SectorSize:   "4096",
+		TargetStatus: targetStatus,
+	}
+	for _, mode := range modes {
+		stats.Replicas = append(stats.Replicas, volume.Replica{Mode: mode})
+	}
+	return stats
+}
+
+// deleteTestStatsMetrics removes the stats series of the volume and
+// returns the names of the metrics which had a series of the volume
+func deleteTestStatsMetrics(pv string) map[string]bool {
+	found := map[string]bool{}
+	for _, m := range statsMetrics {
+		if m.DeletePartialMatch(prometheus.Labels{"pv": pv}) != 0 {
+			desc := make(chan *prometheus.Desc, 1)
+			m.Describe(desc)
+			found[(<-desc).String()] = true
+		}
+	}
+	return found
+}
+
+func TestRecordStatsMetrics(t *testing.T) {
+	tests := map[string]struct {
+		stats          *volume.Stats
+		expectStatus   float64
+		expectHealthy  float64
+		expectDegraded float64
+	}{
+		"Test healthy volume": {
+			stats:         newTestStats("RW", "RW", "RW", "RW"),
+			expectStatus:  volumeStatusHealthy,
+			expectHealthy: 3,
+		},
+		"Test degraded volume": {
+			stats:          newTestStats("RW", "RW", "RW", "WO"),
+			expectStatus:   volumeStatusDegraded,
+			expectHealthy:  2,
+			expectDegraded: 1,
+		},
+		"Test volume with replicas missing": {
+			stats:         newTestStats("RW", "RW", "RW"),
+			expectStatus:  volumeStatusDegraded,
+			expectHealthy: 2,
+		},
+		"Test offline volume": {
+			stats:          newTestStats("RO", "RW", "ERR"),
+			expectStatus:   volumeStatusOffline,
+			expectHealthy:  1,
+			expectDegraded: 1,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv-stats", 3)
+			cr.Spec.Policy.Target.DisableMonitor = ptr.To(true)
+			labels := volumeLabelValues(cr.Spec.PV, "", cr.Namespace)
+			defer deleteTestStatsMetrics(cr.Spec.PV)
+
+			recordStatsMetrics(cr, mock.stats)
+			if got := testutil.ToFloat64(statsVolumeStatus.With(labels)); got != mock.expectStatus {
+				t.Fatalf("Test %q failed: expected volume status %v, got %v", name, mock.expectStatus, got)
+			}
+			if got := testutil.ToFloat64(statsReplicaCount.With(labels)); got != float64(len(mock.stats.Replicas)) {
+				t.Fatalf("Test %q failed: expected %d replicas, got %v", name, len(mock.stats.Replicas), got)
+			}
+			if got := testutil.ToFloat64(statsHealthyReplicaCount.With(labels)); got != mock.expectHealthy {
+				t.Fatalf("Test %q failed: expected %v healthy replicas, got %v", name, mock.expectHealthy, got)
+			}
+			if got := testutil.ToFloat64(statsDegradedReplicaCount.With(labels)); got != mock.expectDegraded {
+				t.Fatalf("Test %q failed: expected %v degraded replicas, got %v", name, mock.expectDegraded, got)
+			}
+			if got := testutil.ToFloat64(statsReads.With(labels)); got != 10 {
+				t.Fatalf("Test %q failed: expected 10 reads, got %v", name, got)
+			}
+			if got := testutil.ToFloat64(statsSectorSize.With(labels)); got != 4096 {
+				t.Fatalf("Test %q failed: expected sector size 4096, got %v", name, got)
+			}
+		})
+	}
+}
+
+func TestRecordStatsMetricsNotGot(t *testing.T) {
+	cr := newTestVolume("pv-stats-unknown", 3)
+	cr.Spec.Policy.Target.DisableMonitor = ptr.To(true)
+	labels := volumeLabelValues(cr.Spec.PV, "", cr.Namespace)
+	defer deleteTestStatsMetrics(cr.Spec.PV)
+
+	recordStatsMetrics(cr, &volume.Stats{})
+	if got := testutil.ToFloat64(statsVolumeStatus.With(labels)); got != volumeStatusUnknown {
+		t.Fatalf("expected volume status %v, got %v", volumeStatusUnknown, got)
+	}
+	found := deleteTestStatsMetrics(cr.Spec.PV)
+	if len(found) != 1 {
+		t.Fatalf("expected only the volume status to be set, got %v", found)
+	}
+}
+
+func TestRecordStatsMetricsSidecar(t *testing.T) {
+	tests := map[string]struct {
+		disableMonitor *bool
+	}{
+		"Test volume with monitor enabled":     {disableMonitor: ptr.To(false)},
+		"Test volume with monitor not set":     {},
+		"Test volume with monitor set to true": {disableMonitor: ptr.To(true)},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv-stats-sidecar", 3)
+			defer deleteTestStatsMetrics(cr.Spec.PV)
+			// the volume was exported by the operator before
+			cr.Spec.Policy.Target.DisableMonitor = ptr.To(true)
+			recordStatsMetrics(cr, newTestStats("RW", "RW", "RW", "RW"))
+
+			cr.Spec.Policy.Target.DisableMonitor = mock.disableMonitor
+			recordStatsMetrics(cr, newTestStats("RW", "RW", "RW", "RW"))
+			found := deleteTestStatsMetrics(cr.Spec.PV)
+			if isMonitorDisabled(cr) && len(found) != len(statsMetrics) {
+				t.Fatalf("Test %q failed: expected all the stats to be exported, got %d of %d",
+					name, len(found), len(statsMetrics))
+			}
+			if !isMonitorDisabled(cr) && len(found) != 0 {
+				t.Fatalf("Test %q failed: expected stats of sidecar volume to be deleted, got %v",
+					name, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	operr "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+// storageMigrationRetryInterval is the interval at
+// which a failed storage migration is retried
+const storageMigrationRetryInterval = time.Minute
+
+// StorageVersionMigrator rewrites the JivaVolumes and the JivaVolumePolicies
+// stored in an older version of the API in the storage version, and then
+// removes the older versions from the storedVersions of the CRDs, so that
+// the older versions can be removed from the CRDs.
+type StorageVersionMigrator struct {
+	client.Client
+	// APIReader reads the CRDs and the objects from the API server,
+	// the CRDs aren't cached as they are read only once
+	APIReader client.Reader
+}
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;update;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update;patch
+
+// Start migrates the CRDs till the migration succeeds or the context is
+// cancelled, it is run by the manager only on the elected leader.
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	migrations := []struct {
+		crd  string
+		list func() client.ObjectList
+	}{
+		{"jivavolumepolicies.openebs.io", func() client.ObjectList { return &jivaAPI.JivaVolumePolicyList{} }},
+		{"jivavolumes.openebs.io", func() client.ObjectList { return &jivaAPI.JivaVolumeList{} }},
+	}
+	for _, mig := range migrations {
+		mig := mig
+		err := wait.PollUntilContextCancel(ctx, storageMigrationRetryInterval, true,
+			func(ctx context.Context) (bool, error) {
+				if err := m.migrate(ctx, mig.crd, mig.list()); err != nil {
+					logrus.Errorf("failed to migrate storage version of %s: %v", mig.crd, err)
+					return false, nil
+				}
+				return true, nil
+			})
+		if err != nil {
+			// the context has been cancelled
+			return nil
+		}
+	}
+	return nil
+}
+
+// migrate rewrites all the objects of the CRD if any of them may be
+// stored in a version other than the storage version of the CRD
+func (m *StorageVersionMigrator) migrate(ctx context.Context, name string, list client.ObjectList) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := m.APIReader.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+		return operr.Wrapf(err, "failed to get CRD %s", name)
+	}
+	storageVersion := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			storageVersion = v.Name
+		}
+	}
+	if storageVersion == "" {
+		return fmt.Errorf("CRD %s has no storage version", name)
+	}
+	stored := crd.Status.StoredVersions
+	if len(stored) == 1 && stored[0] == storageVersion {
+		return nil
+	}
+
+	logrus.Infof("migrating %s from stored versions %v to %s", name, stored, storageVersion)
+	if err := m.APIReader.List(ctx, list); err != nil {
+		return operr.Wrapf(err, "failed to list %s", name)
+	}
+	objs, err := listObjects(list)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if err := m.rewrite(ctx, obj); err != nil {
+			return operr.Wrapf(err, "failed to rewrite %s %s/%s", name, obj.GetNamespace(), obj.GetName())
+		}
+	}
+
+	// all the objects are now stored in the storage version
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.APIReader.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+			return err
+		}
+		crd.Status.StoredVersions = []string{storageVersion}
+		return m.Status().Update(ctx, crd)
+	})
+}
+
+// rewrite updates the object without any change, which makes
+// the API server store it again in the storage version
+func (m *StorageVersionMigrator) rewrite(ctx context.Context, obj client.Object) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := m.Update(ctx, obj)
+		if errors.IsConflict(err) {
+			// get the latest version to retry with
+			if getErr := m.APIReader.Get(ctx, client.ObjectKeyFromObject(obj), obj); getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+	if errors.IsNotFound(err) {
+		// the object has been deleted meanwhile
+		return nil
+	}
+	return err
+}
+
+func listObjects(list client.ObjectList) ([]client.Object, error) {
+	objs := []client.Object{}
+	switch l := list.(type) {
+	case *jivaAPI.JivaVolumeList:
+		for i := range l.Items {
+			objs = append(objs, &l.Items[i])
+		}
+	case *jivaAPI.JivaVolumePolicyList:
+		for i := range l.Items {
+			objs = append(objs, &l.Items[i])
+		}
+	default:
+		return nil, fmt.Errorf("unexpected list type %T", list)
+	}
+	return objs, nil
+}
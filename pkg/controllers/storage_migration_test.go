@@ -0,0 +1,144 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const testVolumeCRD = "jivavolumes.openebs.io"
+
+func newTestCRD(storedVersions ...string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: testVolumeCRD},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func TestStorageVersionMigratorMigrate(t *testing.T) {
+	tests := map[string]struct {
+		storedVersions       []string
+		failVolume           string
+		isErr                bool
+		expectRewritten      []string
+		expectStoredVersions []string
+	}{
+		"Test migration of all the volumes": {
+			storedVersions:       []string{"v1alpha1", "v1"},
+			expectRewritten:      []string{"pv-1", "pv-2", "pv-3"},
+			expectStoredVersions: []string{"v1"},
+		},
+		"Test migration which fails to rewrite a volume": {
+			storedVersions:       []string{"v1alpha1", "v1"},
+			failVolume:           "pv-2",
+			isErr:                true,
+			expectRewritten:      []string{"pv-1"},
+			expectStoredVersions: []string{"v1alpha1", "v1"},
+		},
+		"Test CRD stored only in the storage version": {
+			storedVersions:       []string{"v1"},
+			expectStoredVersions: []string{"v1"},
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to add client-go types to scheme: %v", err)
+			}
+			if err := jivaAPI.AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to add jiva types to scheme: %v", err)
+			}
+			if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to add apiextensions types to scheme: %v", err)
+			}
+			objs := []client.Object{newTestCRD(mock.storedVersions...)}
+			for i := 1; i <= 3; i++ {
+				objs = append(objs, newTestVolume(fmt.Sprintf("pv-%d", i), 1))
+			}
+
+			rewritten := []string{}
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+						if _, ok := obj.(*jivaAPI.JivaVolume); !ok {
+							return c.Update(ctx, obj, opts...)
+						}
+						if obj.GetName() == mock.failVolume {
+							return fmt.Errorf("injected error")
+						}
+						// the CRD must keep the older versions
+						// till all the objects are rewritten
+						crd := &apiextensionsv1.CustomResourceDefinition{}
+						if err := c.Get(ctx, types.NamespacedName{Name: testVolumeCRD}, crd); err != nil {
+							return err
+						}
+						if len(crd.Status.StoredVersions) != len(mock.storedVersions) {
+							t.Errorf("Test %q failed: stored versions changed to %v before rewriting %s",
+								name, crd.Status.StoredVersions, obj.GetName())
+						}
+						rewritten = append(rewritten, obj.GetName())
+						return c.Update(ctx, obj, opts...)
+					},
+				}).Build()
+			m := &StorageVersionMigrator{Client: cl, APIReader: cl}
+
+			err := m.migrate(context.TODO(), testVolumeCRD, &jivaAPI.JivaVolumeList{})
+			if mock.isErr && err == nil {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.isErr && err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if len(rewritten) != 0 || len(mock.expectRewritten) != 0 {
+				if !reflect.DeepEqual(rewritten, mock.expectRewritten) {
+					t.Fatalf("Test %q failed: expected rewritten volumes %v, got %v",
+						name, mock.expectRewritten, rewritten)
+				}
+			}
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: testVolumeCRD}, crd); err != nil {
+				t.Fatalf("Test %q failed: failed to get CRD: %v", name, err)
+			}
+			if !reflect.DeepEqual(crd.Status.StoredVersions, mock.expectStoredVersions) {
+				t.Fatalf("Test %q failed: expected stored versions %v, got %v",
+					name, mock.expectStoredVersions, crd.Status.StoredVersions)
+			}
+		})
+	}
+}
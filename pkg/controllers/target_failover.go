@@ -0,0 +1,154 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// DefaultTargetFailoverGracePeriod is the duration for which the node
+	// of a target pod is allowed to stay NotReady before the target pod
+	// is force deleted so that it is rescheduled on another node
+	DefaultTargetFailoverGracePeriod = time.Minute
+
+	targetFailoverReason = "TargetFailover"
+)
+
+// reconcileTargetFailover force deletes the target pod of the volume once its
+// node has been NotReady for the failover grace period. A pod on a dead node
+// stays Terminating till the node comes back, and the Recreate strategy of
+// the target deployment doesn't create the new pod till the old one is gone.
+// The failover is recorded as a pending action of the volume, which is
+// completed once the new target pod is running on a ready node.
+func (r *JivaVolumeReconciler) reconcileTargetFailover(cr *jivaAPI.JivaVolume) error {
+	labelSelector, _ := labels.Parse(controllerComponentLabel + cr.Name)
+	pods := &corev1.PodList{}
+	err := r.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return err
+	}
+
+	key := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		notReadySince, missing, err := r.nodeNotReadySince(pod.Spec.NodeName)
+		if err != nil {
+			return err
+		}
+		if notReadySince == nil {
+			continue
+		}
+		// the cached IP of the pod can't be used anymore
+		r.cache.deletePodIP(key)
+
+		if !missing && time.Since(notReadySince.Time) < r.targetFailoverGracePeriod() {
+			logrus.Infof("node %s of target pod %s of volume %s is not ready since %s",
+				pod.Spec.NodeName, pod.Name, cr.Name, notReadySince.Format(time.RFC3339))
+			continue
+		}
+
+		logrus.Infof("force deleting target pod %s of volume %s on node %s not ready since %s",
+			pod.Name, cr.Name, pod.Spec.NodeName, notReadySince.Format(time.RFC3339))
+		err = r.Delete(context.TODO(), pod, client.GracePeriodSeconds(0))
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, targetFailoverReason,
+			"node %s of target pod %s not ready since %s, force deleted the pod",
+			pod.Spec.NodeName, pod.Name, notReadySince.Format(time.RFC3339))
+
+		name := cr.Name + "-jiva-ctrl"
+		if getPendingAction(cr, pendingTargetFailover, name) == nil {
+			addPendingAction(cr, pendingTargetFailover, name, targetFailoverPendingMsg)
+			// the failover is measured from the time the node went down
+			getPendingAction(cr, pendingTargetFailover, name).Since = *notReadySince
+			if err := r.updateJivaVolume(cr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// completeTargetFailover records the duration of an ongoing failover of
+// the target pod once the new target pod is running on a ready node,
+// without updating the object. It returns true if the status changed.
+func (r *JivaVolumeReconciler) completeTargetFailover(cr *jivaAPI.JivaVolume) bool {
+	name := cr.Name + "-jiva-ctrl"
+	action := getPendingAction(cr, pendingTargetFailover, name)
+	if action == nil {
+		return false
+	}
+	duration := time.Since(action.Since.Time)
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, targetFailoverReason,
+		"target pod failed over in %s", duration.Round(time.Second))
+	logrus.Infof("target pod of volume %s failed over in %s", cr.Name, duration.Round(time.Second))
+	targetFailoverDuration.With(
+		volumeLabelValues(cr.Spec.PV, cr.GetLabels()[openebsPVC], cr.Namespace),
+	).Observe(duration.Seconds())
+	return removePendingAction(cr, pendingTargetFailover, name)
+}
+
+// nodeNotReadySince returns the time since which the node is not ready, or
+// nil if the node is ready. A missing node is reported as not ready since
+// now, the pods on a removed node don't need to wait for the grace period.
+func (r *JivaVolumeReconciler) nodeNotReadySince(name string) (*metav1.Time, bool, error) {
+	node := &corev1.Node{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name}, node)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			now := metav1.Now()
+			return &now, true, nil
+		}
+		return nil, false, err
+	}
+	if isNodeReady(node) {
+		return nil, false, nil
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return &cond.LastTransitionTime, false, nil
+		}
+	}
+	// the node hasn't reported its readiness yet
+	return &node.CreationTimestamp, false, nil
+}
+
+func (r *JivaVolumeReconciler) targetFailoverGracePeriod() time.Duration {
+	if r.TargetFailoverGracePeriod <= 0 {
+		return DefaultTargetFailoverGracePeriod
+	}
+	return r.TargetFailoverGracePeriod
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNodeNotReadySince(t *testing.T) {
+	created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	transition := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	tests := map[string]struct {
+		conditions    []corev1.NodeCondition
+		missing       bool
+		expectSince   *metav1.Time
+		expectMissing bool
+	}{
+		"Test ready node": {
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastTransitionTime: transition},
+			},
+		},
+		"Test not ready node": {
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse, LastTransitionTime: created},
+				{Type: corev1.NodeReady, Status: corev1.ConditionFalse, LastTransitionTime: transition},
+			},
+			expectSince: &transition,
+		},
+		"Test node with unknown readiness": {
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionUnknown, LastTransitionTime: transition},
+			},
+			expectSince: &transition,
+		},
+		"Test node which hasn't reported its readiness": {
+			expectSince: &created,
+		},
+		"Test missing node": {
+			missing:       true,
+			expectMissing: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			objs := []client.Object{}
+			if !mock.missing {
+				objs = append(objs, &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "node-1", CreationTimestamp: created},
+					Status:     corev1.NodeStatus{Conditions: mock.conditions},
+				})
+			}
+			r := newTestReconciler(t, objs...)
+
+			before := time.Now().Truncate(time.Second)
+			since, missing, err := r.nodeNotReadySince("node-1")
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if missing != mock.expectMissing {
+				t.Fatalf("Test %q failed: expected node missing: %t, got: %t", name, mock.expectMissing, missing)
+			}
+			switch {
+			case mock.expectMissing:
+				if since == nil || since.Time.Before(before) {
+					t.Fatalf("Test %q failed: expected missing node to be not ready since now, got %v", name, since)
+				}
+			case mock.expectSince == nil:
+				if since != nil {
+					t.Fatalf("Test %q failed: expected node to be ready, got not ready since %v", name, since)
+				}
+			default:
+				if since == nil || !since.Equal(mock.expectSince) {
+					t.Fatalf("Test %q failed: expected node to be not ready since %v, got %v",
+						name, mock.expectSince, since)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// jivaVolumeFinalizer protects the JivaVolume from being
+	// removed before its components are torn down
+	jivaVolumeFinalizer = "jiva.openebs.io/volume-protection"
+
+	// unstageWaitTimeout is the time the teardown waits for a
+	// staged volume to be unstaged before tearing it down anyway
+	unstageWaitTimeout = 10 * time.Minute
+
+	teardownReason           = "Teardown"
+	controllerComponentLabel = "openebs.io/component=jiva-controller,openebs.io/persistent-volume="
+)
+
+// ensureFinalizer adds the finalizer to the volume if it is missing
+func (r *JivaVolumeReconciler) ensureFinalizer(cr *jivaAPI.JivaVolume) error {
+	if controllerutil.ContainsFinalizer(cr, jivaVolumeFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(cr, jivaVolumeFinalizer)
+	return r.updateJivaVolume(cr)
+}
+
+// teardownJiva removes the components of a deleted volume in order, the
+// target is scaled down first followed by the replicas, then the replica
+// PVCs and PVs are deleted and finally the finalizer is removed. Each
+// step waits for the previous one to complete, so the teardown is
+// resumed across the reconciliations. The teardown doesn't start while
+// the volume is staged on a node, see waitForUnstage.
+func (r *JivaVolumeReconciler) teardownJiva(cr *jivaAPI.JivaVolume) error {
+	if !controllerutil.ContainsFinalizer(cr, jivaVolumeFinalizer) {
+		return nil
+	}
+
+	if cr.Status.Phase != jivaAPI.JivaVolumePhaseDeleting {
+		if cr.Spec.MountInfo.StagingPath != "" {
+			wait, err := r.waitForUnstage(cr)
+			if err != nil || wait {
+				return err
+			}
+		}
+		logrus.Info("start tearing down jiva components", "JivaVolume: ", cr.Name)
+		r.Recorder.Event(cr, corev1.EventTypeNormal, teardownReason, "tearing down the volume")
+		cr.Status.Phase = jivaAPI.JivaVolumePhaseDeleting
+		return r.updateJivaVolume(cr)
+	}
+
+	// scale down the target first so that no more
+	// IOs are served while the replicas are removed
+	done, err := r.scaleDownComponent(cr, cr.Name+"-jiva-ctrl", &appsv1.Deployment{}, controllerComponentLabel)
+	if err != nil || !done {
+		return err
+	}
+	done, err = r.scaleDownComponent(cr, cr.Name+"-jiva-rep", &appsv1.StatefulSet{}, replicaComponentSelector)
+	if err != nil || !done {
+		return err
+	}
+
+	done, err = r.deleteReplicaVolumes(cr)
+	if err != nil || !done {
+		return err
+	}
+
+	logrus.Infof("teardown of volume %s completed", cr.Name)
+	r.Recorder.Event(cr, corev1.EventTypeNormal, teardownReason, "teardown completed")
+	r.cache.delete(types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace})
+	controllerutil.RemoveFinalizer(cr, jivaVolumeFinalizer)
+	return r.Update(context.TODO(), cr)
+}
+
+// waitForUnstage returns true while the teardown has to wait for the
+// staged volume to be unstaged. The wait is skipped if the node the volume
+// is staged on no longer exists or once unstageWaitTimeout has elapsed,
+// as the node plugin may never unstage the volume in such cases.
+func (r *JivaVolumeReconciler) waitForUnstage(cr *jivaAPI.JivaVolume) (bool, error) {
+	nodeID := cr.Labels["nodeID"]
+	reason := ""
+	if nodeID != "" {
+		err := r.Get(context.TODO(), types.NamespacedName{Name: nodeID}, &corev1.Node{})
+		if err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		if errors.IsNotFound(err) {
+			reason = "the node no longer exists"
+		}
+	}
+	if a := getPendingAction(cr, pendingUnstage, nodeID); a != nil &&
+		time.Since(a.Since.Time) > unstageWaitTimeout {
+		reason = fmt.Sprintf("it wasn't unstaged within %s", unstageWaitTimeout)
+	}
+
+	if reason != "" {
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, teardownReason,
+			"volume is staged at %s on node %s, tearing it down as %s",
+			cr.Spec.MountInfo.StagingPath, nodeID, reason)
+		removePendingAction(cr, pendingUnstage, nodeID)
+		return false, nil
+	}
+
+	if addPendingAction(cr, pendingUnstage, nodeID, unstagePendingMsg) {
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, teardownReason,
+			"volume is staged at %s on node %s, waiting for it to be unstaged",
+			cr.Spec.MountInfo.StagingPath, nodeID)
+		return true, r.updateJivaVolume(cr)
+	}
+	return true, nil
+}
+
+// scaleDownComponent scales the target deployment or the replica
+// statefulset to zero and returns true once all of its pods are removed
+func (r *JivaVolumeReconciler) scaleDownComponent(cr *jivaAPI.JivaVolume, name string,
+	obj client.Object, podSelector string) (bool, error) {
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cr.Namespace}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if err == nil {
+		var replicas **int32
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			replicas = &o.Spec.Replicas
+		case *appsv1.StatefulSet:
+			replicas = &o.Spec.Replicas
+		}
+		if *replicas == nil || **replicas != 0 {
+			logrus.Infof("scaling down %s of volume %s", name, cr.Name)
+			zero := int32(0)
+			*replicas = &zero
+			if err := r.Update(context.TODO(), obj); err != nil {
+				return false, err
+			}
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, teardownReason, "scaled down %s", name)
+		}
+	}
+
+	labelSelector, err := labels.Parse(podSelector + cr.Name)
+	if err != nil {
+		return false, err
+	}
+	pods := &corev1.PodList{}
+	err = r.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(pods.Items) == 0, nil
+}
+
+// deleteReplicaVolumes deletes the replica PVCs and the PVs bound
+// to them as per the data retention policy of the volume, it returns
+// true once all of them have been removed or retained.
+func (r *JivaVolumeReconciler) deleteReplicaVolumes(cr *jivaAPI.JivaVolume) (bool, error) {
+	labelSelector, err := labels.Parse(replicaComponentSelector + cr.Name)
+	if err != nil {
+		return false, err
+	}
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err = r.List(context.TODO(), pvcs, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	remaining := 0
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.Labels[retainedReplicaLabel] != "" {
+			continue
+		}
+		remaining++
+		switch cr.Spec.Policy.DataRetention {
+		case jivaAPI.DataRetentionRetain:
+			if err := r.retainReplicaVolume(cr, pvc); err != nil {
+				return false, err
+			}
+			remaining--
+			continue
+		case jivaAPI.DataRetentionWipe:
+			if pvc.DeletionTimestamp == nil {
+				wiped, err := r.wipeReplicaVolume(cr, pvc)
+				if err != nil || !wiped {
+					return false, err
+				}
+			}
+		}
+		if pvc.DeletionTimestamp == nil {
+			logrus.Infof("deleting replica pvc %s of volume %s", pvc.Name, cr.Name)
+			if err := r.Delete(context.TODO(), pvc); err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, teardownReason, "deleted replica pvc %s", pvc.Name)
+		}
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		// the PVs with the Delete reclaim policy are removed
+		// by the provisioner, the retained ones are deleted here
+		pv := &corev1.PersistentVolume{}
+		err := r.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain &&
+			pv.DeletionTimestamp == nil {
+			logrus.Infof("deleting replica pv %s of volume %s", pv.Name, cr.Name)
+			if err := r.Delete(context.TODO(), pv); err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+			r.Recorder.Eventf(cr, corev1.EventTypeNormal, teardownReason, "deleted replica pv %s", pv.Name)
+		}
+	}
+	return remaining == 0, nil
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestWaitForUnstage(t *testing.T) {
+	tests := map[string]struct {
+		stagingPath string
+		nodeExists  bool
+		// waitingFor is the time the teardown has been waiting
+		// for the volume to be unstaged, if it has been waiting
+		waitingFor   time.Duration
+		expectWait   bool
+		expectEvents int
+	}{
+		"Test volume which is not staged": {
+			expectEvents: 1,
+		},
+		"Test volume staged on a node": {
+			stagingPath:  "/var/lib/kubelet/staging",
+			nodeExists:   true,
+			expectWait:   true,
+			expectEvents: 1,
+		},
+		"Test volume staged on a node already waited on": {
+			stagingPath: "/var/lib/kubelet/staging",
+			nodeExists:  true,
+			waitingFor:  time.Minute,
+			expectWait:  true,
+		},
+		"Test volume staged on a node beyond the wait timeout": {
+			stagingPath:  "/var/lib/kubelet/staging",
+			nodeExists:   true,
+			waitingFor:   time.Hour,
+			expectEvents: 2,
+		},
+		"Test volume staged on a removed node": {
+			stagingPath:  "/var/lib/kubelet/staging",
+			expectEvents: 2,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := newTestVolume("pv", 3)
+			cr.Labels = map[string]string{"nodeID": "node-1"}
+			cr.Finalizers = []string{jivaVolumeFinalizer}
+			cr.Spec.MountInfo.StagingPath = mock.stagingPath
+			if mock.waitingFor != 0 {
+				cr.Status.PendingActions = []jivaAPI.PendingAction{{
+					Type:    pendingUnstage,
+					Target:  "node-1",
+					Message: unstagePendingMsg,
+					Since:   metav1.NewTime(time.Now().Add(-mock.waitingFor)),
+				}}
+			}
+			objs := []client.Object{cr}
+			if mock.nodeExists {
+				objs = append(objs, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+			}
+			r := newTestReconciler(t, objs...)
+
+			if err := r.teardownJiva(cr); err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			got := getTestVolume(t, r, cr.Name)
+			if waiting := got.Status.Phase != jivaAPI.JivaVolumePhaseDeleting; waiting != mock.expectWait {
+				t.Fatalf("Test %q failed: expected teardown to wait: %t, got phase %s",
+					name, mock.expectWait, got.Status.Phase)
+			}
+			if pending := getPendingAction(got, pendingUnstage, "node-1") != nil; pending != mock.expectWait {
+				t.Fatalf("Test %q failed: expected pending unstage action: %t", name, mock.expectWait)
+			}
+			if events := len(r.Recorder.(*record.FakeRecorder).Events); events != mock.expectEvents {
+				t.Fatalf("Test %q failed: expected %d events, got %d", name, mock.expectEvents, events)
+			}
+		})
+	}
+}
+
+func TestTeardownJivaOrder(t *testing.T) {
+	cr := newTestVolume("pv", 3)
+	cr.Finalizers = []string{jivaVolumeFinalizer}
+	targetPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-jiva-ctrl-0",
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"openebs.io/component":         "jiva-controller",
+				"openebs.io/persistent-volume": cr.Name,
+			},
+		},
+	}
+	objs := []client.Object{
+		cr, targetPod,
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(3))},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-0"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			},
+		},
+	}
+	replicaPods := []*corev1.Pod{}
+	pvcs := []*corev1.PersistentVolumeClaim{}
+	for i := 0; i < 3; i++ {
+		pod, pvc := newTestReplica(cr, i)
+		if i == 0 {
+			pvc.Spec.VolumeName = "pvc-0"
+		}
+		replicaPods = append(replicaPods, pod)
+		pvcs = append(pvcs, pvc)
+		objs = append(objs, pod, pvc)
+	}
+	r := newTestReconciler(t, objs...)
+
+	completed := false
+	for i := 0; i < 10 && !completed; i++ {
+		cr = getTestVolume(t, r, cr.Name)
+		if err := r.teardownJiva(cr); err != nil {
+			t.Fatalf("expected error to be nil, got: %v", err)
+		}
+
+		dep := &appsv1.Deployment{}
+		objectExists(t, r, cr.Name+"-jiva-ctrl", cr.Namespace, dep)
+		sts := &appsv1.StatefulSet{}
+		objectExists(t, r, cr.Name+"-jiva-rep", cr.Namespace, sts)
+		targetRunning := objectExists(t, r, targetPod.Name, targetPod.Namespace, &corev1.Pod{})
+		replicasRunning := false
+		for _, pod := range replicaPods {
+			replicasRunning = objectExists(t, r, pod.Name, pod.Namespace, &corev1.Pod{}) || replicasRunning
+		}
+		pvcsLeft := 0
+		for _, pvc := range pvcs {
+			if objectExists(t, r, pvc.Name, pvc.Namespace, &corev1.PersistentVolumeClaim{}) {
+				pvcsLeft++
+			}
+		}
+
+		if *sts.Spec.Replicas == 0 && targetRunning {
+			t.Fatalf("expected replicas to be scaled down after the target pods are removed")
+		}
+		if pvcsLeft != len(pvcs) && replicasRunning {
+			t.Fatalf("expected replica pvcs to be deleted after the replica pods are removed")
+		}
+		got := getTestVolume(t, r, cr.Name)
+		completed = !controllerutil.ContainsFinalizer(got, jivaVolumeFinalizer)
+		if completed && (pvcsLeft != 0 ||
+			objectExists(t, r, "pvc-0", "", &corev1.PersistentVolume{})) {
+			t.Fatalf("expected finalizer to be removed after the replica volumes are deleted")
+		}
+
+		// remove the pods of the scaled down components
+		// as the deployment and statefulset controllers would
+		if *dep.Spec.Replicas == 0 && targetRunning {
+			if err := r.Delete(context.TODO(), targetPod); err != nil {
+				t.Fatalf("failed to delete target pod: %v", err)
+			}
+		}
+		if *sts.Spec.Replicas == 0 && replicasRunning {
+			for _, pod := range replicaPods {
+				if err := r.Delete(context.TODO(), pod); err != nil {
+					t.Fatalf("failed to delete replica pod: %v", err)
+				}
+			}
+		}
+	}
+	if !completed {
+		t.Fatalf("expected teardown to be completed")
+	}
+}
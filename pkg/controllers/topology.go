@@ -0,0 +1,139 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const topologySpreadReason = "TopologySpread"
+
+// reconcileTopologySpread updates the status of the replica spread across
+// the topology domains and raises an event when the placement of the
+// replicas breaks the spread requested in the replica policy.
+func (r *JivaVolumeReconciler) reconcileTopologySpread(cr *jivaAPI.JivaVolume) error {
+	spread := cr.Spec.Policy.Replica.TopologySpread
+	if spread == nil {
+		if cr.Status.TopologySpread == nil {
+			return nil
+		}
+		cr.Status.TopologySpread = nil
+		return r.updateJivaVolume(cr)
+	}
+
+	status, err := r.getTopologySpreadStatus(cr, spread)
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(status, cr.Status.TopologySpread) {
+		return nil
+	}
+
+	wasViolated := cr.Status.TopologySpread != nil && cr.Status.TopologySpread.Violated
+	switch {
+	case status.Violated && !wasViolated:
+		logrus.Warningf("replicas of volume %s break the %s spread, skew: %d",
+			cr.Name, spread.TopologyKey, status.Skew)
+		r.Recorder.Eventf(cr, corev1.EventTypeWarning, topologySpreadReason,
+			"replicas break the %s spread, skew %d is more than %d, domains: %v",
+			spread.TopologyKey, status.Skew, spread.MaxSkew, status.Domains)
+	case !status.Violated && wasViolated:
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, topologySpreadReason,
+			"replicas are spread across %s as requested", spread.TopologyKey)
+	}
+	cr.Status.TopologySpread = status
+	return r.updateJivaVolume(cr)
+}
+
+// getTopologySpreadStatus counts the scheduled replica pods in each domain
+// of the topology key. All the domains of the nodes eligible for the replicas
+// are considered, so an empty domain adds to the skew. A replica on a node
+// without the topology key breaks the spread.
+func (r *JivaVolumeReconciler) getTopologySpreadStatus(cr *jivaAPI.JivaVolume,
+	spread *jivaAPI.ReplicaTopologySpread) (*jivaAPI.TopologySpreadStatus, error) {
+	nodes := &corev1.NodeList{}
+	opts := []client.ListOption{}
+	if len(cr.Spec.Policy.Replica.NodeSelector) != 0 {
+		opts = append(opts, client.MatchingLabels(cr.Spec.Policy.Replica.NodeSelector))
+	}
+	if err := r.List(context.TODO(), nodes, opts...); err != nil {
+		return nil, err
+	}
+
+	domains := map[string]int{}
+	nodeDomains := map[string]string{}
+	for _, node := range nodes.Items {
+		if domain, ok := node.Labels[spread.TopologyKey]; ok {
+			nodeDomains[node.Name] = domain
+			domains[domain] = 0
+		}
+	}
+
+	labelSelector, err := labels.Parse(replicaComponentSelector + cr.Name)
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	err = r.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &jivaAPI.TopologySpreadStatus{
+		TopologyKey: spread.TopologyKey,
+		Domains:     domains,
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		domain, ok := nodeDomains[pod.Spec.NodeName]
+		if !ok {
+			status.Violated = true
+			continue
+		}
+		domains[domain]++
+	}
+
+	first := true
+	var min, max int
+	for _, count := range domains {
+		if first || count < min {
+			min = count
+		}
+		if first || count > max {
+			max = count
+		}
+		first = false
+	}
+	status.Skew = int32(max - min)
+	if status.Skew > spread.MaxSkew {
+		status.Violated = true
+	}
+	return status, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/openebs/jiva-operator/version"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	upgradeReason   = "Upgrade"
+	versionLabelKey = "openebs.io/version"
+
+	// upgradePollInterval is the interval at which the progress
+	// of the upgrade of the data plane of a volume is checked
+	upgradePollInterval = 10 * time.Second
+)
+
+// isUpgradeRollback checks if the desired version has been set back to
+// the current version while the data plane upgrade is in progress
+func isUpgradeRollback(cr *jivaAPI.JivaVolume) bool {
+	return cr.VersionDetails.Desired == cr.VersionDetails.Status.Current &&
+		cr.VersionDetails.Status.UpgradeStep != ""
+}
+
+// upgradeImages returns the images of the operator version keyed by
+// the container name, the images set by the policy of the volume are
+// kept as they are.
+func upgradeImages(cr *jivaAPI.JivaVolume) map[string]string {
+	policy := cr.Spec.Policy
+	return map[string]string{
+		"jiva-controller": policyImage(policy.Target.Image,
+			"OPENEBS_IO_JIVA_CONTROLLER_IMAGE", "jiva-controller"),
+		"maya-volume-exporter": policyImage(policy.Target.MonitorImage,
+			"OPENEBS_IO_MAYA_EXPORTER_IMAGE", "exporter"),
+		"jiva-replica": policyImage(policy.Replica.Image,
+			"OPENEBS_IO_JIVA_REPLICA_IMAGE", "jiva-replica"),
+	}
+}
+
+// withImages returns a mutator which sets the given
+// images and the version label of the pod template
+func withImages(images map[string]string, ver string) podTemplateMutator {
+	return func(template *corev1.PodTemplateSpec) {
+		if template.Labels == nil {
+			template.Labels = map[string]string{}
+		}
+		template.Labels[versionLabelKey] = ver
+		for i, c := range template.Spec.Containers {
+			if image, ok := images[c.Name]; ok {
+				template.Spec.Containers[i].Image = image
+			}
+		}
+	}
+}
+
+// startUpgrade records the images of the volume containers
+// and starts the upgrade of the replicas.
+func (r *JivaVolumeReconciler) startUpgrade(cr *jivaAPI.JivaVolume) error {
+	images := map[string]string{}
+	templates := []struct {
+		obj  client.Object
+		name string
+	}{
+		{&appsv1.Deployment{}, cr.Name + "-jiva-ctrl"},
+		{&appsv1.StatefulSet{}, cr.Name + "-jiva-rep"},
+	}
+	for _, t := range templates {
+		err := r.Get(context.TODO(),
+			types.NamespacedName{Name: t.name, Namespace: cr.Namespace}, t.obj)
+		if err != nil {
+			return err
+		}
+		var containers []corev1.Container
+		switch obj := t.obj.(type) {
+		case *appsv1.Deployment:
+			containers = obj.Spec.Template.Spec.Containers
+		case *appsv1.StatefulSet:
+			containers = obj.Spec.Template.Spec.Containers
+		}
+		for _, c := range containers {
+			images[c.Name] = c.Image
+		}
+	}
+
+	logrus.Infof("upgrading volume %s from %s to %s", cr.Name,
+		cr.VersionDetails.Status.Current, cr.VersionDetails.Desired)
+	r.Recorder.Eventf(cr, corev1.EventTypeNormal, upgradeReason,
+		"upgrading from %s to %s", cr.VersionDetails.Status.Current, cr.VersionDetails.Desired)
+	cr.VersionDetails.Status.PreviousImages = images
+	cr.VersionDetails.Status.UpgradeStep = jivaAPI.UpgradeStepReplicas
+	return r.updateJivaVolume(cr)
+}
+
+// upgradeDataPlane updates the images and the version labels of the
+// replicas and the target. The replicas are restarted one at a time,
+// each replica has to be back in RW mode before the next is restarted,
+// and the target is restarted last. In case of a rollback the previous
+// images are restored in the same order. It returns true once the
+// upgrade is complete.
+func (r *JivaVolumeReconciler) upgradeDataPlane(ctx context.Context, cr *jivaAPI.JivaVolume) (bool, error) {
+	if err := r.getAndUpdateVolumeStatus(ctx, cr); err != nil {
+		return false, err
+	}
+
+	images, ver := upgradeImages(cr), version.Version
+	if isUpgradeRollback(cr) {
+		images, ver = cr.VersionDetails.Status.PreviousImages, cr.VersionDetails.Status.Current
+	}
+	mutate := withImages(images, ver)
+
+	done, err := r.rolloutReplicas(cr, mutate)
+	if err != nil || !done {
+		return false, err
+	}
+	if err := r.setVersionLabel(cr, cr.Name+"-jiva-rep", &appsv1.StatefulSet{}, ver); err != nil {
+		return false, err
+	}
+
+	if cr.VersionDetails.Status.UpgradeStep != jivaAPI.UpgradeStepTarget {
+		r.Recorder.Eventf(cr, corev1.EventTypeNormal, upgradeReason,
+			"replicas updated to version %s, restarting target", ver)
+		cr.VersionDetails.Status.UpgradeStep = jivaAPI.UpgradeStepTarget
+		return false, r.updateJivaVolume(cr)
+	}
+
+	done, err = r.rolloutTarget(cr, mutate)
+	if err != nil || !done {
+		return false, err
+	}
+	if err := r.setVersionLabel(cr, cr.Name+"-jiva-ctrl", &appsv1.Deployment{}, ver); err != nil {
+		return false, err
+	}
+	if err := r.setVersionLabel(cr, cr.Name+"-jiva-ctrl-svc", &corev1.Service{}, ver); err != nil {
+		return false, err
+	}
+	return allReplicasRW(cr), nil
+}
+
+// setVersionLabel sets the version label of the given volume component
+func (r *JivaVolumeReconciler) setVersionLabel(cr *jivaAPI.JivaVolume, name string, obj client.Object, ver string) error {
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cr.Namespace}, obj)
+	if err != nil {
+		return err
+	}
+	labels := obj.GetLabels()
+	if labels[versionLabelKey] == ver {
+		return nil
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[versionLabelKey] = ver
+	obj.SetLabels(labels)
+	return r.Update(context.TODO(), obj)
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/volume"
+	"github.com/openebs/jiva-operator/version"
+)
+
+func TestIsUpgradeRollback(t *testing.T) {
+	tests := map[string]struct {
+		current, desired string
+		step             jivaAPI.UpgradeStep
+		expectRollback   bool
+	}{
+		"Test upgraded volume": {
+			current: "3.0.0", desired: "3.0.0",
+		},
+		"Test upgrade not started": {
+			current: "2.12.0", desired: "3.0.0",
+		},
+		"Test upgrade in progress": {
+			current: "2.12.0", desired: "3.0.0", step: jivaAPI.UpgradeStepReplicas,
+		},
+		"Test desired version reverted during the replica upgrade": {
+			current: "2.12.0", desired: "2.12.0", step: jivaAPI.UpgradeStepReplicas,
+			expectRollback: true,
+		},
+		"Test desired version reverted during the target upgrade": {
+			current: "2.12.0", desired: "2.12.0", step: jivaAPI.UpgradeStepTarget,
+			expectRollback: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr := &jivaAPI.JivaVolume{}
+			cr.VersionDetails.Desired = mock.desired
+			cr.VersionDetails.Status.Current = mock.current
+			cr.VersionDetails.Status.UpgradeStep = mock.step
+			if got := isUpgradeRollback(cr); got != mock.expectRollback {
+				t.Fatalf("Test %q failed: expected rollback to be %t, got %t", name, mock.expectRollback, got)
+			}
+		})
+	}
+}
+
+// startTestTarget serves the stats of a healthy volume with the given
+// number of replicas on the target API port of the loopback address.
+func startTestTarget(t *testing.T, replicas int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:9501")
+	if err != nil {
+		t.Skipf("target API port is not available: %v", err)
+	}
+	stats := volume.Stats{TargetStatus: "RW"}
+	for i := 0; i < replicas; i++ {
+		stats.Replicas = append(stats.Replicas, volume.Replica{
+			Address: fmt.Sprintf("tcp://10.0.0.%d:9502", i+1),
+			Mode:    "RW",
+		})
+	}
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_ = json.NewEncoder(w).Encode(stats)
+		})},
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+}
+
+// newTestUpgradeVolume returns a volume along with the replica statefulset,
+// the target deployment and service running the given images
+func newTestUpgradeVolume(images map[string]string) (*jivaAPI.JivaVolume, []client.Object) {
+	cr := newTestVolume("pv", 3)
+	cr.Spec.ISCSISpec.TargetIP = "127.0.0.1"
+	cr.VersionDetails.Desired = version.Version
+	cr.VersionDetails.Status.Current = "2.12.0"
+	cr.VersionDetails.Status.UpgradeStep = jivaAPI.UpgradeStepReplicas
+	cr.VersionDetails.Status.PreviousImages = map[string]string{
+		"jiva-controller":      "openebs/jiva:2.12.0",
+		"maya-volume-exporter": "openebs/m-exporter:2.12.0",
+		"jiva-replica":         "openebs/jiva:2.12.0",
+	}
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: ptr.To(int32(3)),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "jiva-replica", Image: images["jiva-replica"]},
+				}},
+			},
+		},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 3},
+	}
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(1)),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "jiva-controller", Image: images["jiva-controller"]},
+					{Name: "maya-volume-exporter", Image: images["maya-volume-exporter"]},
+				}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Name + "-jiva-ctrl-svc", Namespace: cr.Namespace},
+	}
+	return cr, []client.Object{cr, sts, dep, svc}
+}
+
+// rolloutTestComponents marks the updated pods of the replica statefulset
+// and the target deployment as recreated, as their controllers would.
+func rolloutTestComponents(t *testing.T, r *JivaVolumeReconciler, cr *jivaAPI.JivaVolume) {
+	sts := &appsv1.StatefulSet{}
+	key := types.NamespacedName{Name: cr.Name + "-jiva-rep", Namespace: cr.Namespace}
+	if err := r.Get(context.TODO(), key, sts); err != nil {
+		t.Fatalf("failed to get statefulset: %v", err)
+	}
+	sts.Status.ObservedGeneration = sts.Generation
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		sts.Status.UpdatedReplicas = *sts.Spec.Replicas - *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	if err := r.Status().Update(context.TODO(), sts); err != nil {
+		t.Fatalf("failed to update statefulset status: %v", err)
+	}
+
+	dep := &appsv1.Deployment{}
+	key = types.NamespacedName{Name: cr.Name + "-jiva-ctrl", Namespace: cr.Namespace}
+	if err := r.Get(context.TODO(), key, dep); err != nil {
+		t.Fatalf("failed to get deployment: %v", err)
+	}
+	dep.Status.ObservedGeneration = dep.Generation
+	if err := r.Status().Update(context.TODO(), dep); err != nil {
+		t.Fatalf("failed to update deployment status: %v", err)
+	}
+}
+
+func TestUpgradeDataPlane(t *testing.T) {
+	startTestTarget(t, 3)
+	t.Setenv("OPENEBS_IO_JIVA_CONTROLLER_IMAGE", "openebs/jiva:ci")
+	t.Setenv("OPENEBS_IO_JIVA_REPLICA_IMAGE", "openebs/jiva:ci")
+	t.Setenv("OPENEBS_IO_MAYA_EXPORTER_IMAGE", "openebs/m-exporter:ci")
+
+	oldImages := map[string]string{
+		"jiva-controller":      "openebs/jiva:2.12.0",
+		"maya-volume-exporter": "openebs/m-exporter:2.12.0",
+		"jiva-replica":         "openebs/jiva:2.12.0",
+	}
+	newImages := map[string]string{
+		"jiva-controller":      "openebs/jiva:ci",
+		"maya-volume-exporter": "openebs/m-exporter:ci",
+		"jiva-replica":         "openebs/jiva:ci",
+	}
+	tests := map[string]struct {
+		// setup updates the volume and its components
+		// to the state the upgrade is resumed from
+		setup func(cr *jivaAPI.JivaVolume, sts *appsv1.StatefulSet)
+		// expectRestarts is the number of replicas restarted
+		expectRestarts int
+		expectImages   map[string]string
+		expectVersion  string
+	}{
+		"Test upgrade of the replicas and the target": {
+			expectRestarts: 3,
+			expectImages:   newImages,
+			expectVersion:  version.Version,
+		},
+		"Test upgrade resumed from the target step": {
+			setup: func(cr *jivaAPI.JivaVolume, sts *appsv1.StatefulSet) {
+				cr.VersionDetails.Status.UpgradeStep = jivaAPI.UpgradeStepTarget
+				sts.Labels = map[string]string{versionLabelKey: version.Version}
+				sts.Spec.Template.Labels = map[string]string{versionLabelKey: version.Version}
+				sts.Spec.Template.Spec.Containers[0].Image = newImages["jiva-replica"]
+			},
+			expectImages:  newImages,
+			expectVersion: version.Version,
+		},
+		"Test upgrade resumed with a replica left to restart": {
+			setup: func(cr *jivaAPI.JivaVolume, sts *appsv1.StatefulSet) {
+				sts.Spec.Template.Labels = map[string]string{versionLabelKey: version.Version}
+				sts.Spec.Template.Spec.Containers[0].Image = newImages["jiva-replica"]
+				sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{
+					Partition: ptr.To(int32(1)),
+				}
+				sts.Status.UpdatedReplicas = 2
+			},
+			expectRestarts: 1,
+			expectImages:   newImages,
+			expectVersion:  version.Version,
+		},
+		"Test rollback during the target step": {
+			setup: func(cr *jivaAPI.JivaVolume, sts *appsv1.StatefulSet) {
+				cr.VersionDetails.Desired = cr.VersionDetails.Status.Current
+				cr.VersionDetails.Status.UpgradeStep = jivaAPI.UpgradeStepTarget
+				sts.Spec.Template.Labels = map[string]string{versionLabelKey: version.Version}
+				sts.Spec.Template.Spec.Containers[0].Image = newImages["jiva-replica"]
+			},
+			expectRestarts: 3,
+			expectImages:   oldImages,
+			expectVersion:  "2.12.0",
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			cr, objs := newTestUpgradeVolume(oldImages)
+			if mock.setup != nil {
+				mock.setup(cr, objs[1].(*appsv1.StatefulSet))
+			}
+			r := newTestReconciler(t, objs...)
+
+			restarts := 0
+			done := false
+			for i := 0; i < 20 && !done; i++ {
+				cr = getTestVolume(t, r, cr.Name)
+				step := cr.VersionDetails.Status.UpgradeStep
+				before := &appsv1.StatefulSet{}
+				objectExists(t, r, cr.Name+"-jiva-rep", cr.Namespace, before)
+				var err error
+				done, err = r.upgradeDataPlane(context.TODO(), cr)
+				if err != nil {
+					t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+				}
+
+				after := &appsv1.StatefulSet{}
+				objectExists(t, r, cr.Name+"-jiva-rep", cr.Namespace, after)
+				if p := after.Spec.UpdateStrategy.RollingUpdate; p != nil && p.Partition != nil &&
+					before.Spec.UpdateStrategy.RollingUpdate != nil &&
+					*p.Partition < *before.Spec.UpdateStrategy.RollingUpdate.Partition {
+					restarts++
+				}
+				dep := &appsv1.Deployment{}
+				objectExists(t, r, cr.Name+"-jiva-ctrl", cr.Namespace, dep)
+				if step == jivaAPI.UpgradeStepReplicas &&
+					dep.Spec.Template.Spec.Containers[0].Image != oldImages["jiva-controller"] {
+					t.Fatalf("Test %q failed: expected target to be restarted after the replicas", name)
+				}
+				rolloutTestComponents(t, r, cr)
+			}
+			if !done {
+				t.Fatalf("Test %q failed: expected upgrade to be completed", name)
+			}
+			if restarts != mock.expectRestarts {
+				t.Fatalf("Test %q failed: expected %d replica restarts, got %d", name, mock.expectRestarts, restarts)
+			}
+
+			sts := &appsv1.StatefulSet{}
+			objectExists(t, r, cr.Name+"-jiva-rep", cr.Namespace, sts)
+			dep := &appsv1.Deployment{}
+			objectExists(t, r, cr.Name+"-jiva-ctrl", cr.Namespace, dep)
+			svc := &corev1.Service{}
+			objectExists(t, r, cr.Name+"-jiva-ctrl-svc", cr.Namespace, svc)
+			images := map[string]string{}
+			for _, c := range append(sts.Spec.Template.Spec.Containers, dep.Spec.Template.Spec.Containers...) {
+				images[c.Name] = c.Image
+			}
+			for c, image := range mock.expectImages {
+				if images[c] != image {
+					t.Fatalf("Test %q failed: expected image %s of %s, got %s", name, image, c, images[c])
+				}
+			}
+			for _, obj := range []client.Object{sts, dep, svc} {
+				if obj.GetLabels()[versionLabelKey] != mock.expectVersion {
+					t.Fatalf("Test %q failed: expected version label %s on %s, got %s",
+						name, mock.expectVersion, obj.GetName(), obj.GetLabels()[versionLabelKey])
+				}
+			}
+		})
+	}
+}
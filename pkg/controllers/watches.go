@@ -0,0 +1,104 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	casTypeLabel = "openebs.io/cas-type"
+	pvLabel      = "openebs.io/persistent-volume"
+)
+
+// jivaPodPredicate filters the target and replica pods of the jiva volumes
+var jivaPodPredicate = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	return obj.GetLabels()[casTypeLabel] == "jiva" && obj.GetLabels()[pvLabel] != ""
+})
+
+// nodePredicate filters the node events which can affect the
+// placement of the jiva pods, i.e. the node is added or removed,
+// its readiness changes or it is cordoned.
+var nodePredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return isNodeReady(oldNode) != isNodeReady(newNode) ||
+			oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// volumeForPod maps a target or replica pod to its volume
+func (r *JivaVolumeReconciler) volumeForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{
+			Name:      obj.GetLabels()[pvLabel],
+			Namespace: obj.GetNamespace(),
+		},
+	}}
+}
+
+// volumesForNode maps a node to the volumes which have a target or
+// replica pod on the node, or a replica PVC bound to the node.
+func (r *JivaVolumeReconciler) volumesForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	volumes := map[types.NamespacedName]bool{}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.MatchingLabels{casTypeLabel: "jiva"}); err != nil {
+		logrus.Errorf("failed to list jiva pods for node %s: %v", obj.GetName(), err)
+		return nil
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == obj.GetName() && pod.Labels[pvLabel] != "" {
+			volumes[types.NamespacedName{Name: pod.Labels[pvLabel], Namespace: pod.Namespace}] = true
+		}
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcs, client.MatchingLabels{casTypeLabel: "jiva"}); err != nil {
+		logrus.Errorf("failed to list jiva replica pvcs for node %s: %v", obj.GetName(), err)
+		return nil
+	}
+	for _, pvc := range pvcs.Items {
+		if pvc.Annotations[selectedNodeAnnotation] == obj.GetName() && pvc.Labels[pvLabel] != "" {
+			volumes[types.NamespacedName{Name: pvc.Labels[pvLabel], Namespace: pvc.Namespace}] = true
+		}
+	}
+
+	requests := []reconcile.Request{}
+	for nn := range volumes {
+		requests = append(requests, reconcile.Request{NamespacedName: nn})
+	}
+	return requests
+}
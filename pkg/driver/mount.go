@@ -249,6 +249,10 @@ func (n *NodeMounter) MonitorMounts() {
 				vol.Spec.MountInfo.TargetPath == "" {
 				continue
 			}
+			// apply the changes of the QoS of the volume
+			if err := applyQoS(&vol); err != nil {
+				logrus.Debugf("MonitorMounts: failed to apply QoS of volume %s, err: {%v}", vol.Name, err)
+			}
 			// ignore monitoring the mount for a block device
 			if vol.Spec.AccessType == "block" {
 				continue
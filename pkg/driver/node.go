@@ -486,6 +486,7 @@ func (ns *node) NodePublishVolume(
 
 update:
 	instance.Spec.MountInfo.TargetPath = target
+	instance.Spec.MountInfo.PodUID = req.GetVolumeContext()[podUIDContextKey]
 	if conflict, err := ns.client.UpdateJivaVolume(instance); err != nil {
 		if conflict {
 			logrus.Infof("Failed to update JivaVolume CR, err: %v. Retrying", err)
@@ -498,6 +499,10 @@ update:
 		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	// the limits are applied again by the mount monitor if this fails
+	if err := applyQoS(instance); err != nil {
+		logrus.Warningf("NodePublishVolume: failed to apply QoS of volume %s, err: %v", volumeID, err)
+	}
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
@@ -622,6 +627,7 @@ update:
 		return nil, err
 	}
 	instance.Spec.MountInfo.TargetPath = ""
+	instance.Spec.MountInfo.PodUID = ""
 	if conflict, err := ns.client.UpdateJivaVolume(instance); err != nil {
 		if conflict {
 			logrus.Infof("Failed to update JivaVolume CR, err: %v. Retrying", err)
@@ -630,6 +636,7 @@ update:
 		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	forgetQoS(instance.Name)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// podUIDContextKey is set in the volume context of NodePublishVolume
+	// as podInfoOnMount is enabled for the driver
+	podUIDContextKey = "csi.storage.k8s.io/pod.uid"
+)
+
+var (
+	// hostCgroupRoot is the cgroup v2 hierarchy of the
+	// host, the host root is mounted at /host in the plugin
+	hostCgroupRoot = "/host/sys/fs/cgroup"
+
+	qosLock sync.Mutex
+	// appliedQoS are the io.max entries written for the
+	// volumes along with the pod UID, keyed by the volume
+	appliedQoS = map[string]string{}
+)
+
+// applyQoS limits the I/O of the consuming pod on the iSCSI device of the
+// volume as per the QoS of the target policy, by writing the limits to the
+// io.max of the cgroup of the pod. The limits are written again only if
+// they have changed, so it is called periodically to apply the changes to
+// a running volume.
+func applyQoS(vol *jivaAPI.JivaVolume) error {
+	devicePath := vol.Spec.MountInfo.DevicePath
+	podUID := vol.Spec.MountInfo.PodUID
+	if devicePath == "" || podUID == "" {
+		return nil
+	}
+
+	qosLock.Lock()
+	defer qosLock.Unlock()
+	applied, ok := appliedQoS[vol.Name]
+	if !ok && vol.Spec.Policy.Target.QoS == nil {
+		// nothing to apply or remove
+		return nil
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(devicePath, &st); err != nil {
+		return fmt.Errorf("failed to stat device %s: %v", devicePath, err)
+	}
+	entry := ioMaxEntry(unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev)),
+		vol.Spec.Policy.Target.QoS)
+	if applied == podUID+" "+entry {
+		return nil
+	}
+
+	dir, err := podCgroupDir(podUID)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "io.max"), []byte(entry), 0644); err != nil {
+		return fmt.Errorf("failed to set io.max of pod %s: %v", podUID, err)
+	}
+	logrus.Infof("applied I/O limits {%s} of volume %s to pod %s", entry, vol.Name, podUID)
+	appliedQoS[vol.Name] = podUID + " " + entry
+	return nil
+}
+
+// forgetQoS removes the applied limits of the volume once it is unpublished,
+// the limits are removed along with the cgroup of the pod
+func forgetQoS(volName string) {
+	qosLock.Lock()
+	defer qosLock.Unlock()
+	delete(appliedQoS, volName)
+}
+
+// ioMaxEntry returns the io.max entry for the device,
+// the limits which are not set are reset to max
+func ioMaxEntry(major, minor uint32, qos *jivaAPI.QoSSpec) string {
+	limits := jivaAPI.QoSSpec{}
+	if qos != nil {
+		limits = *qos
+	}
+	limit := func(v int64) string {
+		if v <= 0 {
+			return "max"
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprintf("%d:%d rbps=%s wbps=%s riops=%s wiops=%s", major, minor,
+		limit(limits.ReadBPS), limit(limits.WriteBPS), limit(limits.ReadIOPS), limit(limits.WriteIOPS))
+}
+
+// podCgroupDir returns the cgroup of the pod for both
+// the systemd and the cgroupfs cgroup drivers of kubelet
+func podCgroupDir(podUID string) (string, error) {
+	if _, err := os.Stat(filepath.Join(hostCgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not available on the node: %v", err)
+	}
+	escaped := strings.ReplaceAll(podUID, "-", "_")
+	candidates := []string{
+		"kubepods.slice/kubepods-pod" + escaped + ".slice",
+		"kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + escaped + ".slice",
+		"kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod" + escaped + ".slice",
+		"kubepods/pod" + podUID,
+		"kubepods/burstable/pod" + podUID,
+		"kubepods/besteffort/pod" + podUID,
+	}
+	for _, c := range candidates {
+		dir := filepath.Join(hostCgroupRoot, c)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("cgroup of pod %s not found", podUID)
+}
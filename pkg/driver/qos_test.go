@@ -0,0 +1,173 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const testPodUID = "5b8f6a2e-1c3d-4e5f-8a9b-0c1d2e3f4a5b"
+
+// setTestCgroupRoot creates a cgroup v2 hierarchy in a temp directory
+// with the given cgroups of the pods and uses it as the host cgroup root
+func setTestCgroupRoot(t *testing.T, v2 bool, cgroups ...string) string {
+	root := t.TempDir()
+	if v2 {
+		if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("io memory"), 0644); err != nil {
+			t.Fatalf("failed to create cgroup.controllers: %v", err)
+		}
+	}
+	for _, c := range cgroups {
+		if err := os.MkdirAll(filepath.Join(root, c), 0755); err != nil {
+			t.Fatalf("failed to create cgroup %s: %v", c, err)
+		}
+	}
+	cgroupRoot := hostCgroupRoot
+	hostCgroupRoot = root
+	t.Cleanup(func() { hostCgroupRoot = cgroupRoot })
+	return root
+}
+
+func TestIOMaxEntry(t *testing.T) {
+	tests := map[string]struct {
+		qos         *jivaAPI.QoSSpec
+		expectEntry string
+	}{
+		"Test without QoS": {
+			expectEntry: "8:16 rbps=max wbps=max riops=max wiops=max",
+		},
+		"Test with all the limits": {
+			qos: &jivaAPI.QoSSpec{
+				ReadIOPS: 100, WriteIOPS: 200, ReadBPS: 1048576, WriteBPS: 2097152,
+			},
+			expectEntry: "8:16 rbps=1048576 wbps=2097152 riops=100 wiops=200",
+		},
+		"Test with some of the limits": {
+			qos:         &jivaAPI.QoSSpec{WriteIOPS: 200},
+			expectEntry: "8:16 rbps=max wbps=max riops=max wiops=200",
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			if got := ioMaxEntry(8, 16, mock.qos); got != mock.expectEntry {
+				t.Fatalf("Test %q failed: expected entry %q, got %q", name, mock.expectEntry, got)
+			}
+		})
+	}
+}
+
+func TestPodCgroupDir(t *testing.T) {
+	escaped := "5b8f6a2e_1c3d_4e5f_8a9b_0c1d2e3f4a5b"
+	tests := map[string]struct {
+		v2        bool
+		cgroup    string
+		expectErr bool
+	}{
+		"Test guaranteed pod with the systemd driver": {
+			v2:     true,
+			cgroup: "kubepods.slice/kubepods-pod" + escaped + ".slice",
+		},
+		"Test burstable pod with the systemd driver": {
+			v2:     true,
+			cgroup: "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + escaped + ".slice",
+		},
+		"Test besteffort pod with the cgroupfs driver": {
+			v2:     true,
+			cgroup: "kubepods/besteffort/pod" + testPodUID,
+		},
+		"Test missing pod cgroup": {
+			v2:        true,
+			cgroup:    "kubepods/burstable/pod00000000-0000-0000-0000-000000000000",
+			expectErr: true,
+		},
+		"Test node without cgroup v2": {
+			cgroup:    "kubepods/pod" + testPodUID,
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			root := setTestCgroupRoot(t, mock.v2, mock.cgroup)
+			dir, err := podCgroupDir(testPodUID)
+			if mock.expectErr {
+				if err == nil {
+					t.Fatalf("Test %q failed: expected error not to be nil, got cgroup %s", name, dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, err)
+			}
+			if dir != filepath.Join(root, mock.cgroup) {
+				t.Fatalf("Test %q failed: expected cgroup %s, got %s", name, filepath.Join(root, mock.cgroup), dir)
+			}
+		})
+	}
+}
+
+func TestApplyQoS(t *testing.T) {
+	// a character device stands in for the iSCSI device of the volume
+	const device = "/dev/null"
+	if _, err := os.Stat(device); err != nil {
+		t.Skipf("%s is not available: %v", device, err)
+	}
+	cgroup := "kubepods/pod" + testPodUID
+	root := setTestCgroupRoot(t, true, cgroup)
+	ioMax := filepath.Join(root, cgroup, "io.max")
+
+	vol := &jivaAPI.JivaVolume{}
+	vol.Name = "pvc-qos"
+	vol.Spec.MountInfo.DevicePath = device
+	vol.Spec.Policy.Target.QoS = &jivaAPI.QoSSpec{ReadIOPS: 100}
+	t.Cleanup(func() { forgetQoS(vol.Name) })
+
+	// the pod is not known till the volume is published
+	if err := applyQoS(vol); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	if _, err := os.Stat(ioMax); !os.IsNotExist(err) {
+		t.Fatalf("expected io.max not to be written without the pod UID, got: %v", err)
+	}
+
+	vol.Spec.MountInfo.PodUID = testPodUID
+	if err := applyQoS(vol); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	got, err := os.ReadFile(ioMax)
+	if err != nil {
+		t.Fatalf("expected io.max to be written, got: %v", err)
+	}
+	if expect := "1:3 rbps=max wbps=max riops=100 wiops=max"; string(got) != expect {
+		t.Fatalf("expected io.max %q, got %q", expect, got)
+	}
+
+	// the limits removed from the policy are reset
+	vol.Spec.Policy.Target.QoS = nil
+	if err := applyQoS(vol); err != nil {
+		t.Fatalf("expected error to be nil, got: %v", err)
+	}
+	got, _ = os.ReadFile(ioMax)
+	if expect := "1:3 rbps=max wbps=max riops=max wiops=max"; string(got) != expect {
+		t.Fatalf("expected io.max %q, got %q", expect, got)
+	}
+}
@@ -16,6 +16,7 @@ package jiva
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -45,6 +46,13 @@ func NewControllerClient(address string) *ControllerClient {
 	}
 }
 
+// NewReplicaClient creates the client for the REST API of a replica,
+// address is the replica address as reported by the target in /stats
+// (i.e. tcp://<replica-ip>:9502).
+func NewReplicaClient(address string) *ControllerClient {
+	return NewControllerClient(strings.TrimPrefix(address, "tcp://"))
+}
+
 // SetTimeout overrides the default timeout
 func (c *ControllerClient) SetTimeout(interval time.Duration) {
 	c.httpClient.Timeout = interval
@@ -53,12 +61,28 @@ func (c *ControllerClient) SetTimeout(interval time.Duration) {
 // Get sends a request to the specified path and stores body in the value
 // pointed to by obj.
 func (c *ControllerClient) Get(path string, obj interface{}) error {
-	resp, err := http.Get(c.Address + path)
+	return c.GetWithContext(context.Background(), path, obj)
+}
+
+// GetWithContext is same as Get, the request is cancelled once either
+// the context is done or the timeout of the client expires.
+func (c *ControllerClient) GetWithContext(ctx context.Context, path string, obj interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Address+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 300 {
+		content, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Bad response: %d %s: %s", resp.StatusCode, resp.Status, content)
+	}
+
 	return json.NewDecoder(resp.Body).Decode(obj)
 }
 
@@ -89,7 +113,7 @@ func (c *ControllerClient) Do(method, path string, req, resp interface{}) error
 	}
 	httpReq.Header.Set("Content-Type", bodyType)
 
-	httpResp, err := http.DefaultClient.Do(httpReq)
+	httpResp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -259,6 +259,63 @@ func (b *Builder) WithAffinity(affinity *corev1.Affinity) *Builder {
 	return b
 }
 
+// WithTopologySpreadConstraints sets the topology
+// spread constraints field of podtemplatespec
+func (b *Builder) WithTopologySpreadConstraints(constraints ...corev1.TopologySpreadConstraint) *Builder {
+	if len(constraints) == 0 {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build podtemplatespec object: missing topology spread constraints",
+			),
+		)
+		return b
+	}
+
+	// copy of original slice
+	newconstraints := []corev1.TopologySpreadConstraint{}
+	newconstraints = append(newconstraints, constraints...)
+
+	b.podtemplatespec.Object.Spec.TopologySpreadConstraints = newconstraints
+	return b
+}
+
+// WithSecurityContext sets the security context field of podtemplatespec
+func (b *Builder) WithSecurityContext(securityContext *corev1.PodSecurityContext) *Builder {
+	if securityContext == nil {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build podtemplatespec object: missing security context",
+			),
+		)
+		return b
+	}
+
+	b.podtemplatespec.Object.Spec.SecurityContext = securityContext.DeepCopy()
+	return b
+}
+
+// WithImagePullSecrets sets the image pull secrets field of podtemplatespec
+func (b *Builder) WithImagePullSecrets(secrets ...corev1.LocalObjectReference) *Builder {
+	if len(secrets) == 0 {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build podtemplatespec object: missing image pull secrets",
+			),
+		)
+		return b
+	}
+
+	// copy of original slice
+	newsecrets := []corev1.LocalObjectReference{}
+	newsecrets = append(newsecrets, secrets...)
+
+	b.podtemplatespec.Object.Spec.ImagePullSecrets = newsecrets
+	return b
+}
+
 // WithTolerations merges the existing tolerations
 // with the provided arguments
 func (b *Builder) WithTolerations(tolerations ...corev1.Toleration) *Builder {
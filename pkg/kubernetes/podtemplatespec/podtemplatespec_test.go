@@ -272,6 +272,129 @@ func TestBuildWithAffinity(t *testing.T) {
 	}
 }
 
+func TestBuildWithTopologySpreadConstraints(t *testing.T) {
+	tests := map[string]struct {
+		constraints []corev1.TopologySpreadConstraint
+		builder     *Builder
+		expectErr   bool
+	}{
+		"Test Builder with topology spread constraints": {
+			constraints: []corev1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: corev1.DoNotSchedule,
+				},
+			},
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: false,
+		},
+		"Test Builder without topology spread constraints": {
+			constraints: []corev1.TopologySpreadConstraint{},
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			b := mock.builder.WithTopologySpreadConstraints(mock.constraints...)
+			if mock.expectErr && len(b.errs) == 0 {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && len(b.errs) > 0 {
+				t.Fatalf("Test %q failed: expected error to be nil", name)
+			}
+			if !mock.expectErr && len(b.podtemplatespec.Object.Spec.TopologySpreadConstraints) != len(mock.constraints) {
+				t.Fatalf("Test %q failed: expected topology spread constraints to be set", name)
+			}
+		})
+	}
+}
+
+func TestBuildWithSecurityContext(t *testing.T) {
+	fsGroup := int64(1000)
+	tests := map[string]struct {
+		securityContext *corev1.PodSecurityContext
+		builder         *Builder
+		expectErr       bool
+	}{
+		"Test Builder with security context": {
+			securityContext: &corev1.PodSecurityContext{FSGroup: &fsGroup},
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: false,
+		},
+		"Test Builder without security context": {
+			securityContext: nil,
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			b := mock.builder.WithSecurityContext(mock.securityContext)
+			if mock.expectErr && len(b.errs) == 0 {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && len(b.errs) > 0 {
+				t.Fatalf("Test %q failed: expected error to be nil", name)
+			}
+			if !mock.expectErr && b.podtemplatespec.Object.Spec.SecurityContext == nil {
+				t.Fatalf("Test %q failed: expected security context to be set", name)
+			}
+		})
+	}
+}
+
+func TestBuildWithImagePullSecrets(t *testing.T) {
+	tests := map[string]struct {
+		secrets   []corev1.LocalObjectReference
+		builder   *Builder
+		expectErr bool
+	}{
+		"Test Builder with image pull secrets": {
+			secrets: []corev1.LocalObjectReference{
+				{Name: "registry-secret"},
+			},
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: false,
+		},
+		"Test Builder without image pull secrets": {
+			secrets: []corev1.LocalObjectReference{},
+			builder: &Builder{podtemplatespec: &PodTemplateSpec{
+				Object: &corev1.PodTemplateSpec{},
+			}},
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			b := mock.builder.WithImagePullSecrets(mock.secrets...)
+			if mock.expectErr && len(b.errs) == 0 {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && len(b.errs) > 0 {
+				t.Fatalf("Test %q failed: expected error to be nil", name)
+			}
+			if !mock.expectErr && len(b.podtemplatespec.Object.Spec.ImagePullSecrets) != len(mock.secrets) {
+				t.Fatalf("Test %q failed: expected image pull secrets to be set", name)
+			}
+		})
+	}
+}
+
 func TestBuildWithContainerBuilders(t *testing.T) {
 	tests := map[string]struct {
 		conBuilders []*container.Builder
@@ -16,7 +16,11 @@ limitations under the License.
 
 package utils
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/docker/go-units"
+)
 
 const maxNameLen = 43
 
@@ -36,3 +40,9 @@ func StripName(name string) string {
 	}
 	return name
 }
+
+// CapacityInBytes parses the capacity of a volume, e.g. 4Gi, the same
+// way as it is parsed for the replica statefulset
+func CapacityInBytes(capacity string) (int64, error) {
+	return units.RAMInBytes(strings.Split(capacity, "i")[0])
+}
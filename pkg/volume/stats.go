@@ -102,6 +102,30 @@ type Replica struct {
 	Mode string `json:"Mode"`
 }
 
+// RebuildInfo is used to store the rebuild progress reported by
+// a replica which is in WO mode.
+type RebuildInfo struct {
+	Resource
+	// Snapshots keeps the sync details of the snapshots
+	// being synced from the healthy replica.
+	Snapshots []SnapshotSyncInfo `json:"snapshots"`
+}
+
+// SnapshotSyncInfo is used to store the sync details of
+// a snapshot being rebuilt.
+type SnapshotSyncInfo struct {
+	// Name is the name of the snapshot
+	Name string `json:"name"`
+	// RWSize is the size of the snapshot in bytes on the
+	// healthy replica it is being synced from.
+	RWSize json.Number `json:"rwSize"`
+	// WOSize is the size of the snapshot in bytes synced
+	// so far to the rebuilding replica.
+	WOSize json.Number `json:"woSize"`
+	// Status is the sync status of the snapshot
+	Status string `json:"status"`
+}
+
 // Resource keep id, links, actions associated with the volume
 type Resource struct {
 	Id      string            `json:"id,omitempty"`
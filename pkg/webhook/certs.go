@@ -0,0 +1,396 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	operr "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+const (
+	// ConfigurationName is the name of the mutating and the validating
+	// webhook configurations managed by the operator
+	ConfigurationName = "jiva-operator-webhook"
+	// CertSecretName is the name of the secret in the operator namespace
+	// which holds the CA and the serving certificate of the webhooks
+	CertSecretName = "jiva-operator-webhook-certs"
+
+	caCertKey = "ca.crt"
+	caKeyKey  = "ca.key"
+
+	conversionPath = "/convert"
+
+	certValidity = 10 * 365 * 24 * time.Hour
+	// certRenewBefore is the time before the expiry of the
+	// certificate at which a new certificate is generated
+	certRenewBefore = 30 * 24 * time.Hour
+)
+
+// CertConfig is the configuration of the webhook server
+// certificate and the webhook configurations
+type CertConfig struct {
+	// Namespace and ServiceName of the service in front of the webhook server
+	Namespace   string
+	ServiceName string
+	// CertDir is the directory from which the webhook server loads
+	// the certificate, as tls.crt and tls.key
+	CertDir string
+}
+
+// SetupCertificates ensures that the secret in the operator namespace holds
+// a valid self signed certificate for the webhook service, writes it to the
+// cert dir of the webhook server and injects the CA into the webhook
+// configurations and the conversion webhooks of the CRDs. The certificate
+// is regenerated when it is about to expire, which happens on a restart
+// of the operator.
+func SetupCertificates(ctx context.Context, cl client.Client, cfg CertConfig) error {
+	secret, err := ensureCertSecret(ctx, cl, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.CertDir, 0700); err != nil {
+		return operr.Wrapf(err, "failed to create cert dir %s", cfg.CertDir)
+	}
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if err := os.WriteFile(filepath.Join(cfg.CertDir, key), secret.Data[key], 0600); err != nil {
+			return operr.Wrapf(err, "failed to write %s to cert dir", key)
+		}
+	}
+
+	caBundle := secret.Data[caCertKey]
+	if err := ensureMutatingConfiguration(ctx, cl, cfg, caBundle); err != nil {
+		return err
+	}
+	if err := ensureValidatingConfiguration(ctx, cl, cfg, caBundle); err != nil {
+		return err
+	}
+	for _, name := range conversionCRDs {
+		if err := ensureCRDConversion(ctx, cl, cfg, name, caBundle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureCertSecret(ctx context.Context, cl client.Client, cfg CertConfig) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := cl.Get(ctx, types.NamespacedName{Name: CertSecretName, Namespace: cfg.Namespace}, secret)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, operr.Wrapf(err, "failed to get secret %s", CertSecretName)
+	}
+	exists := err == nil
+
+	if exists && isCertValid(secret.Data[corev1.TLSCertKey], cfg) {
+		return secret, nil
+	}
+
+	logrus.Infof("generating the certificate of the webhook service %s/%s", cfg.Namespace, cfg.ServiceName)
+	data, err := generateCerts(cfg)
+	if err != nil {
+		return nil, operr.Wrap(err, "failed to generate webhook certificate")
+	}
+
+	if exists {
+		secret.Data = data
+		if err := cl.Update(ctx, secret); err != nil {
+			return nil, operr.Wrapf(err, "failed to update secret %s", CertSecretName)
+		}
+		return secret, nil
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CertSecretName,
+			Namespace: cfg.Namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: data,
+	}
+	if err := cl.Create(ctx, secret); err != nil {
+		return nil, operr.Wrapf(err, "failed to create secret %s", CertSecretName)
+	}
+	return secret, nil
+}
+
+// isCertValid checks that the certificate is issued for the webhook
+// service and doesn't expire within the renewal period
+func isCertValid(certPEM []byte, cfg CertConfig) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(certRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+	return cert.VerifyHostname(serviceDNSNames(cfg)[0]) == nil
+}
+
+func serviceDNSNames(cfg CertConfig) []string {
+	return []string{
+		fmt.Sprintf("%s.%s.svc", cfg.ServiceName, cfg.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", cfg.ServiceName, cfg.Namespace),
+	}
+}
+
+// generateCerts generates a self signed CA and a serving
+// certificate signed by it for the webhook service
+func generateCerts(cfg CertConfig) (map[string][]byte, error) {
+	now := time.Now()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: "jiva-operator-webhook-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	dnsNames := serviceDNSNames(cfg)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		caCertKey:               encodePEM("CERTIFICATE", caDER),
+		caKeyKey:                encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(caKey)),
+		corev1.TLSCertKey:       encodePEM("CERTIFICATE", certDER),
+		corev1.TLSPrivateKeyKey: encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+	}, nil
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+// webhookRules returns the rules matching the create and
+// update requests of the given resource
+func webhookRules(resource string) []admissionv1.RuleWithOperations {
+	return []admissionv1.RuleWithOperations{{
+		Operations: []admissionv1.OperationType{admissionv1.Create, admissionv1.Update},
+		Rule: admissionv1.Rule{
+			APIGroups:   []string{jivaAPI.GroupVersion.Group},
+			APIVersions: []string{jivaAPI.GroupVersion.Version},
+			Resources:   []string{resource},
+		},
+	}}
+}
+
+// webhookSpec is the common configuration of a mutating and validating webhook
+type webhookSpec struct {
+	name     string
+	path     string
+	resource string
+	// failurePolicy of the jiva volume webhooks is Ignore so that
+	// the volume operations aren't blocked while the operator is down
+	failurePolicy admissionv1.FailurePolicyType
+}
+
+// conversionCRDs are the CRDs served in more than one version,
+// which are converted by the conversion webhook of the operator
+var conversionCRDs = []string{
+	"jivavolumes.openebs.io",
+	"jivavolumepolicies.openebs.io",
+}
+
+var (
+	mutatingWebhooks = []webhookSpec{
+		{"mjivavolumepolicy.openebs.io", "/mutate-openebs-io-v1-jivavolumepolicy", "jivavolumepolicies", admissionv1.Fail},
+		{"mjivavolume.openebs.io", "/mutate-openebs-io-v1-jivavolume", "jivavolumes", admissionv1.Ignore},
+	}
+	validatingWebhooks = []webhookSpec{
+		{"vjivavolumepolicy.openebs.io", "/validate-openebs-io-v1-jivavolumepolicy", "jivavolumepolicies", admissionv1.Fail},
+		{"vjivavolume.openebs.io", "/validate-openebs-io-v1-jivavolume", "jivavolumes", admissionv1.Ignore},
+	}
+)
+
+func clientConfig(cfg CertConfig, path string, caBundle []byte) admissionv1.WebhookClientConfig {
+	return admissionv1.WebhookClientConfig{
+		Service: &admissionv1.ServiceReference{
+			Namespace: cfg.Namespace,
+			Name:      cfg.ServiceName,
+			Path:      &path,
+		},
+		CABundle: caBundle,
+	}
+}
+
+func ensureMutatingConfiguration(ctx context.Context, cl client.Client, cfg CertConfig, caBundle []byte) error {
+	webhooks := []admissionv1.MutatingWebhook{}
+	for _, w := range mutatingWebhooks {
+		failurePolicy := w.failurePolicy
+		sideEffects := admissionv1.SideEffectClassNone
+		webhooks = append(webhooks, admissionv1.MutatingWebhook{
+			Name:                    w.name,
+			ClientConfig:            clientConfig(cfg, w.path, caBundle),
+			Rules:                   webhookRules(w.resource),
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			AdmissionReviewVersions: []string{"v1"},
+		})
+	}
+
+	config := &admissionv1.MutatingWebhookConfiguration{}
+	err := cl.Get(ctx, types.NamespacedName{Name: ConfigurationName}, config)
+	if k8serrors.IsNotFound(err) {
+		config = &admissionv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: ConfigurationName},
+			Webhooks:   webhooks,
+		}
+		if err := cl.Create(ctx, config); err != nil {
+			return operr.Wrapf(err, "failed to create mutating webhook configuration %s", ConfigurationName)
+		}
+		return nil
+	}
+	if err != nil {
+		return operr.Wrapf(err, "failed to get mutating webhook configuration %s", ConfigurationName)
+	}
+
+	// the webhooks are replaced as the server sets the defaults to the
+	// unset fields, which makes the configurations hard to compare
+	config.Webhooks = webhooks
+	if err := cl.Update(ctx, config); err != nil {
+		return operr.Wrapf(err, "failed to update mutating webhook configuration %s", ConfigurationName)
+	}
+	return nil
+}
+
+func ensureValidatingConfiguration(ctx context.Context, cl client.Client, cfg CertConfig, caBundle []byte) error {
+	webhooks := []admissionv1.ValidatingWebhook{}
+	for _, w := range validatingWebhooks {
+		failurePolicy := w.failurePolicy
+		sideEffects := admissionv1.SideEffectClassNone
+		webhooks = append(webhooks, admissionv1.ValidatingWebhook{
+			Name:                    w.name,
+			ClientConfig:            clientConfig(cfg, w.path, caBundle),
+			Rules:                   webhookRules(w.resource),
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			AdmissionReviewVersions: []string{"v1"},
+		})
+	}
+
+	config := &admissionv1.ValidatingWebhookConfiguration{}
+	err := cl.Get(ctx, types.NamespacedName{Name: ConfigurationName}, config)
+	if k8serrors.IsNotFound(err) {
+		config = &admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: ConfigurationName},
+			Webhooks:   webhooks,
+		}
+		if err := cl.Create(ctx, config); err != nil {
+			return operr.Wrapf(err, "failed to create validating webhook configuration %s", ConfigurationName)
+		}
+		return nil
+	}
+	if err != nil {
+		return operr.Wrapf(err, "failed to get validating webhook configuration %s", ConfigurationName)
+	}
+
+	// the webhooks are replaced as the server sets the defaults to the
+	// unset fields, which makes the configurations hard to compare
+	config.Webhooks = webhooks
+	if err := cl.Update(ctx, config); err != nil {
+		return operr.Wrapf(err, "failed to update validating webhook configuration %s", ConfigurationName)
+	}
+	return nil
+}
+
+// ensureCRDConversion sets the conversion strategy of the CRD to the
+// conversion webhook of the operator, which trusts the CA of the server
+func ensureCRDConversion(ctx context.Context, cl client.Client, cfg CertConfig, name string, caBundle []byte) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := cl.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+		return operr.Wrapf(err, "failed to get CRD %s", name)
+	}
+	path := conversionPath
+	conversion := &apiextensionsv1.CustomResourceConversion{
+		Strategy: apiextensionsv1.WebhookConverter,
+		Webhook: &apiextensionsv1.WebhookConversion{
+			ClientConfig: &apiextensionsv1.WebhookClientConfig{
+				Service: &apiextensionsv1.ServiceReference{
+					Namespace: cfg.Namespace,
+					Name:      cfg.ServiceName,
+					Path:      &path,
+				},
+				CABundle: caBundle,
+			},
+			ConversionReviewVersions: []string{"v1"},
+		},
+	}
+	if equality.Semantic.DeepEqual(crd.Spec.Conversion, conversion) {
+		return nil
+	}
+	crd.Spec.Conversion = conversion
+	if err := cl.Update(ctx, crd); err != nil {
+		return operr.Wrapf(err, "failed to update conversion of CRD %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/utils"
+)
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+
+	// reservedLabelPrefix is the prefix of the pod
+	// labels which are reserved for the operator
+	reservedLabelPrefix = "openebs.io/"
+)
+
+// replicaLabels are the labels of the replica pods which the
+// replicas are spread across the nodes by
+var replicaLabels = labels.Set{
+	"openebs.io/cas-type":  "jiva",
+	"openebs.io/component": "jiva-replica",
+}
+
+// imageRegexp matches an image reference, an optional registry host with
+// an optional port followed by the repository path, an optional tag and
+// an optional digest, e.g. registry.example.com:5000/openebs/jiva:3.6.0
+var imageRegexp = regexp.MustCompile(`^` +
+	`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?` +
+	`(?:@[a-zA-Z][a-zA-Z0-9]*(?:[-_+.][a-zA-Z][a-zA-Z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// validatePolicySpec validates the policy spec of a JivaVolumePolicy or
+// of a JivaVolume. The defaults aren't set to a policy with a base
+// policy, so its unset fields are inherited from the base policy.
+func validatePolicySpec(policy *jivaAPI.JivaVolumePolicySpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	inherits := policy.BasePolicy != ""
+
+	if policy.ReplicaSC == "" && !inherits {
+		allErrs = append(allErrs, field.Required(fldPath.Child("replicaSC"), "storage class of the replicas is required"))
+	}
+
+	targetPath := fldPath.Child("target")
+	if rf := policy.Target.ReplicationFactor; rf < 0 || (rf == 0 && !inherits) {
+		allErrs = append(allErrs, field.Invalid(targetPath.Child("replicationFactor"),
+			policy.Target.ReplicationFactor, "must be at least 1"))
+	}
+	allErrs = append(allErrs, validateResources(policy.Target.Resources, targetPath.Child("resources"))...)
+	allErrs = append(allErrs, validateResources(policy.Target.AuxResources, targetPath.Child("auxResources"))...)
+	allErrs = append(allErrs, validateAffinity(policy.Target.Affinity, targetPath.Child("affinity"))...)
+	allErrs = append(allErrs, validateQoS(policy.Target.QoS, targetPath.Child("qos"))...)
+	allErrs = append(allErrs, validateImages(&policy.Target.PodTemplateResources, targetPath)...)
+	allErrs = append(allErrs, validatePodMetadata(&policy.Target.PodTemplateResources, targetPath)...)
+	allErrs = append(allErrs, validateImage(policy.Target.MonitorImage, targetPath.Child("monitorImage"))...)
+	allErrs = append(allErrs, validateExternalService(policy.Target.ExternalService, targetPath.Child("externalService"))...)
+
+	replicaPath := fldPath.Child("replica")
+	allErrs = append(allErrs, validateResources(policy.Replica.Resources, replicaPath.Child("resources"))...)
+	allErrs = append(allErrs, validateAffinity(policy.Replica.Affinity, replicaPath.Child("affinity"))...)
+	allErrs = append(allErrs, validateReplicaAffinity(policy.Replica.Affinity, replicaPath.Child("affinity"))...)
+	allErrs = append(allErrs, validateImages(&policy.Replica.PodTemplateResources, replicaPath)...)
+	allErrs = append(allErrs, validatePodMetadata(&policy.Replica.PodTemplateResources, replicaPath)...)
+	if spread := policy.Replica.TopologySpread; spread != nil {
+		spreadPath := replicaPath.Child("topologySpread")
+		if spread.TopologyKey == "" {
+			allErrs = append(allErrs, field.Required(spreadPath.Child("topologyKey"), ""))
+		}
+		if spread.MaxSkew < 0 {
+			allErrs = append(allErrs, field.Invalid(spreadPath.Child("maxSkew"), spread.MaxSkew, "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateResources checks that none of the resource quantities is negative
+func validateResources(res *corev1.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if res == nil {
+		return allErrs
+	}
+	for name, q := range res.Requests {
+		if q.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests").Key(string(name)),
+				q.String(), "must not be negative"))
+		}
+	}
+	for name, q := range res.Limits {
+		if q.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limits").Key(string(name)),
+				q.String(), "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateQoS checks that none of the I/O limits is negative
+func validateQoS(qos *jivaAPI.QoSSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if qos == nil {
+		return allErrs
+	}
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"readIOPS", qos.ReadIOPS},
+		{"writeIOPS", qos.WriteIOPS},
+		{"readBPS", qos.ReadBPS},
+		{"writeBPS", qos.WriteBPS},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(l.name), l.value, "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateExternalService checks the type of the service and the
+// allowed initiators, the jiva target doesn't support CHAP so the
+// target can't be exposed without restricting the initiators
+func validateExternalService(spec *jivaAPI.ExternalServiceSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec == nil {
+		return allErrs
+	}
+	if spec.Type != corev1.ServiceTypeNodePort && spec.Type != corev1.ServiceTypeLoadBalancer {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), spec.Type,
+			[]string{string(corev1.ServiceTypeNodePort), string(corev1.ServiceTypeLoadBalancer)}))
+	}
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(spec.Annotations, fldPath.Child("annotations"))...)
+
+	initiatorsPath := fldPath.Child("allowedInitiators")
+	if len(spec.AllowedInitiators) == 0 {
+		allErrs = append(allErrs, field.Required(initiatorsPath,
+			"the target doesn't support CHAP, the initiators must be restricted"))
+	}
+	for i, cidr := range spec.AllowedInitiators {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(initiatorsPath.Index(i), cidr, "must be a valid CIDR"))
+			continue
+		}
+		if ones, _ := ipNet.Mask.Size(); ones == 0 {
+			allErrs = append(allErrs, field.Invalid(initiatorsPath.Index(i), cidr,
+				"must not allow all the addresses"))
+		}
+	}
+	return allErrs
+}
+
+// validateImages validates the image, the pull
+// policy and the image pull secrets of the pod
+func validateImages(res *jivaAPI.PodTemplateResources, fldPath *field.Path) field.ErrorList {
+	allErrs := validateImage(res.Image, fldPath.Child("image"))
+	switch res.ImagePullPolicy {
+	case "", corev1.PullAlways, corev1.PullNever, corev1.PullIfNotPresent:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("imagePullPolicy"), res.ImagePullPolicy,
+			[]string{string(corev1.PullAlways), string(corev1.PullNever), string(corev1.PullIfNotPresent)}))
+	}
+	for i, ref := range res.ImagePullSecrets {
+		namePath := fldPath.Child("imagePullSecrets").Index(i).Child("name")
+		if ref.Name == "" {
+			allErrs = append(allErrs, field.Required(namePath, ""))
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(namePath, ref.Name, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateImage checks that the image, if set, is a valid image reference
+func validateImage(image string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if image != "" && !imageRegexp.MatchString(image) {
+		allErrs = append(allErrs, field.Invalid(fldPath, image, "must be a valid image reference"))
+	}
+	return allErrs
+}
+
+// validatePodMetadata validates the labels, the annotations and the env
+// variables of the pod, the labels reserved for the operator are rejected
+func validatePodMetadata(res *jivaAPI.PodTemplateResources, fldPath *field.Path) field.ErrorList {
+	labelsPath := fldPath.Child("labels")
+	allErrs := metavalidation.ValidateLabels(res.Labels, labelsPath)
+	for k := range res.Labels {
+		if strings.HasPrefix(k, reservedLabelPrefix) {
+			allErrs = append(allErrs, field.Invalid(labelsPath.Key(k), k,
+				fmt.Sprintf("labels with the %s prefix are reserved", reservedLabelPrefix)))
+		}
+	}
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(res.Annotations, fldPath.Child("annotations"))...)
+
+	for i, env := range res.Env {
+		namePath := fldPath.Child("env").Index(i).Child("name")
+		if env.Name == "" {
+			allErrs = append(allErrs, field.Required(namePath, ""))
+			continue
+		}
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			allErrs = append(allErrs, field.Invalid(namePath, env.Name, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateAffinity rejects the same pod affinity term being
+// both required by the pod affinity and the pod anti-affinity
+func validateAffinity(affinity *corev1.Affinity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if affinity == nil || affinity.PodAffinity == nil || affinity.PodAntiAffinity == nil {
+		return allErrs
+	}
+	for i, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		for _, antiTerm := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if term.TopologyKey == antiTerm.TopologyKey &&
+				equality.Semantic.DeepEqual(term.LabelSelector, antiTerm.LabelSelector) &&
+				equality.Semantic.DeepEqual(term.Namespaces, antiTerm.Namespaces) {
+				allErrs = append(allErrs, field.Invalid(
+					fldPath.Child("podAffinity", "requiredDuringSchedulingIgnoredDuringExecution").Index(i),
+					term.TopologyKey, "conflicts with a required pod anti-affinity term"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateReplicaAffinity rejects a required pod affinity which places the
+// replicas on the same node, the replicas are always spread across the nodes.
+func validateReplicaAffinity(affinity *corev1.Affinity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if affinity == nil || affinity.PodAffinity == nil {
+		return allErrs
+	}
+	for i, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey != hostnameTopologyKey || term.LabelSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(
+				fldPath.Child("podAffinity", "requiredDuringSchedulingIgnoredDuringExecution").Index(i).Child("labelSelector"),
+				term.LabelSelector, err.Error()))
+			continue
+		}
+		if !selector.Empty() && selector.Matches(replicaLabels) {
+			allErrs = append(allErrs, field.Invalid(
+				fldPath.Child("podAffinity", "requiredDuringSchedulingIgnoredDuringExecution").Index(i),
+				term.TopologyKey, "conflicts with the anti-affinity of the replicas across the nodes"))
+		}
+	}
+	return allErrs
+}
+
+// validateVolumeUpdate validates the changes to the spec of a JivaVolume
+func validateVolumeUpdate(oldJV, newJV *jivaAPI.JivaVolume) field.ErrorList {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if oldJV.Spec.AccessType != "" && newJV.Spec.AccessType != oldJV.Spec.AccessType {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("accessType"), "field is immutable"))
+	}
+
+	if newJV.Spec.Capacity != oldJV.Spec.Capacity && oldJV.Spec.Capacity != "" {
+		oldSize, err := utils.CapacityInBytes(oldJV.Spec.Capacity)
+		if err == nil {
+			newSize, err := utils.CapacityInBytes(newJV.Spec.Capacity)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("capacity"), newJV.Spec.Capacity, err.Error()))
+			} else if newSize < oldSize {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("capacity"), newJV.Spec.Capacity,
+					fmt.Sprintf("can't be less than the current capacity %s", oldJV.Spec.Capacity)))
+			}
+		}
+	}
+
+	rf := oldJV.Spec.Policy.Target.ReplicationFactor
+	quorum := rf/2 + 1
+	desired := newJV.Spec.DesiredReplicationFactor
+	if desired != oldJV.Spec.DesiredReplicationFactor && desired != 0 && rf != 0 && desired < quorum {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("desiredReplicationFactor"), desired,
+			fmt.Sprintf("can't be less than the quorum %d of the replication factor %d", quorum, rf)))
+	}
+	return allErrs
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+)
+
+func TestValidatePolicySpec(t *testing.T) {
+	replicaSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"openebs.io/component": "jiva-replica"},
+	}
+	tests := map[string]struct {
+		policy    jivaAPI.JivaVolumePolicySpec
+		expectErr bool
+	}{
+		"Test valid policy": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+			},
+			expectErr: false,
+		},
+		"Test policy without replicaSC": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				Target: jivaAPI.TargetSpec{ReplicationFactor: 3},
+			},
+			expectErr: true,
+		},
+		"Test policy with negative replicationFactor": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: -1},
+			},
+			expectErr: true,
+		},
+		"Test policy with negative resource quantity": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Resources: &corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("-1")},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with negative qos limit": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					QoS:               &jivaAPI.QoSSpec{ReadIOPS: 1000, WriteBPS: -1},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with external service": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService: &jivaAPI.ExternalServiceSpec{
+						Type:              corev1.ServiceTypeLoadBalancer,
+						Annotations:       map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true"},
+						AllowedInitiators: []string{"10.20.0.0/16", "192.168.1.10/32"},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		"Test policy with external service without allowed initiators": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService:   &jivaAPI.ExternalServiceSpec{Type: corev1.ServiceTypeNodePort},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with external service allowing all initiators": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService: &jivaAPI.ExternalServiceSpec{
+						Type:              corev1.ServiceTypeNodePort,
+						AllowedInitiators: []string{"0.0.0.0/0"},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with external service of invalid type": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService: &jivaAPI.ExternalServiceSpec{
+						Type:              corev1.ServiceTypeClusterIP,
+						AllowedInitiators: []string{"10.20.0.0/16"},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with external service with invalid initiator": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					ExternalService: &jivaAPI.ExternalServiceSpec{
+						Type:              corev1.ServiceTypeNodePort,
+						AllowedInitiators: []string{"10.20.0.300/16"},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with image overrides": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					MonitorImage:      "openebs/m-exporter:3.6.0",
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Image:            "registry.example.com:5000/openebs/jiva:3.6.0-rc1",
+						ImagePullPolicy:  corev1.PullAlways,
+						ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-secret"}},
+					},
+				},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Image: "openebs/jiva@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+					},
+				},
+			},
+			expectErr: false,
+		},
+		"Test policy with invalid image": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Image: "openebs/Jiva:latest tag",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with invalid image pull policy": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						ImagePullPolicy: "Sometimes",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with empty image pull secret": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						ImagePullSecrets: []corev1.LocalObjectReference{{}},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with pod metadata and security context": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Labels:      map[string]string{"cost-center": "storage"},
+						Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+						Env:         []corev1.EnvVar{{Name: "GOTRACEBACK", Value: "all"}},
+					},
+				},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						SecurityContext: &corev1.SecurityContext{
+							Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+						},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		"Test policy with reserved pod label": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Labels: map[string]string{"openebs.io/persistent-volume": "pvc-1"},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with invalid env name": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target: jivaAPI.TargetSpec{
+					ReplicationFactor: 3,
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Env: []corev1.EnvVar{{Name: "1=INVALID"}},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		"Test policy with replica affinity to the replicas on the same node": {
+			policy: jivaAPI.JivaVolumePolicySpec{
+				ReplicaSC: "openebs-hostpath",
+				Target:    jivaAPI.TargetSpec{ReplicationFactor: 3},
+				Replica: jivaAPI.ReplicaSpec{
+					PodTemplateResources: jivaAPI.PodTemplateResources{
+						Affinity: &corev1.Affinity{
+							PodAffinity: &corev1.PodAffinity{
+								RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+									LabelSelector: replicaSelector,
+									TopologyKey:   hostnameTopologyKey,
+								}},
+							},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			errs := validatePolicySpec(&mock.policy, field.NewPath("spec"))
+			if mock.expectErr && len(errs) == 0 {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && len(errs) > 0 {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, errs)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeUpdate(t *testing.T) {
+	tests := map[string]struct {
+		oldSpec   jivaAPI.JivaVolumeSpec
+		newSpec   jivaAPI.JivaVolumeSpec
+		expectErr bool
+	}{
+		"Test capacity increase": {
+			oldSpec:   jivaAPI.JivaVolumeSpec{Capacity: "4Gi", AccessType: "mount"},
+			newSpec:   jivaAPI.JivaVolumeSpec{Capacity: "5Gi", AccessType: "mount"},
+			expectErr: false,
+		},
+		"Test capacity decrease": {
+			oldSpec:   jivaAPI.JivaVolumeSpec{Capacity: "5Gi", AccessType: "mount"},
+			newSpec:   jivaAPI.JivaVolumeSpec{Capacity: "4Gi", AccessType: "mount"},
+			expectErr: true,
+		},
+		"Test access type change": {
+			oldSpec:   jivaAPI.JivaVolumeSpec{Capacity: "4Gi", AccessType: "mount"},
+			newSpec:   jivaAPI.JivaVolumeSpec{Capacity: "4Gi", AccessType: "block"},
+			expectErr: true,
+		},
+		"Test desired replication factor below quorum": {
+			oldSpec: jivaAPI.JivaVolumeSpec{
+				Policy:                   jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+				DesiredReplicationFactor: 3,
+			},
+			newSpec: jivaAPI.JivaVolumeSpec{
+				Policy:                   jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+				DesiredReplicationFactor: 1,
+			},
+			expectErr: true,
+		},
+		"Test desired replication factor set below quorum": {
+			oldSpec: jivaAPI.JivaVolumeSpec{
+				Policy: jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+			},
+			newSpec: jivaAPI.JivaVolumeSpec{
+				Policy:                   jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+				DesiredReplicationFactor: 1,
+			},
+			expectErr: true,
+		},
+		"Test desired replication factor set to quorum": {
+			oldSpec: jivaAPI.JivaVolumeSpec{
+				Policy: jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+			},
+			newSpec: jivaAPI.JivaVolumeSpec{
+				Policy:                   jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 3}},
+				DesiredReplicationFactor: 2,
+			},
+			expectErr: false,
+		},
+		"Test desired replication factor above the replication factor": {
+			oldSpec: jivaAPI.JivaVolumeSpec{
+				Policy: jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 1}},
+			},
+			newSpec: jivaAPI.JivaVolumeSpec{
+				Policy:                   jivaAPI.JivaVolumePolicySpec{Target: jivaAPI.TargetSpec{ReplicationFactor: 1}},
+				DesiredReplicationFactor: 3,
+			},
+			expectErr: false,
+		},
+	}
+	for name, mock := range tests {
+		name, mock := name, mock
+		t.Run(name, func(t *testing.T) {
+			errs := validateVolumeUpdate(
+				&jivaAPI.JivaVolume{Spec: mock.oldSpec},
+				&jivaAPI.JivaVolume{Spec: mock.newSpec},
+			)
+			if mock.expectErr && len(errs) == 0 {
+				t.Fatalf("Test %q failed: expected error not to be nil", name)
+			}
+			if !mock.expectErr && len(errs) > 0 {
+				t.Fatalf("Test %q failed: expected error to be nil, got: %v", name, errs)
+			}
+		})
+	}
+}
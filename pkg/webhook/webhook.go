@@ -0,0 +1,183 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	jivaAPI "github.com/openebs/jiva-operator/pkg/apis/openebs/v1"
+	"github.com/openebs/jiva-operator/pkg/controllers"
+)
+
+// JivaVolumePolicyWebhook sets the defaults to and validates the
+// JivaVolumePolicy objects at admission time, so that an invalid
+// policy is rejected before any volume is provisioned with it.
+type JivaVolumePolicyWebhook struct{}
+
+var _ admission.CustomDefaulter = &JivaVolumePolicyWebhook{}
+var _ admission.CustomValidator = &JivaVolumePolicyWebhook{}
+
+// SetupWithManager registers the webhook with the webhook server of the manager
+func (w *JivaVolumePolicyWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&jivaAPI.JivaVolumePolicy{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter. A policy with a base
+// policy isn't defaulted, as the defaults would override the fields
+// of the base policy, the defaults are set to the merged spec.
+func (w *JivaVolumePolicyWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	policy, ok := obj.(*jivaAPI.JivaVolumePolicy)
+	if !ok {
+		return fmt.Errorf("expected a JivaVolumePolicy but got a %T", obj)
+	}
+	if policy.Spec.BasePolicy != "" {
+		return nil
+	}
+	controllers.SetPolicyDefaults(&policy.Spec)
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (w *JivaVolumePolicyWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	policy, ok := obj.(*jivaAPI.JivaVolumePolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a JivaVolumePolicy but got a %T", obj)
+	}
+	allErrs := validatePolicySpec(&policy.Spec, field.NewPath("spec"))
+	if policy.Spec.BasePolicy != "" && policy.Spec.BasePolicy == policy.Name {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "basePolicy"),
+			policy.Spec.BasePolicy, "a policy can't inherit from itself"))
+	}
+	return nil, toInvalidError("JivaVolumePolicy", policy.Name, allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (w *JivaVolumePolicyWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return w.ValidateCreate(ctx, newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (w *JivaVolumePolicyWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// JivaVolumeWebhook sets the defaults to the policy of the JivaVolume
+// objects and rejects the changes which can't be applied to a volume.
+type JivaVolumeWebhook struct{}
+
+var _ admission.CustomDefaulter = &JivaVolumeWebhook{}
+var _ admission.CustomValidator = &JivaVolumeWebhook{}
+
+// SetupWithManager registers the webhook with the webhook server of the manager
+func (w *JivaVolumeWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&jivaAPI.JivaVolume{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter. The policy of a volume
+// created without one is populated by the controller from the policy
+// annotation, so only a policy set in the spec is defaulted here. The
+// exporter sidecar is disabled by default only when the volume is created,
+// as every update of the volume, including the status updates, is defaulted.
+func (w *JivaVolumeWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	jv, ok := obj.(*jivaAPI.JivaVolume)
+	if !ok {
+		return fmt.Errorf("expected a JivaVolume but got a %T", obj)
+	}
+	if isPolicyEmpty(&jv.Spec.Policy) {
+		return nil
+	}
+	controllers.SetPolicyDefaults(&jv.Spec.Policy)
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Operation == admissionv1.Create {
+		controllers.SetMonitorDefault(&jv.Spec.Policy)
+	}
+	if jv.Spec.DesiredReplicationFactor == 0 {
+		jv.Spec.DesiredReplicationFactor = jv.Spec.Policy.Target.ReplicationFactor
+	}
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (w *JivaVolumeWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	jv, ok := obj.(*jivaAPI.JivaVolume)
+	if !ok {
+		return nil, fmt.Errorf("expected a JivaVolume but got a %T", obj)
+	}
+	if isPolicyEmpty(&jv.Spec.Policy) {
+		return nil, nil
+	}
+	return nil, toInvalidError("JivaVolume", jv.Name, validatePolicySpec(&jv.Spec.Policy, field.NewPath("spec", "policy")))
+}
+
+// ValidateUpdate implements admission.CustomValidator. The policy is
+// validated only when it changes so that the status updates of the
+// existing volumes are never rejected.
+func (w *JivaVolumeWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldJV, ok := oldObj.(*jivaAPI.JivaVolume)
+	if !ok {
+		return nil, fmt.Errorf("expected a JivaVolume but got a %T", oldObj)
+	}
+	newJV, ok := newObj.(*jivaAPI.JivaVolume)
+	if !ok {
+		return nil, fmt.Errorf("expected a JivaVolume but got a %T", newObj)
+	}
+	// the volume is being deleted, don't block the removal of the finalizers
+	if newJV.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	allErrs := validateVolumeUpdate(oldJV, newJV)
+	if !isPolicyEmpty(&newJV.Spec.Policy) &&
+		!equality.Semantic.DeepEqual(oldJV.Spec.Policy, newJV.Spec.Policy) {
+		allErrs = append(allErrs, validatePolicySpec(&newJV.Spec.Policy, field.NewPath("spec", "policy"))...)
+	}
+	return nil, toInvalidError("JivaVolume", newJV.Name, allErrs)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (w *JivaVolumeWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func isPolicyEmpty(policy *jivaAPI.JivaVolumePolicySpec) bool {
+	return equality.Semantic.DeepEqual(*policy, jivaAPI.JivaVolumePolicySpec{})
+}
+
+// toInvalidError converts the validation errors into an Invalid
+// status error of the object, or returns nil if there are none.
+func toInvalidError(kind, name string, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(jivaAPI.GroupVersion.WithKind(kind).GroupKind(), name, allErrs)
+}